@@ -0,0 +1,186 @@
+// Package barcode 在截图中查找并解码二维码(QR code)和常见的条形码，
+// 使用纯 Go 的 gozxing，不需要联网。
+package barcode
+
+import (
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/multi/qrcode"
+	"github.com/makiuchi-d/gozxing/oned"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Code 识别出的一个二维码或者条形码
+type Code struct {
+	// Format 格式，例如 "QR_CODE"、"EAN_13"、"CODE_128"
+	Format string
+	// Text 解码之后的内容
+	Text string
+	// Box 在图片中的位置，使用传入图片的坐标
+	Box image.Rectangle
+}
+
+// maxCodesPerFormat 每种条形码最多查找的个数
+const maxCodesPerFormat = 16
+
+// lightThreshold 判断像素是浅色还是深色的亮度阈值
+const lightThreshold = 128
+
+// Detect 查找 img 中所有的二维码和条形码。
+// 先按照深色码、浅色背景查找，再将图片反色查找深色主题中浅色的码。
+func Detect(img image.Image) []Code {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return nil
+	}
+	// 转换成灰度图，坐标从 (0, 0) 开始
+	gray := image.NewGray(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(gray, gray.Rect, &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(gray, gray.Rect, img, bounds.Min, draw.Over)
+
+	codes := detect(gray)
+	inverted := image.NewGray(gray.Rect)
+	for ii, v := range gray.Pix {
+		inverted.Pix[ii] = 255 - v
+	}
+	for _, c := range detect(inverted) {
+		if !overlaps(codes, c.Box) {
+			codes = append(codes, c)
+		}
+	}
+	for ii := range codes {
+		codes[ii].Box = codes[ii].Box.Add(bounds.Min)
+	}
+	return codes
+}
+
+// detect 查找深色的码
+func detect(gray *image.Gray) []Code {
+	hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_TRY_HARDER: true}
+	var codes []Code
+
+	if bmp, err := gozxing.NewBinaryBitmapFromImage(gray); err == nil {
+		results, _ := qrcode.NewQRCodeMultiReader().DecodeMultiple(bmp, hints)
+		for _, r := range results {
+			codes = append(codes, Code{Format: r.GetBarcodeFormat().String(), Text: r.GetText(), Box: qrBox(gray, r.GetResultPoints())})
+		}
+	}
+
+	// 一维码的 reader 每次只返回一个结果: 找到之后将它涂白再继续查找
+	readers := []gozxing.Reader{
+		oned.NewMultiFormatUPCEANReader(hints),
+		oned.NewCode128Reader(),
+		oned.NewCode39Reader(),
+		oned.NewCode93Reader(),
+	}
+	work := image.NewGray(gray.Rect)
+	copy(work.Pix, gray.Pix)
+	// 二维码的区域不再查找一维码
+	for _, c := range codes {
+		draw.Draw(work, c.Box, &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	}
+	for _, reader := range readers {
+		for ii := 0; ii < maxCodesPerFormat; ii++ {
+			bmp, err := gozxing.NewBinaryBitmapFromImage(work)
+			if err != nil {
+				break
+			}
+			r, err := reader.Decode(bmp, hints)
+			if err != nil {
+				break
+			}
+			box := linearBox(work, r.GetResultPoints())
+			if box.Empty() {
+				break
+			}
+			codes = append(codes, Code{Format: r.GetBarcodeFormat().String(), Text: r.GetText(), Box: box})
+			draw.Draw(work, box, &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+		}
+	}
+	return codes
+}
+
+// qrBox 二维码的区域: points 是定位图案(以及校正图案)的中心，
+// 从左上角定位图案的中心向左找到图案的外边缘，得到中心到二维码边缘的距离
+func qrBox(gray *image.Gray, points []gozxing.ResultPoint) image.Rectangle {
+	if len(points) < 3 {
+		return pointsBox(points)
+	}
+	// gozxing 的顺序是左下、左上、右上
+	topLeft := points[1]
+	x, y := int(math.Round(topLeft.GetX())), int(math.Round(topLeft.GetY()))
+	// 定位图案从中心向外是 深(1.5 个模块)、浅(1)、深(1)，之后是空白区
+	pad := 0
+	transitions := 0
+	dark := true
+	for xx := x; xx >= gray.Rect.Min.X && transitions < 3; xx-- {
+		isDark := gray.GrayAt(xx, y).Y < lightThreshold
+		if isDark != dark {
+			dark = isDark
+			transitions++
+		}
+		pad = x - xx
+	}
+	if transitions < 3 {
+		// 找不到边缘: 使用 21x21 (最小的二维码)的比例估计
+		dist := math.Hypot(points[2].GetX()-topLeft.GetX(), points[2].GetY()-topLeft.GetY())
+		pad = int(math.Ceil(dist * 3.5 / 14))
+	}
+	box := pointsBox(points)
+	return image.Rect(box.Min.X-pad, box.Min.Y-pad, box.Max.X+pad, box.Max.Y+pad).Intersect(gray.Rect)
+}
+
+// linearBox 一维码的区域: points 是扫描行上的起点和终点，
+// 向上下扩展到和扫描行的图案不再相同的行
+func linearBox(gray *image.Gray, points []gozxing.ResultPoint) image.Rectangle {
+	box := pointsBox(points)
+	if box.Empty() {
+		return box
+	}
+	row := box.Min.Y
+	same := func(y int) bool {
+		if y < gray.Rect.Min.Y || y >= gray.Rect.Max.Y {
+			return false
+		}
+		matches := 0
+		for x := box.Min.X; x < box.Max.X; x++ {
+			if (gray.GrayAt(x, y).Y < lightThreshold) == (gray.GrayAt(x, row).Y < lightThreshold) {
+				matches++
+			}
+		}
+		return matches*10 >= box.Dx()*9
+	}
+	top, bottom := row, row+1
+	for same(top - 1) {
+		top--
+	}
+	for same(bottom) {
+		bottom++
+	}
+	return image.Rect(box.Min.X, top, box.Max.X, bottom).Intersect(gray.Rect)
+}
+
+// pointsBox 包含所有点的最小矩形
+func pointsBox(points []gozxing.ResultPoint) image.Rectangle {
+	if len(points) == 0 {
+		return image.Rectangle{}
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range points {
+		minX, maxX = math.Min(minX, p.GetX()), math.Max(maxX, p.GetX())
+		minY, maxY = math.Min(minY, p.GetY()), math.Max(maxY, p.GetY())
+	}
+	return image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX))+1, int(math.Ceil(maxY))+1)
+}
+
+func overlaps(codes []Code, box image.Rectangle) bool {
+	for _, c := range codes {
+		if c.Box.Overlaps(box) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+// Package beautify 导出截图时的美化处理: 在截图周围添加留白和背景，
+// 并且可以设置圆角、阴影以及边框，适合直接粘贴到幻灯片或者博客中。
+//
+// 美化只作用在导出的图片上，不会修改编辑中的截图和标注。
+package beautify
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Options 美化参数
+type Options struct {
+	// Padding 截图四周的留白像素
+	Padding int
+
+	// Background 背景颜色，GradientTo 不透明时背景从左上角的 Background 渐变到右下角的 GradientTo
+	Background, GradientTo color.RGBA
+
+	// CornerRadius 截图的圆角半径
+	CornerRadius float64
+
+	// ShadowBlur 阴影模糊半径，0 表示没有阴影
+	ShadowBlur float64
+	// ShadowOffset 阴影相对截图的偏移
+	ShadowOffset image.Point
+	// ShadowColor 阴影颜色，透明度决定阴影的深浅
+	ShadowColor color.RGBA
+
+	// BorderWidth 边框宽度，0 表示没有边框
+	BorderWidth float64
+	// BorderColor 边框颜色
+	BorderColor color.RGBA
+}
+
+// Preset 命名的美化参数，方便保存和复用
+type Preset struct {
+	Name    string
+	Options Options
+}
+
+// Presets 内置的美化预设
+var Presets = []Preset{
+	{
+		Name: "简洁",
+		Options: Options{
+			Padding:      32,
+			Background:   color.RGBA{R: 0xF2, G: 0xF2, B: 0xF2, A: 0xFF},
+			CornerRadius: 8,
+			ShadowBlur:   12,
+			ShadowOffset: image.Point{Y: 4},
+			ShadowColor:  color.RGBA{A: 0x60},
+		},
+	},
+	{
+		Name: "渐变",
+		Options: Options{
+			Padding:      64,
+			Background:   color.RGBA{R: 0x5B, G: 0x86, B: 0xE5, A: 0xFF},
+			GradientTo:   color.RGBA{R: 0x36, G: 0xD1, B: 0xDC, A: 0xFF},
+			CornerRadius: 12,
+			ShadowBlur:   24,
+			ShadowOffset: image.Point{Y: 10},
+			ShadowColor:  color.RGBA{A: 0x80},
+		},
+	},
+	{
+		Name: "边框",
+		Options: Options{
+			Padding:     16,
+			Background:  color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF},
+			BorderWidth: 2,
+			BorderColor: color.RGBA{R: 0x99, G: 0x99, B: 0x99, A: 0xFF},
+		},
+	},
+}
+
+// Apply 将截图 img 放到美化之后的画布上，返回新的图片，img 本身不会被修改
+func Apply(img image.Image, opts Options) *image.RGBA {
+	bounds := img.Bounds()
+	padding := opts.Padding
+	if padding < 0 {
+		padding = 0
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, bounds.Dx()+2*padding, bounds.Dy()+2*padding))
+	fillBackground(canvas, opts.Background, opts.GradientTo)
+
+	shot := image.Rect(padding, padding, padding+bounds.Dx(), padding+bounds.Dy())
+	radius := math.Min(opts.CornerRadius, float64(minInt(bounds.Dx(), bounds.Dy()))/2)
+
+	// 阴影: 圆角矩形的遮罩模糊之后按照阴影颜色叠加
+	if opts.ShadowBlur > 0 && opts.ShadowColor.A > 0 {
+		shadowMask := roundedMask(canvas.Rect, shot.Add(opts.ShadowOffset), radius, 0)
+		BoxBlur(shadowMask, int(opts.ShadowBlur/2+0.5))
+		draw.DrawMask(canvas, canvas.Rect, image.NewUniform(opts.ShadowColor), image.Point{},
+			shadowMask, image.Point{}, draw.Over)
+	}
+
+	// 截图本身，圆角以外的部分透出背景
+	draw.DrawMask(canvas, shot, img, bounds.Min, roundedMask(canvas.Rect, shot, radius, 0), shot.Min, draw.Over)
+
+	// 边框画在截图的内侧，不改变截图的大小
+	if opts.BorderWidth > 0 && opts.BorderColor.A > 0 {
+		draw.DrawMask(canvas, shot, image.NewUniform(opts.BorderColor), image.Point{},
+			roundedMask(canvas.Rect, shot, radius, opts.BorderWidth), shot.Min, draw.Over)
+	}
+	return canvas
+}
+
+// fillBackground 使用纯色或者对角线渐变填充背景
+func fillBackground(img *image.RGBA, from, to color.RGBA) {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	if to.A == 0 {
+		draw.Draw(img, img.Rect, image.NewUniform(from), image.Point{}, draw.Src)
+		return
+	}
+	span := float64(w + h)
+	lerp := func(a, b uint8, t float64) uint8 {
+		return uint8(float64(a)*(1-t) + float64(b)*t + 0.5)
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			t := float64(x+y) / span
+			img.SetRGBA(x, y, color.RGBA{
+				R: lerp(from.R, to.R, t),
+				G: lerp(from.G, to.G, t),
+				B: lerp(from.B, to.B, t),
+				A: lerp(from.A, to.A, t),
+			})
+		}
+	}
+}
+
+// roundedMask 生成圆角矩形 rect 的遮罩，边缘做了一个像素的抗锯齿。
+// stroke > 0 时只生成宽度为 stroke 的内侧轮廓。
+func roundedMask(bounds, rect image.Rectangle, radius, stroke float64) *image.Alpha {
+	mask := image.NewAlpha(bounds)
+	halfW := float64(rect.Dx()) / 2
+	halfH := float64(rect.Dy()) / 2
+	cx := float64(rect.Min.X) + halfW
+	cy := float64(rect.Min.Y) + halfH
+	coverage := func(d float64) float64 {
+		return math.Max(0, math.Min(1, 0.5-d))
+	}
+	area := rect.Intersect(bounds)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			px := math.Abs(float64(x)+0.5-cx) - (halfW - radius)
+			py := math.Abs(float64(y)+0.5-cy) - (halfH - radius)
+			dist := math.Hypot(math.Max(px, 0), math.Max(py, 0)) + math.Min(math.Max(px, py), 0) - radius
+			a := coverage(dist)
+			if stroke > 0 {
+				a = math.Min(a, 1-coverage(dist+stroke))
+			}
+			mask.Pix[mask.PixOffset(x, y)] = uint8(a*0xFF + 0.5)
+		}
+	}
+	return mask
+}
+
+// BoxBlur 对遮罩做三次水平和垂直方向的均值模糊，近似高斯模糊
+func BoxBlur(mask *image.Alpha, radius int) {
+	if radius <= 0 {
+		return
+	}
+	w, h := mask.Rect.Dx(), mask.Rect.Dy()
+	buf := make([]uint8, len(mask.Pix))
+	blurLine := func(src, dst []uint8, start, step, n int) {
+		var sum int
+		window := 2*radius + 1
+		at := func(i int) int {
+			if i < 0 || i >= n {
+				return 0
+			}
+			return int(src[start+i*step])
+		}
+		for i := -radius; i <= radius; i++ {
+			sum += at(i)
+		}
+		for i := 0; i < n; i++ {
+			dst[start+i*step] = uint8(sum / window)
+			sum += at(i+radius+1) - at(i-radius)
+		}
+	}
+	for pass := 0; pass < 3; pass++ {
+		for y := 0; y < h; y++ {
+			blurLine(mask.Pix, buf, y*mask.Stride, 1, w)
+		}
+		for x := 0; x < w; x++ {
+			blurLine(buf, mask.Pix, x, mask.Stride, h)
+		}
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
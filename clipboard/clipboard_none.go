@@ -1,15 +1,27 @@
+//go:build !linux && !windows
 // +build !linux,!windows
 
 package clipboard
 
 // Placeholder implementation that informs about missing capability.
 
-import "errors"
+import (
+	"errors"
+	"image"
+)
 
 func CopyImage(img image.Image) error {
 	return errors.New("Clipboard image copy not implemented in this platform, sorry.")
 }
 
+func CopyEncodedImage(img image.Image, content []byte, mimeType string) error {
+	return errors.New("Clipboard image copy not implemented in this platform, sorry.")
+}
+
 func CopyText(text string) error {
 	return errors.New("Clipboard text copy not implemented in this platform, sorry.")
 }
+
+func PasteImage() (image.Image, error) {
+	return nil, errors.New("Clipboard image paste not implemented in this platform, sorry.")
+}
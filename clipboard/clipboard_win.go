@@ -6,9 +6,11 @@ package clipboard
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"image"
+	"image/color"
 	"image/png"
 	"strings"
 	"syscall"
@@ -32,6 +34,8 @@ import (
 var (
 	user32                      = syscall.MustLoadDLL("user32.dll")
 	procRegisterClipboardFormat = user32.MustFindProc("RegisterClipboardFormatA")
+	kernel32                    = syscall.MustLoadDLL("kernel32.dll")
+	procGlobalSize              = kernel32.MustFindProc("GlobalSize")
 )
 
 const (
@@ -41,7 +45,22 @@ const (
 )
 
 func CopyImage(img image.Image) error {
-	glog.V(2).Infof("CopyImage(bounds=%+v)", img.Bounds())
+	var pngContentBuffer bytes.Buffer
+	_ = png.Encode(&pngContentBuffer, img)
+	return CopyEncodedImage(img, pngContentBuffer.Bytes(), "image/png")
+}
+
+// registeredImageFormats 剪贴板中已经编码的图片格式的名称，按照 MIME 类型
+var registeredImageFormats = map[string]string{
+	"image/png":  "PNG",
+	"image/jpeg": "JFIF",
+	"image/gif":  "GIF",
+}
+
+// CopyEncodedImage 复制图片到剪贴板: 除了位图之外，同时提供按照 mimeType 编码的 content。
+// Windows 剪贴板没有对应名称的格式时提供 PNG。
+func CopyEncodedImage(img image.Image, content []byte, mimeType string) error {
+	glog.V(2).Infof("CopyEncodedImage(bounds=%+v, %d bytes of %s)", img.Bounds(), len(content), mimeType)
 
 	// CF_DIBV5 version
 	_, bitmapHeader, bitmapBits, err := hBitmapFromImage(img, false)
@@ -64,17 +83,20 @@ func CopyImage(img image.Image) error {
 	// discussion in
 	// https://github.com/tannerhelland/PhotoDemon/issues/343
 
-	// "PNG" format.
-	var pngContentBuffer bytes.Buffer
-	_ = png.Encode(&pngContentBuffer, img)
-	pngContent := pngContentBuffer.Bytes()
-	pngData, err := bytesToGlobalAlloc(&pngContent[0], len(pngContent))
+	// Encoded format: "PNG", "JFIF" or "GIF".
+	registeredFormat, found := registeredImageFormats[mimeType]
+	if !found {
+		var pngContentBuffer bytes.Buffer
+		_ = png.Encode(&pngContentBuffer, img)
+		registeredFormat, content = "PNG", pngContentBuffer.Bytes()
+	}
+	encodedData, err := bytesToGlobalAlloc(&content[0], len(content))
 	if err != nil {
 		return err
 	}
 	defer func() {
-		if pngData != 0 {
-			win.GlobalFree(pngData)
+		if encodedData != 0 {
+			win.GlobalFree(encodedData)
 		}
 	}()
 
@@ -90,14 +112,110 @@ func CopyImage(img image.Image) error {
 	err = safeSetClipboardData([]formatAndData{
 		{Format: win.CF_DIB, Data: win.HANDLE(dibV5Data)},
 		//{Format: win.CF_DIBV5, Data: win.HANDLE(dibV5Data)},  // Chromium (Chrome/Edge) does not support dibV5 :(
-		{RegisteredFormat: "PNG", Data: win.HANDLE(pngData)},
+		{RegisteredFormat: registeredFormat, Data: win.HANDLE(encodedData)},
 		{RegisteredFormat: "HTML Format", Data: win.HANDLE(htmlData)},
 	})
 	dibV5Data = 0
-	pngData = 0
+	encodedData = 0
 	return err
 }
 
+// PasteImage 读取剪贴板中的图片，优先使用 "PNG" 格式(保留透明度)，否则读取 CF_DIB 位图
+func PasteImage() (image.Image, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	err := waitOpenClipboard()
+	if err != nil {
+		return nil, err
+	}
+	defer win.CloseClipboard()
+
+	if pngFormat := registerClipboardFormat("PNG"); win.IsClipboardFormatAvailable(pngFormat) {
+		content, err := globalBytes(win.GetClipboardData(pngFormat))
+		if err != nil {
+			return nil, err
+		}
+		return png.Decode(bytes.NewReader(content))
+	}
+	if win.IsClipboardFormatAvailable(win.CF_DIB) {
+		content, err := globalBytes(win.GetClipboardData(win.CF_DIB))
+		if err != nil {
+			return nil, err
+		}
+		return imageFromDIB(content)
+	}
+	return nil, errors.New("clipboard has no image")
+}
+
+// globalBytes 复制剪贴板返回的全局内存
+func globalBytes(handle win.HANDLE) ([]byte, error) {
+	if handle == 0 {
+		return nil, errors.New("failed win.GetClipboardData()")
+	}
+	size, _, _ := procGlobalSize.Call(uintptr(handle))
+	data := win.GlobalLock(win.HGLOBAL(handle))
+	if data == nil {
+		return nil, errors.New("call to GlobalLock failed")
+	}
+	defer win.GlobalUnlock(win.HGLOBAL(handle))
+	return C.GoBytes(data, C.int(size)), nil
+}
+
+// imageFromDIB 解析 CF_DIB 格式: BITMAPINFOHEADER 加上像素，只支持 24 位和 32 位非压缩的位图
+func imageFromDIB(content []byte) (image.Image, error) {
+	if len(content) < 40 {
+		return nil, errors.New("invalid DIB in clipboard")
+	}
+	headerSize := int(binary.LittleEndian.Uint32(content[0:4]))
+	width := int(int32(binary.LittleEndian.Uint32(content[4:8])))
+	height := int(int32(binary.LittleEndian.Uint32(content[8:12])))
+	bitCount := int(binary.LittleEndian.Uint16(content[14:16]))
+	compression := binary.LittleEndian.Uint32(content[16:20])
+	if (bitCount != 24 && bitCount != 32) || (compression != win.BI_RGB && compression != win.BI_BITFIELDS) {
+		return nil, fmt.Errorf("unsupported DIB in clipboard: %d bits, compression %d", bitCount, compression)
+	}
+	offset := headerSize
+	if compression == win.BI_BITFIELDS && headerSize == 40 {
+		offset += 12 // 三个颜色掩码
+	}
+	bottomUp := height > 0
+	if !bottomUp {
+		height = -height
+	}
+	pixelSize := bitCount / 8
+	stride := (width*pixelSize + 3) &^ 3
+	if width <= 0 || len(content) < offset+stride*height {
+		return nil, errors.New("truncated DIB in clipboard")
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	hasAlpha := false
+	for y := 0; y < height; y++ {
+		row := y
+		if bottomUp {
+			row = height - 1 - y
+		}
+		line := content[offset+row*stride:]
+		for x := 0; x < width; x++ {
+			p := line[x*pixelSize:]
+			c := color.NRGBA{R: p[2], G: p[1], B: p[0], A: 0xFF}
+			if pixelSize == 4 {
+				c.A = p[3]
+				hasAlpha = hasAlpha || p[3] != 0
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	if pixelSize == 4 && !hasAlpha {
+		// 大部分程序复制的 32 位位图不使用 alpha 通道
+		for ii := 3; ii < len(img.Pix); ii += 4 {
+			img.Pix[ii] = 0xFF
+		}
+	}
+	return img, nil
+}
+
 func CopyText(text string) error {
 	glog.V(2).Infof("CopyText(%q)", text)
 	cStr := C.CString(text)
@@ -7,9 +7,14 @@ import (
 	"bytes"
 	"errors"
 	"github.com/golang/glog"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
 	"image"
+	_ "image/gif"
+	_ "image/jpeg"
 	"image/png"
 	"sync"
+	"time"
 	"unsafe"
 )
 
@@ -55,14 +60,34 @@ var (
 	hasClipboardOwnership bool
 	currentContent        []byte
 	currentContentTarget  C.Atom
+
+	// 读取剪贴板: 同一时间只能有一个读取请求，结果通过 pasteResults 返回
+	pasteLock     sync.Mutex
+	pasteResults  = make(chan pasteResult, 1)
+	atomPaste     C.Atom
+	pasteDeadline = 3 * time.Second
+
+	// 正在进行的读取请求的状态，PasteImage 和 x11EventLoop 在不同的 goroutine 中访问，使用 pasteStateLock 保护
+	pasteStateLock sync.Mutex
+	pasting        bool
+	pasteIncr      bool
+	pasteBuffer    []byte
 )
 
+type pasteResult struct {
+	content []byte
+	err     error
+}
+
 func CopyImage(img image.Image) error {
 	var contentBuffer bytes.Buffer
 	png.Encode(&contentBuffer, img)
-	content := contentBuffer.Bytes()
+	return CopyEncodedImage(img, contentBuffer.Bytes(), ImageTarget)
+}
 
-	glog.V(2).Infof("CopyImage(%d bytes)", len(content))
+// CopyEncodedImage 复制已经按照 mimeType 编码的图片 content 到剪贴板，其它程序通过 mimeType 读取
+func CopyEncodedImage(_ image.Image, content []byte, mimeType string) error {
+	glog.V(2).Infof("CopyEncodedImage(%d bytes of %s)", len(content), mimeType)
 	clipboardOnce.Do(func() { initX11() })
 	if failure != nil {
 		glog.Errorf("clipboard.CopyImage: %s", failure)
@@ -71,11 +96,50 @@ func CopyImage(img image.Image) error {
 
 	C.XSetSelectionOwner(display, atomClipboardSelection, window, C.CurrentTime)
 	currentContent = content
-	currentContentTarget = atomPNGTarget
+	currentContentTarget = getAtomFromName(mimeType)
 	hasClipboardOwnership = true
 	return nil
 }
 
+// PasteImage 读取剪贴板中的 PNG 图片
+func PasteImage() (image.Image, error) {
+	clipboardOnce.Do(func() { initX11() })
+	if failure != nil {
+		glog.Errorf("clipboard.PasteImage: %s", failure)
+		return nil, failure
+	}
+	if hasClipboardOwnership && currentContentTarget != atomTextTargets[0] {
+		// 剪贴板中是我们自己复制的图片，可能不是 PNG 格式
+		img, _, err := image.Decode(bytes.NewReader(currentContent))
+		return img, err
+	}
+
+	pasteLock.Lock()
+	defer pasteLock.Unlock()
+	select {
+	case <-pasteResults: // 丢弃之前超时的请求的结果
+	default:
+	}
+	pasteStateLock.Lock()
+	pasting, pasteIncr, pasteBuffer = true, false, nil
+	pasteStateLock.Unlock()
+	C.XConvertSelection(display, atomClipboardSelection, atomPNGTarget, atomPaste, window, C.CurrentTime)
+	C.XFlush(display)
+	select {
+	case result := <-pasteResults:
+		if result.err != nil {
+			return nil, result.err
+		}
+		glog.V(2).Infof("PasteImage(): %d bytes", len(result.content))
+		return png.Decode(bytes.NewReader(result.content))
+	case <-time.After(pasteDeadline):
+		pasteStateLock.Lock()
+		pasting, pasteIncr, pasteBuffer = false, false, nil
+		pasteStateLock.Unlock()
+		return nil, errors.New("timeout reading image from clipboard")
+	}
+}
+
 func CopyText(text string) error {
 	glog.V(2).Infof("CopyText(%d bytes)", len(text))
 	clipboardOnce.Do(func() { initX11() })
@@ -110,6 +174,7 @@ func initX11() {
 	}
 	atomIncr = getAtomFromName("INCR")
 	atomTargets = getAtomFromName("TARGETS")
+	atomPaste = getAtomFromName("FIRESHOTGO_PASTE")
 	glog.V(2).Infof("- Atom for \"image/png\" target=%+v", atomPNGTarget)
 
 	xExtendedMaxRequestSize := C.macro_XExtendedMaxRequestSize(display)
@@ -146,7 +211,15 @@ func x11EventLoop() {
 			}
 
 		case PropertyNotifyEventType:
-			handleSelectionRequest(xev)
+			if (*C.XPropertyEvent)(unsafe.Pointer(xev)).window == window {
+				// 我们自己窗口的属性: 分段读取剪贴板
+				handlePasteProperty(xev)
+			} else {
+				handleSelectionRequest(xev)
+			}
+
+		case SelectionNotifyEventType:
+			handleSelectionNotify(xev)
 
 		case SelectionClearEventType:
 			glog.V(2).Infof("We lost clipboard ownership")
@@ -253,6 +326,68 @@ func handleSelectionRequest(xev *C.XEvent) {
 	}
 }
 
+// handleSelectionNotify 剪贴板的所有者已经把内容写到了我们窗口的 atomPaste 属性中
+func handleSelectionNotify(xev *C.XEvent) {
+	selEv := (*C.XSelectionEvent)(unsafe.Pointer(xev))
+	pasteStateLock.Lock()
+	defer pasteStateLock.Unlock()
+	if !pasting {
+		return
+	}
+	if selEv.property == C.None {
+		finishPaste(nil, errors.New("clipboard has no image"))
+		return
+	}
+	content, propType := readPasteProperty()
+	if propType == atomIncr {
+		// 内容太大，所有者会分段发送，每次我们删除属性之后发送下一段
+		pasteIncr = true
+		return
+	}
+	finishPaste(content, nil)
+}
+
+// handlePasteProperty 分段读取: 每次属性写入新的一段，长度为 0 表示结束
+func handlePasteProperty(xev *C.XEvent) {
+	propEv := (*C.XPropertyEvent)(unsafe.Pointer(xev))
+	pasteStateLock.Lock()
+	defer pasteStateLock.Unlock()
+	if !pasting || !pasteIncr || propEv.atom != atomPaste || propEv.state != C.PropertyNewValue {
+		return
+	}
+	content, _ := readPasteProperty()
+	if len(content) == 0 {
+		finishPaste(pasteBuffer, nil)
+		return
+	}
+	pasteBuffer = append(pasteBuffer, content...)
+}
+
+// readPasteProperty 读取并删除我们窗口上的 atomPaste 属性
+func readPasteProperty() (content []byte, propType C.Atom) {
+	var format C.int
+	var numItems, bytesAfter C.ulong
+	var data *C.uchar
+	C.XGetWindowProperty(display, window, atomPaste, 0, C.long(0x1FFFFFFF), C.True, C.AnyPropertyType,
+		&propType, &format, &numItems, &bytesAfter, &data)
+	if data != nil {
+		defer C.XFree(unsafe.Pointer(data))
+		if format == 8 {
+			content = C.GoBytes(unsafe.Pointer(data), C.int(numItems))
+		}
+	}
+	return
+}
+
+// finishPaste 结束读取请求并返回结果，调用时需要持有 pasteStateLock
+func finishPaste(content []byte, err error) {
+	pasting, pasteIncr, pasteBuffer = false, false, nil
+	select {
+	case pasteResults <- pasteResult{content: content, err: err}:
+	default:
+	}
+}
+
 var liveRequests = make(map[C.Window]*RequestHandler)
 
 type RequestHandler struct {
@@ -306,12 +441,12 @@ func (r *RequestHandler) SendContentPart() bool {
 	}
 	glog.V(2).Infof("SendContentPart(): %d bytes missing, sending %d.", missing, amount)
 	if amount > 0 {
-		C.XChangeProperty(display, r.win, r.property, atomPNGTarget,
+		C.XChangeProperty(display, r.win, r.property, r.target,
 			/* byte */ 8, C.PropModeReplace,
 			(*C.uchar)(unsafe.Pointer(&r.content[r.position])), C.int(amount))
 	} else {
 		// Signals end of transfer.
-		C.XChangeProperty(display, r.win, r.property, atomPNGTarget,
+		C.XChangeProperty(display, r.win, r.property, r.target,
 			/* byte */ 8, C.PropModeReplace, nil, 0)
 	}
 	C.XFlush(display)
@@ -5,10 +5,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"gitee.com/andrewgithub/FireShotGo/encoder"
 	"github.com/golang/glog"
 	"google.golang.org/api/googleapi"
 	"image"
-	"image/png"
 	"net/http"
 	"os/exec"
 	"runtime"
@@ -137,21 +137,24 @@ func (m *Manager) getTokenFromWeb() (*oauth2.Token, error) {
 	return tok, nil
 }
 
-// ShareImage will create
-func (m *Manager) ShareImage(ctx context.Context, name string, img image.Image) (url string, err error) {
+// ShareImage will create a file named `name` plus the extension of the format in `opts`,
+// with the image encoded accordingly, and return a link to it readable by anyone.
+func (m *Manager) ShareImage(ctx context.Context, name string, img image.Image, opts encoder.Options) (url string, err error) {
 	parentId, err := m.createPath(ctx)
 	if err != nil {
 		return "", err
 	}
 
-	// Create PNG content of the image.
+	// Encode the image in the selected format.
 	var contentBuffer bytes.Buffer
-	_ = png.Encode(&contentBuffer, img)
+	if err = encoder.Encode(&contentBuffer, img, opts); err != nil {
+		return "", fmt.Errorf("failed to encode image: %w", err)
+	}
 	content := contentBuffer.Bytes()
 
 	f := &drive.File{
-		MimeType: "image/png",
-		Name:     name + ".png",
+		MimeType: opts.Format.MimeType(),
+		Name:     name + opts.Format.Extension(),
 		Parents:  []string{parentId},
 	}
 	f, err = m.service.Files.Create(f).
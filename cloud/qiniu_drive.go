@@ -3,12 +3,12 @@ package cloud
 import (
 	osbytes "bytes"
 	"context"
+	"gitee.com/andrewgithub/FireShotGo/encoder"
 	"github.com/golang/glog"
 	"github.com/qiniu/go-sdk/v7/auth/qbox"
 	"github.com/qiniu/go-sdk/v7/sms/bytes"
 	"github.com/qiniu/go-sdk/v7/storage"
 	"image"
-	"image/png"
 )
 
 // MyPutRet 自定义返回值结构体
@@ -38,9 +38,10 @@ func NewQiNiu(accesskey string, secretKey string, bucket string) (*QiNiuManager,
 	return m, nil
 }
 
-// QiNiuShareImage 将图片发送到七牛云上，需要传入图片名图片内容，目前仅支持网络浏览友好的png后期有需要可以扩展
+// QiNiuShareImage 将图片发送到七牛云上，需要传入图片名图片内容，图片按照 opts 中的格式编码，
+// filename 的扩展名需要和格式一致。返回编码时优化前后的大小。
 // Beta版本仅支持上传华东地区，其他地区上传有点慢，杭州或者上海这边的上传速度会快一些
-func (qiNiuManager *QiNiuManager) QiNiuShareImage(filename string, img image.Image) error {
+func (qiNiuManager *QiNiuManager) QiNiuShareImage(filename string, img image.Image, opts encoder.Options) (encoder.Report, error) {
 
 	// bucket 就是个人空间下创建的文件夹
 	putPolicy := storage.PutPolicy{
@@ -69,21 +70,24 @@ func (qiNiuManager *QiNiuManager) QiNiuShareImage(filename string, img image.Ima
 		},
 	}
 
-	// Create PNG content of the image.
+	// 按照选择的格式编码
 	var contentBuffer osbytes.Buffer
-	_ = png.Encode(&contentBuffer, img)
+	report, err := encoder.EncodeReport(&contentBuffer, img, opts)
+	if err != nil {
+		return report, err
+	}
 	content := contentBuffer.Bytes()
 
 	dataLen := int64(len(content))
 
 	// key文件名称
-	err := formUploader.Put(context.Background(), &ret, upToken, filename, bytes.NewReader(content), dataLen, &putExtra)
+	err = formUploader.Put(context.Background(), &ret, upToken, filename, bytes.NewReader(content), dataLen, &putExtra)
 	if err != nil {
 		glog.V(2).Infoln(err.Error())
-		return err
+		return report, err
 	}
 	//fmt.Println(filename)
 	// 记录截取图片的信息
 	glog.V(2).Infoln(ret, filename)
-	return nil
+	return report, nil
 }
@@ -0,0 +1,161 @@
+// Package collage 将多张截图排列成一张图片(网格、横排或者竖排)，用于在问题报告中并排展示几张截图。
+//
+// 这里只负责排列和绘制图片，标题的位置由 Layout 返回，由调用者绘制(例如作为可以编辑的文本标注)。
+package collage
+
+import (
+	xdraw "golang.org/x/image/draw"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Arrangement 图片的排列方式
+type Arrangement int
+
+const (
+	// Grid 按照 Options.Columns 列的网格排列
+	Grid Arrangement = iota
+	// Row 排成一行
+	Row
+	// Column 排成一列
+	Column
+)
+
+// Options 排列的参数
+type Options struct {
+	Arrangement Arrangement
+	// Columns 网格的列数，0 时自动选择(接近正方形)
+	Columns int
+	// Spacing 图片之间以及四周的间隔
+	Spacing int
+	// Background 背景颜色
+	Background color.Color
+	// CaptionHeight 每张图片下方留给标题的高度，0 表示没有标题
+	CaptionHeight int
+	// SameSize 缩放到同样的大小: 横排时高度相同，网格和竖排时宽度相同，以最小的图片为准
+	SameSize bool
+}
+
+// Cell 一张图片在合成之后的位置
+type Cell struct {
+	// Image 图片(缩放之后)的位置
+	Image image.Rectangle
+	// Caption 标题的位置，在图片的下方，没有标题时为空
+	Caption image.Rectangle
+}
+
+// columns 每一行的图片数
+func (o Options) columns(n int) int {
+	switch o.Arrangement {
+	case Row:
+		return n
+	case Column:
+		return 1
+	}
+	if o.Columns > 0 {
+		return o.Columns
+	}
+	return int(math.Ceil(math.Sqrt(float64(n))))
+}
+
+// scaledSizes 按照 SameSize 缩放之后的大小
+func (o Options) scaledSizes(sizes []image.Point) []image.Point {
+	scaled := append([]image.Point(nil), sizes...)
+	if !o.SameSize || len(sizes) == 0 {
+		return scaled
+	}
+	byHeight := o.Arrangement == Row
+	target := math.MaxInt32
+	for _, s := range sizes {
+		v := s.X
+		if byHeight {
+			v = s.Y
+		}
+		if v > 0 && v < target {
+			target = v
+		}
+	}
+	for ii, s := range sizes {
+		if s.X <= 0 || s.Y <= 0 {
+			continue
+		}
+		if byHeight {
+			scaled[ii] = image.Point{X: int(math.Round(float64(s.X) * float64(target) / float64(s.Y))), Y: target}
+		} else {
+			scaled[ii] = image.Point{X: target, Y: int(math.Round(float64(s.Y) * float64(target) / float64(s.X)))}
+		}
+	}
+	return scaled
+}
+
+// Layout 计算大小为 sizes 的图片排列之后的位置以及合成的图片的大小。
+// 每一列的宽度和每一行的高度由其中最大的图片决定，图片在自己的格子中居中。
+func Layout(sizes []image.Point, opts Options) (size image.Point, cells []Cell) {
+	n := len(sizes)
+	if n == 0 {
+		return image.Point{}, nil
+	}
+	scaled := opts.scaledSizes(sizes)
+	cols := opts.columns(n)
+	rows := (n + cols - 1) / cols
+	colW, rowH := make([]int, cols), make([]int, rows)
+	for ii, s := range scaled {
+		col, row := ii%cols, ii/cols
+		if s.X > colW[col] {
+			colW[col] = s.X
+		}
+		if s.Y > rowH[row] {
+			rowH[row] = s.Y
+		}
+	}
+
+	colX, rowY := make([]int, cols), make([]int, rows)
+	x := opts.Spacing
+	for col, w := range colW {
+		colX[col] = x
+		x += w + opts.Spacing
+	}
+	y := opts.Spacing
+	for row, h := range rowH {
+		rowY[row] = y
+		y += h + opts.CaptionHeight + opts.Spacing
+	}
+
+	cells = make([]Cell, n)
+	for ii, s := range scaled {
+		col, row := ii%cols, ii/cols
+		min := image.Point{X: colX[col] + (colW[col]-s.X)/2, Y: rowY[row] + (rowH[row]-s.Y)/2}
+		cells[ii].Image = image.Rectangle{Min: min, Max: min.Add(s)}
+		if opts.CaptionHeight > 0 {
+			top := rowY[row] + rowH[row]
+			cells[ii].Caption = image.Rect(colX[col], top, colX[col]+colW[col], top+opts.CaptionHeight)
+		}
+	}
+	return image.Point{X: x, Y: y}, cells
+}
+
+// Compose 按照 Layout 将 images 画到使用 Background 填充的新图片上，返回图片以及每张图片的位置
+func Compose(images []image.Image, opts Options) (*image.RGBA, []Cell) {
+	sizes := make([]image.Point, len(images))
+	for ii, img := range images {
+		sizes[ii] = img.Bounds().Size()
+	}
+	size, cells := Layout(sizes, opts)
+	out := image.NewRGBA(image.Rectangle{Max: size})
+	background := opts.Background
+	if background == nil {
+		background = color.Transparent
+	}
+	draw.Draw(out, out.Rect, image.NewUniform(background), image.Point{}, draw.Src)
+	for ii, img := range images {
+		bounds, target := img.Bounds(), cells[ii].Image
+		if target.Size() == bounds.Size() {
+			draw.Draw(out, target, img, bounds.Min, draw.Over)
+		} else {
+			xdraw.CatmullRom.Scale(out, target, img, bounds, draw.Over, nil)
+		}
+	}
+	return out, cells
+}
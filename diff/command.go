@@ -0,0 +1,107 @@
+package diff
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"os"
+)
+
+// 命令行的退出码
+const (
+	// ExitSame 没有差异，或者差异没有超过阈值
+	ExitSame = 0
+	// ExitDifferent 差异超过阈值
+	ExitDifferent = 1
+	// ExitError 参数错误或者读写图片失败
+	ExitError = 2
+)
+
+// Main 在命令行中比较两张截图，不需要启动界面，用于在持续集成中检查界面的变化:
+//
+//	fireshotgo diff [-threshold 0.1] [-max-ratio 0] [-out diff.png] before.png after.png
+//
+// 图片大小不同，或者变化的像素的比例超过 -max-ratio 时认为有差异。
+// 返回进程的退出码(ExitSame、ExitDifferent 或者 ExitError)。
+func Main(args []string, stdout, stderr io.Writer) int {
+	opts := DefaultOptions
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Float64Var(&opts.Threshold, "threshold", opts.Threshold, "颜色差异的容差 [0, 1]")
+	flags.BoolVar(&opts.Perceptual, "perceptual", opts.Perceptual, "使用感知的颜色差异，false 时比较各个通道的差异")
+	flags.IntVar(&opts.AlignRadius, "align", opts.AlignRadius, "对齐时查找的最大平移(像素)，0 表示不对齐")
+	flags.IntVar(&opts.MergeDistance, "merge", opts.MergeDistance, "距离在这个范围(像素)以内的变化合并成一个区域")
+	flags.IntVar(&opts.MinPixels, "min-pixels", opts.MinPixels, "变化的像素少于这个数的区域忽略")
+	maxRatio := flags.Float64("max-ratio", 0, "允许变化的像素的比例 [0, 1]，超过时退出码为 1")
+	out := flags.String("out", "", "将差异图保存为 PNG")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(stderr, "Usage: fireshotgo diff [flags] before.png after.png\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return ExitError
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return ExitError
+	}
+
+	var images [2]image.Image
+	for ii, name := range flags.Args() {
+		img, err := readImage(name)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "Failed to read %q: %s\n", name, err)
+			return ExitError
+		}
+		images[ii] = img
+	}
+
+	res := Compare(images[0], images[1], opts)
+	_, _ = fmt.Fprintf(stdout, "%d changed pixels (%.3f%%) in %d regions, offset (%d, %d)\n",
+		res.Changed, 100*res.Ratio(), len(res.Regions), res.Offset.X, res.Offset.Y)
+	if res.SizeChanged() {
+		_, _ = fmt.Fprintf(stdout, "Size changed from %d x %d to %d x %d\n",
+			res.BeforeSize.X, res.BeforeSize.Y, res.AfterSize.X, res.AfterSize.Y)
+	}
+	for _, region := range res.Regions {
+		r := region.Rect
+		_, _ = fmt.Fprintf(stdout, "  {%d, %d} - {%d, %d}, %d x %d, %d pixels\n",
+			r.Min.X, r.Min.Y, r.Max.X, r.Max.Y, r.Dx(), r.Dy(), region.Pixels)
+	}
+	if *out != "" {
+		if err := writePNG(*out, res.Highlight(images[0])); err != nil {
+			_, _ = fmt.Fprintf(stderr, "Failed to write %q: %s\n", *out, err)
+			return ExitError
+		}
+	}
+	if res.SizeChanged() || len(res.Regions) > 0 && res.Ratio() > *maxRatio {
+		return ExitDifferent
+	}
+	return ExitSame
+}
+
+func readImage(name string) (image.Image, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+func writePNG(name string, img image.Image) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
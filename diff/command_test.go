@@ -0,0 +1,99 @@
+package diff
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestImage 在 dir 中保存 w x h 的白色图片，changed 中的区域填充为黑色，返回文件名
+func writeTestImage(t *testing.T, dir, name string, w, h int, changed ...image.Rectangle) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Rect, image.NewUniform(color.White), image.Point{}, draw.Src)
+	for _, r := range changed {
+		draw.Draw(img, r, image.NewUniform(color.Black), image.Point{}, draw.Src)
+	}
+	path := filepath.Join(dir, name)
+	if err := writePNG(path, img); err != nil {
+		t.Fatalf("writePNG(%q): %v", path, err)
+	}
+	return path
+}
+
+func TestMainExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	before := writeTestImage(t, dir, "before.png", 100, 80)
+	same := writeTestImage(t, dir, "same.png", 100, 80)
+	// 10 x 10 的变化，占全部像素的 1.25%
+	changed := writeTestImage(t, dir, "changed.png", 100, 80, image.Rect(40, 30, 50, 40))
+	resized := writeTestImage(t, dir, "resized.png", 120, 80)
+	notImage := filepath.Join(dir, "not_image.png")
+	if err := os.WriteFile(notImage, []byte("not a png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"same", []string{before, same}, ExitSame},
+		{"changed", []string{"-align", "0", before, changed}, ExitDifferent},
+		{"within max ratio", []string{"-align", "0", "-max-ratio", "0.02", before, changed}, ExitSame},
+		{"above max ratio", []string{"-align", "0", "-max-ratio", "0.01", before, changed}, ExitDifferent},
+		{"size changed", []string{before, resized}, ExitDifferent},
+		{"size changed with max ratio", []string{"-max-ratio", "1", before, resized}, ExitDifferent},
+		{"no arguments", nil, ExitError},
+		{"one image", []string{before}, ExitError},
+		{"three images", []string{before, same, changed}, ExitError},
+		{"unknown flag", []string{"-unknown", before, same}, ExitError},
+		{"missing file", []string{before, filepath.Join(dir, "missing.png")}, ExitError},
+		{"not an image", []string{notImage, before}, ExitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if got := Main(tt.args, &stdout, &stderr); got != tt.want {
+				t.Errorf("Main(%q) = %d, want %d\nstdout: %s\nstderr: %s",
+					tt.args, got, tt.want, stdout.String(), stderr.String())
+			}
+		})
+	}
+}
+
+func TestMainOutput(t *testing.T) {
+	dir := t.TempDir()
+	before := writeTestImage(t, dir, "before.png", 100, 80)
+	changed := writeTestImage(t, dir, "changed.png", 100, 80, image.Rect(40, 30, 50, 40))
+	out := filepath.Join(dir, "diff.png")
+
+	var stdout, stderr bytes.Buffer
+	if got := Main([]string{"-align", "0", "-out", out, before, changed}, &stdout, &stderr); got != ExitDifferent {
+		t.Fatalf("Main() = %d, want %d, stderr: %s", got, ExitDifferent, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "100 changed pixels") || !strings.Contains(stdout.String(), "in 1 regions") {
+		t.Errorf("Main() printed %q, want 100 changed pixels in 1 region", stdout.String())
+	}
+
+	img, err := readImage(out)
+	if err != nil {
+		t.Fatalf("readImage(%q): %v", out, err)
+	}
+	if got := img.Bounds().Size(); got != (image.Point{X: 100, Y: 80}) {
+		t.Errorf("diff image size = %v, want 100 x 80", got)
+	}
+
+	// 不能写入时返回 ExitError
+	stdout.Reset()
+	stderr.Reset()
+	badOut := filepath.Join(dir, "missing", "diff.png")
+	if got := Main([]string{"-out", badOut, before, changed}, &stdout, &stderr); got != ExitError {
+		t.Errorf("Main(-out %q) = %d, want %d", badOut, got, ExitError)
+	}
+}
@@ -0,0 +1,359 @@
+// Package diff 比较两张截图(例如发布前后的界面): 先对齐，再逐像素或者按照感知的颜色差异比较，
+// 最后把变化的像素合并成矩形区域。界面和命令行(参考 Main)使用同样的实现。
+package diff
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+)
+
+// Options 比较的参数
+type Options struct {
+	// Threshold 颜色差异的容差 [0, 1]，差异不超过它的像素认为没有变化
+	Threshold float64
+	// Perceptual 使用 YIQ 色彩空间中的感知差异，否则使用各个通道差异的最大值
+	Perceptual bool
+	// AlignRadius 对齐时查找的最大平移(像素)，0 表示不对齐
+	AlignRadius int
+	// MergeDistance 距离在这个范围(像素)以内的变化合并成一个区域
+	MergeDistance int
+	// MinPixels 变化的像素少于这个数的区域忽略，用于过滤噪点
+	MinPixels int
+}
+
+// DefaultOptions 默认的参数，适合比较同一个界面的两次截图
+var DefaultOptions = Options{Threshold: 0.1, Perceptual: true, AlignRadius: 32, MergeDistance: 8, MinPixels: 4}
+
+// Region 变化的区域
+type Region struct {
+	// Rect 包含区域中所有变化像素的矩形，使用 Result.Bounds 的坐标
+	Rect image.Rectangle
+	// Pixels 区域中变化的像素数
+	Pixels int
+}
+
+// Result 比较的结果。坐标以 before 为准(左上角是 (0, 0))，after 平移 Offset 之后和 before 对齐。
+type Result struct {
+	// Offset after 中的像素 p 对应 before 中的 p + Offset
+	Offset image.Point
+	// BeforeSize, AfterSize 两张图片的大小
+	BeforeSize, AfterSize image.Point
+	// Bounds 比较的范围: before 和平移之后的 after 重叠的部分。
+	// 对齐时平移出来的边缘不算作变化，图片大小不同参考 SizeChanged。
+	Bounds image.Rectangle
+	// Mask 变化的像素是 0xFF，大小和 Bounds 相同
+	Mask *image.Gray
+	// Changed 变化的像素数
+	Changed int
+	// Regions 变化的区域，按照从上到下、从左到右排列
+	Regions []Region
+}
+
+// SizeChanged 两张图片的大小是否不同
+func (r *Result) SizeChanged() bool {
+	return r.BeforeSize != r.AfterSize
+}
+
+// View 同时包含两张图片的范围，Layout 和 Highlight 返回的图片的大小
+func (r *Result) View() image.Rectangle {
+	before := image.Rectangle{Max: r.BeforeSize}
+	after := image.Rectangle{Min: r.Offset, Max: r.Offset.Add(r.AfterSize)}
+	return before.Union(after)
+}
+
+// Ratio 变化的像素占比较范围的比例 [0, 1]
+func (r *Result) Ratio() float64 {
+	total := r.Bounds.Dx() * r.Bounds.Dy()
+	if total == 0 {
+		return 0
+	}
+	return float64(r.Changed) / float64(total)
+}
+
+// Compare 比较 before 和 after
+func Compare(before, after image.Image, opts Options) *Result {
+	a, b := toNRGBA(before), toNRGBA(after)
+	res := &Result{BeforeSize: a.Rect.Size(), AfterSize: b.Rect.Size()}
+	if opts.AlignRadius > 0 {
+		res.Offset = Align(a, b, opts.AlignRadius)
+	}
+	res.Bounds = a.Rect.Intersect(b.Rect.Add(res.Offset))
+	res.Mask = image.NewGray(res.Bounds)
+
+	distance := plainDistance
+	if opts.Perceptual {
+		distance = perceptualDistance
+	}
+	for y := res.Bounds.Min.Y; y < res.Bounds.Max.Y; y++ {
+		for x := res.Bounds.Min.X; x < res.Bounds.Max.X; x++ {
+			if distance(a.NRGBAAt(x, y), b.NRGBAAt(x-res.Offset.X, y-res.Offset.Y)) > opts.Threshold {
+				res.Mask.Pix[res.Mask.PixOffset(x, y)] = 0xFF
+				res.Changed++
+			}
+		}
+	}
+	res.Regions = regions(res.Mask, opts.MergeDistance, opts.MinPixels)
+	return res
+}
+
+// toNRGBA 复制 img，坐标从 (0, 0) 开始
+func toNRGBA(img image.Image) *image.NRGBA {
+	bounds := img.Bounds()
+	out := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(out, out.Rect, img, bounds.Min, draw.Src)
+	return out
+}
+
+// onWhite 将半透明的颜色叠加到白色上，返回 [0, 255] 的 r, g, b
+func onWhite(c color.NRGBA) (r, g, b float64) {
+	a := float64(c.A) / 0xFF
+	blend := func(v uint8) float64 { return 0xFF + (float64(v)-0xFF)*a }
+	return blend(c.R), blend(c.G), blend(c.B)
+}
+
+// plainDistance 各个通道(包括 alpha)差异的最大值 [0, 1]
+func plainDistance(c1, c2 color.NRGBA) float64 {
+	d := func(v1, v2 uint8) float64 { return math.Abs(float64(v1) - float64(v2)) }
+	return math.Max(math.Max(d(c1.R, c2.R), d(c1.G, c2.G)), math.Max(d(c1.B, c2.B), d(c1.A, c2.A))) / 0xFF
+}
+
+// maxYIQDelta 黑色和白色之间的 YIQ 差异，用于将感知差异归一化到 [0, 1]
+const maxYIQDelta = 35215
+
+// perceptualDistance YIQ 色彩空间中加权的颜色差异 [0, 1]，亮度的权重最高，
+// 参考 Kotsarenko 和 Ramos 的 "Measuring perceived color difference using YIQ NTSC transmission color space"
+func perceptualDistance(c1, c2 color.NRGBA) float64 {
+	if c1 == c2 {
+		return 0
+	}
+	r1, g1, b1 := onWhite(c1)
+	r2, g2, b2 := onWhite(c2)
+	dr, dg, db := r1-r2, g1-g2, b1-b2
+	y := dr*0.29889531 + dg*0.58662247 + db*0.11448223
+	i := dr*0.59597799 - dg*0.27417610 - db*0.32180189
+	q := dr*0.21147017 - dg*0.52261711 + db*0.31114694
+	return math.Sqrt((0.5053*y*y + 0.299*i*i + 0.1957*q*q) / maxYIQDelta)
+}
+
+// gray 转换成亮度图
+func gray(img *image.NRGBA) *image.Gray {
+	out := image.NewGray(img.Rect)
+	draw.Draw(out, out.Rect, img, img.Rect.Min, draw.Src)
+	return out
+}
+
+// shrink 将亮度图缩小到 1/factor，每个像素是对应区域的平均值
+func shrink(img *image.Gray, factor int) *image.Gray {
+	w, h := img.Rect.Dx()/factor, img.Rect.Dy()/factor
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sum := 0
+			for dy := 0; dy < factor; dy++ {
+				row := img.PixOffset(x*factor, y*factor+dy)
+				for _, v := range img.Pix[row : row+factor] {
+					sum += int(v)
+				}
+			}
+			out.Pix[out.PixOffset(x, y)] = uint8(sum / (factor * factor))
+		}
+	}
+	return out
+}
+
+// alignCost 平移 offset 之后重叠部分的平均亮度差，每隔 stride 个像素取一个。
+// 重叠部分小于较小的图片的一半时返回 +Inf，避免选择只有很小一部分重叠的平移。
+func alignCost(a, b *image.Gray, offset image.Point, stride int) float64 {
+	overlap := a.Rect.Intersect(b.Rect.Add(offset))
+	minArea := math.Min(float64(a.Rect.Dx()*a.Rect.Dy()), float64(b.Rect.Dx()*b.Rect.Dy())) / 2
+	if overlap.Empty() || float64(overlap.Dx()*overlap.Dy()) < minArea {
+		return math.Inf(1)
+	}
+	sum, count := 0, 0
+	for y := overlap.Min.Y; y < overlap.Max.Y; y += stride {
+		rowA := a.PixOffset(overlap.Min.X, y)
+		rowB := b.PixOffset(overlap.Min.X-offset.X, y-offset.Y)
+		for x := 0; x < overlap.Dx(); x += stride {
+			d := int(a.Pix[rowA+x]) - int(b.Pix[rowB+x])
+			if d < 0 {
+				d = -d
+			}
+			sum += d
+			count++
+		}
+	}
+	return float64(sum) / float64(count)
+}
+
+// bestOffset 在 center 周围 radius 以内查找差异最小的平移，差异相同时选择离 center 最近的
+func bestOffset(a, b *image.Gray, center image.Point, radius, stride int) image.Point {
+	best, bestCost := center, alignCost(a, b, center, stride)
+	for d := 1; d <= radius; d++ {
+		for dy := -d; dy <= d; dy++ {
+			for dx := -d; dx <= d; dx++ {
+				if dx != -d && dx != d && dy != -d && dy != d {
+					// 只检查距离为 d 的一圈
+					continue
+				}
+				offset := center.Add(image.Point{X: dx, Y: dy})
+				if cost := alignCost(a, b, offset, stride); cost < bestCost {
+					best, bestCost = offset, cost
+				}
+			}
+		}
+	}
+	return best
+}
+
+// alignSize 对齐时先缩小到这个大小以内查找，再在原图上细调
+const alignSize = 512
+
+// Align 查找 after 相对于 before 的平移(各个方向不超过 radius)，使重叠部分的亮度差最小。
+// 返回的 offset 使 after 中的像素 p 对应 before 中的 p + offset。
+func Align(before, after image.Image, radius int) image.Point {
+	a, b := gray(toNRGBA(before)), gray(toNRGBA(after))
+	factor := 1
+	for (a.Rect.Dx()/factor > alignSize || a.Rect.Dy()/factor > alignSize) && radius/factor > 2 {
+		factor *= 2
+	}
+	if factor == 1 {
+		return bestOffset(a, b, image.Point{}, radius, 1)
+	}
+	coarse := bestOffset(shrink(a, factor), shrink(b, factor), image.Point{}, radius/factor, 1)
+	return bestOffset(a, b, coarse.Mul(factor), factor, 2)
+}
+
+// regions 将 mask 中变化的像素按照 merge 大小的格子合并成区域: 相邻(包括对角)的有变化的格子属于同一个区域
+func regions(mask *image.Gray, merge, minPixels int) []Region {
+	if merge < 1 {
+		merge = 1
+	}
+	bounds := mask.Rect
+	cols, rows := (bounds.Dx()+merge-1)/merge, (bounds.Dy()+merge-1)/merge
+	type cell struct {
+		rect   image.Rectangle
+		pixels int
+	}
+	cells := make([]cell, cols*rows)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if mask.Pix[mask.PixOffset(x, y)] == 0 {
+				continue
+			}
+			c := &cells[(y-bounds.Min.Y)/merge*cols+(x-bounds.Min.X)/merge]
+			pixel := image.Rect(x, y, x+1, y+1)
+			if c.pixels == 0 {
+				c.rect = pixel
+			} else {
+				c.rect = c.rect.Union(pixel)
+			}
+			c.pixels++
+		}
+	}
+
+	var found []Region
+	visited := make([]bool, len(cells))
+	for start := range cells {
+		if visited[start] || cells[start].pixels == 0 {
+			continue
+		}
+		region := Region{Rect: cells[start].rect}
+		stack := []int{start}
+		visited[start] = true
+		for len(stack) > 0 {
+			idx := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			region.Rect = region.Rect.Union(cells[idx].rect)
+			region.Pixels += cells[idx].pixels
+			cx, cy := idx%cols, idx/cols
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := cx+dx, cy+dy
+					if nx < 0 || ny < 0 || nx >= cols || ny >= rows {
+						continue
+					}
+					if n := ny*cols + nx; !visited[n] && cells[n].pixels > 0 {
+						visited[n] = true
+						stack = append(stack, n)
+					}
+				}
+			}
+		}
+		if region.Pixels >= minPixels {
+			found = append(found, region)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		ri, rj := found[i].Rect, found[j].Rect
+		if ri.Min.Y != rj.Min.Y {
+			return ri.Min.Y < rj.Min.Y
+		}
+		return ri.Min.X < rj.Min.X
+	})
+	return found
+}
+
+// Layout 将 before 和 after 按照对齐的位置画到和 View 一样大小的图片中(左上角是 (0, 0))，用于并排显示和叠加显示
+func (r *Result) Layout(before, after image.Image) (a, b *image.RGBA) {
+	view := r.View()
+	size := image.Rect(0, 0, view.Dx(), view.Dy())
+	a, b = image.NewRGBA(size), image.NewRGBA(size)
+	bb, ab := before.Bounds(), after.Bounds()
+	at := view.Min.Mul(-1)
+	draw.Draw(a, image.Rectangle{Min: at, Max: at.Add(bb.Size())}, before, bb.Min, draw.Src)
+	at = r.Offset.Sub(view.Min)
+	draw.Draw(b, image.Rectangle{Min: at, Max: at.Add(ab.Size())}, after, ab.Min, draw.Src)
+	return
+}
+
+var (
+	// ChangedColor 差异图中变化的像素的颜色
+	ChangedColor = color.RGBA{R: 0xFF, A: 0xFF}
+	// RegionColor 差异图中变化区域的边框颜色
+	RegionColor = color.RGBA{R: 0xFF, G: 0x8C, A: 0xFF}
+)
+
+// Highlight 差异图(和 Layout 的坐标相同): 淡化的 before 灰度图，变化的像素使用 ChangedColor，
+// 并用 RegionColor 框出变化的区域
+func (r *Result) Highlight(before image.Image) *image.RGBA {
+	a, _ := r.Layout(before, image.NewRGBA(image.Rectangle{}))
+	out := image.NewRGBA(a.Rect)
+	origin := r.View().Min
+	for y := 0; y < a.Rect.Dy(); y++ {
+		for x := 0; x < a.Rect.Dx(); x++ {
+			p := image.Point{X: x, Y: y}.Add(origin)
+			if p.In(r.Bounds) && r.Mask.Pix[r.Mask.PixOffset(p.X, p.Y)] != 0 {
+				out.SetRGBA(x, y, ChangedColor)
+				continue
+			}
+			rr, gg, bb := onWhite(color.NRGBAModel.Convert(a.RGBAAt(x, y)).(color.NRGBA))
+			// 灰度之后淡化到 10%，只用于辨认位置
+			v := uint8(0xFF - (0xFF-(0.299*rr+0.587*gg+0.114*bb))*0.1)
+			pos := out.PixOffset(x, y)
+			out.Pix[pos], out.Pix[pos+1], out.Pix[pos+2], out.Pix[pos+3] = v, v, v, 0xFF
+		}
+	}
+	for _, region := range r.Regions {
+		rect := region.Rect.Sub(origin).Inset(-2)
+		for ii := 0; ii < 2; ii++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				setIn(out, x, rect.Min.Y+ii, RegionColor)
+				setIn(out, x, rect.Max.Y-1-ii, RegionColor)
+			}
+			for y := rect.Min.Y; y < rect.Max.Y; y++ {
+				setIn(out, rect.Min.X+ii, y, RegionColor)
+				setIn(out, rect.Max.X-1-ii, y, RegionColor)
+			}
+		}
+	}
+	return out
+}
+
+func setIn(img *image.RGBA, x, y int, c color.RGBA) {
+	if (image.Point{X: x, Y: y}).In(img.Rect) {
+		img.SetRGBA(x, y, c)
+	}
+}
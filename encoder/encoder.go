@@ -0,0 +1,219 @@
+// Package encoder 保存、复制到剪贴板和上传截图时使用的图片格式以及编码参数。
+//
+// 只支持有纯 Go 编码器的格式。WebP 目前没有纯 Go 的编码器(golang.org/x/image/webp 只能解码)，所以不在支持的列表中。
+package encoder
+
+import (
+	"fmt"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// Format 图片格式
+type Format string
+
+const (
+	PNG  Format = "PNG"
+	JPEG Format = "JPEG"
+	GIF  Format = "GIF"
+	BMP  Format = "BMP"
+	TIFF Format = "TIFF"
+)
+
+// Formats 支持的格式，按照界面上显示的顺序
+var Formats = []Format{PNG, JPEG, GIF, BMP, TIFF}
+
+// formatInfo 格式的文件扩展名(第一个是默认的)和 MIME 类型
+var formatInfo = map[Format]struct {
+	extensions []string
+	mimeType   string
+}{
+	PNG:  {[]string{".png"}, "image/png"},
+	JPEG: {[]string{".jpg", ".jpeg"}, "image/jpeg"},
+	GIF:  {[]string{".gif"}, "image/gif"},
+	BMP:  {[]string{".bmp"}, "image/bmp"},
+	TIFF: {[]string{".tiff", ".tif"}, "image/tiff"},
+}
+
+// Extension 返回格式默认的文件扩展名，包含 "."
+func (f Format) Extension() string {
+	if info, found := formatInfo[f]; found {
+		return info.extensions[0]
+	}
+	return ".png"
+}
+
+// MimeType 返回格式的 MIME 类型
+func (f Format) MimeType() string {
+	if info, found := formatInfo[f]; found {
+		return info.mimeType
+	}
+	return "image/png"
+}
+
+// FromExtension 通过文件扩展名(例如 ".jpg"，不区分大小写)查找格式
+func FromExtension(ext string) (Format, bool) {
+	ext = strings.ToLower(ext)
+	for _, f := range Formats {
+		for _, e := range formatInfo[f].extensions {
+			if e == ext {
+				return f, true
+			}
+		}
+	}
+	return "", false
+}
+
+// PNGCompression PNG 的压缩级别
+type PNGCompression int
+
+// PNGCompressionNames PNG 压缩级别的显示名称，下标和 PNGCompression 的取值一一对应
+var PNGCompressionNames = []string{"默认", "不压缩", "最快", "最小"}
+
+// pngLevels 每个 PNGCompression 对应的 png.CompressionLevel
+var pngLevels = []png.CompressionLevel{png.DefaultCompression, png.NoCompression, png.BestSpeed, png.BestCompression}
+
+// String implements fmt.Stringer.
+func (c PNGCompression) String() string {
+	if c < 0 || int(c) >= len(PNGCompressionNames) {
+		return "未知"
+	}
+	return PNGCompressionNames[c]
+}
+
+// ParsePNGCompression 通过显示名称查找压缩级别，找不到返回默认级别
+func ParsePNGCompression(name string) PNGCompression {
+	for ii, n := range PNGCompressionNames {
+		if n == name {
+			return PNGCompression(ii)
+		}
+	}
+	return 0
+}
+
+// Options 编码参数，只有和 Format 对应的参数会被使用
+type Options struct {
+	Format Format
+
+	// PNGCompression PNG 的压缩级别
+	PNGCompression PNGCompression
+
+	// PNGOptimize 保存 PNG 之前进行优化: 颜色少时无损地转换成调色板图片，并选择最好的压缩
+	PNGOptimize bool
+
+	// PNGQuantize 颜色超过 256 种时有损地量化到 PNGQuantizeColors 种颜色，只在 PNGOptimize 时有效。
+	// PNGDither 量化时是否使用抖动。
+	PNGQuantize       bool
+	PNGQuantizeColors int
+	PNGDither         bool
+
+	// JPEGQuality JPEG 的质量，取值 [1, 100]
+	JPEGQuality int
+
+	// GIFColors GIF 调色板的颜色数，取值 [2, 256]
+	GIFColors int
+
+	// TIFFDeflate TIFF 是否使用 Deflate 压缩
+	TIFFDeflate bool
+
+	// StripMetadata 不在 PNG 中写入任何附加数据(截图时间、屏幕、标注等)，用于保护隐私
+	StripMetadata bool
+
+	// Chunks 写入 PNG 的附加数据块，由保存文件的调用者设置，不保存在设置中
+	Chunks []Chunk `json:"-"`
+}
+
+// DefaultOptions 默认参数: PNG 默认压缩，进行无损优化
+func DefaultOptions() Options {
+	return Options{Format: PNG, PNGOptimize: true, PNGQuantizeColors: 256, PNGDither: true,
+		JPEGQuality: 90, GIFColors: 256, TIFFDeflate: true}
+}
+
+// WithFormat 返回修改了格式的参数，其它参数不变
+func (o Options) WithFormat(f Format) Options {
+	o.Format = f
+	return o
+}
+
+// Encode 按照 opts 将 img 编码写入 w
+func Encode(w io.Writer, img image.Image, opts Options) error {
+	_, err := EncodeReport(w, img, opts)
+	return err
+}
+
+// EncodeReport 与 Encode 相同，同时返回优化前后的字节数
+func EncodeReport(w io.Writer, img image.Image, opts Options) (Report, error) {
+	if opts.Format == PNG || opts.Format == "" {
+		return encodePNG(w, img, opts)
+	}
+	counter := &countingWriter{w: w}
+	err := encode(counter, img, opts)
+	return Report{Before: counter.n, After: counter.n}, err
+}
+
+// encode 编码 PNG 以外的格式
+func encode(w io.Writer, img image.Image, opts Options) error {
+	switch opts.Format {
+	case JPEG:
+		// JPEG 不支持透明，透明的部分(例如美化之后的圆角)显示为白色
+		return jpeg.Encode(w, opaque(img), &jpeg.Options{Quality: clamp(opts.JPEGQuality, 1, 100)})
+	case GIF:
+		return gif.Encode(w, img, &gif.Options{NumColors: clamp(opts.GIFColors, 2, 256)})
+	case BMP:
+		return bmp.Encode(w, img)
+	case TIFF:
+		compression := tiff.Uncompressed
+		if opts.TIFFDeflate {
+			compression = tiff.Deflate
+		}
+		return tiff.Encode(w, img, &tiff.Options{Compression: compression, Predictor: opts.TIFFDeflate})
+	}
+	return fmt.Errorf("unsupported image format %q", opts.Format)
+}
+
+// EncodedSize 返回 img 按照 opts 编码之后(以及优化之前)的字节数，用于预览文件大小
+func EncodedSize(img image.Image, opts Options) (Report, error) {
+	return EncodeReport(io.Discard, img, opts)
+}
+
+// countingWriter 记录写入的字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// opaque 将 img 叠加到白色背景上，img 本身不会被修改
+func opaque(img image.Image) image.Image {
+	if o, ok := img.(interface{ Opaque() bool }); ok && o.Opaque() {
+		return img
+	}
+	bounds := img.Bounds()
+	out := image.NewRGBA(bounds)
+	draw.Draw(out, bounds, &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(out, bounds, img, bounds.Min, draw.Over)
+	return out
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,219 @@
+package encoder
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Chunk PNG 的一个附加数据块，例如 tEXt / iTXt 文本，或者自定义的私有数据块
+type Chunk struct {
+	// Type 4 个字母的数据块类型
+	Type string
+	Data []byte
+}
+
+// compressTextAbove 超过这个长度的文本使用压缩的 iTXt
+const compressTextAbove = 1024
+
+// TextChunk 创建文本数据块: 内容是 Latin-1 并且较短时使用 tEXt，否则使用 iTXt(UTF-8，较长时压缩)。
+// keyword 必须是 1~79 个 Latin-1 字符。
+func TextChunk(keyword, text string) Chunk {
+	if isLatin1(text) && len(text) <= compressTextAbove {
+		data := append(latin1(keyword), 0)
+		return Chunk{Type: "tEXt", Data: append(data, latin1(text)...)}
+	}
+	// iTXt: keyword, 0, 压缩标记, 压缩方法, 语言, 0, 翻译的 keyword, 0, 文本
+	data := append(latin1(keyword), 0)
+	content := []byte(text)
+	if len(content) > compressTextAbove {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		_, _ = zw.Write(content)
+		_ = zw.Close()
+		data = append(data, 1, 0)
+		content = buf.Bytes()
+	} else {
+		data = append(data, 0, 0)
+	}
+	data = append(data, 0, 0)
+	return Chunk{Type: "iTXt", Data: append(data, content...)}
+}
+
+// TextChunks 返回数据块中的所有文本，按照 keyword 索引，支持 tEXt、zTXt 和 iTXt
+func TextChunks(chunks []Chunk) map[string]string {
+	texts := make(map[string]string)
+	for _, c := range chunks {
+		keyword, text, err := parseText(c)
+		if err != nil || keyword == "" {
+			continue
+		}
+		texts[keyword] = text
+	}
+	return texts
+}
+
+// parseText 解析文本数据块，不是文本的数据块返回空的 keyword
+func parseText(c Chunk) (keyword, text string, err error) {
+	sep := bytes.IndexByte(c.Data, 0)
+	if sep < 0 {
+		return "", "", nil
+	}
+	keyword, rest := fromLatin1(c.Data[:sep]), c.Data[sep+1:]
+	switch c.Type {
+	case "tEXt":
+		return keyword, fromLatin1(rest), nil
+	case "zTXt":
+		if len(rest) < 1 {
+			return "", "", fmt.Errorf("truncated zTXt chunk")
+		}
+		content, err := inflate(rest[1:])
+		return keyword, fromLatin1(content), err
+	case "iTXt":
+		if len(rest) < 2 {
+			return "", "", fmt.Errorf("truncated iTXt chunk")
+		}
+		compressed := rest[0] == 1
+		rest = rest[2:]
+		// 跳过语言和翻译的 keyword
+		for ii := 0; ii < 2; ii++ {
+			sep := bytes.IndexByte(rest, 0)
+			if sep < 0 {
+				return "", "", fmt.Errorf("truncated iTXt chunk")
+			}
+			rest = rest[sep+1:]
+		}
+		if compressed {
+			rest, err = inflate(rest)
+		}
+		return keyword, string(rest), err
+	}
+	return "", "", nil
+}
+
+// pngHeader PNG 文件的开头
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+// InsertChunks 将 chunks 插入到 PNG 文件的 IHDR 之后，返回新的文件内容
+func InsertChunks(content []byte, chunks []Chunk) ([]byte, error) {
+	if !bytes.HasPrefix(content, pngHeader) {
+		return nil, fmt.Errorf("not a PNG file")
+	}
+	// 签名 + IHDR (长度 4 + 类型 4 + 数据 13 + CRC 4)
+	ihdrEnd := len(pngHeader) + 25
+	if len(content) < ihdrEnd || string(content[len(pngHeader)+4:len(pngHeader)+8]) != "IHDR" {
+		return nil, fmt.Errorf("PNG file doesn't start with IHDR")
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(content))
+	buf.Write(content[:ihdrEnd])
+	for _, c := range chunks {
+		if err := writeChunk(&buf, c); err != nil {
+			return nil, err
+		}
+	}
+	buf.Write(content[ihdrEnd:])
+	return buf.Bytes(), nil
+}
+
+// ReadChunks 返回 PNG 文件中的附加数据块(不包括 IHDR、PLTE、IDAT、IEND 等图片数据)。
+// 不是 PNG 的文件返回 nil。
+func ReadChunks(content []byte) ([]Chunk, error) {
+	if !bytes.HasPrefix(content, pngHeader) {
+		return nil, nil
+	}
+	var chunks []Chunk
+	rest := content[len(pngHeader):]
+	for len(rest) >= 12 {
+		length := binary.BigEndian.Uint32(rest[:4])
+		if uint64(length)+12 > uint64(len(rest)) {
+			return chunks, fmt.Errorf("truncated PNG chunk")
+		}
+		kind := string(rest[4:8])
+		data := rest[8 : 8+length]
+		if crc32.ChecksumIEEE(rest[4:8+length]) != binary.BigEndian.Uint32(rest[8+length:12+length]) {
+			return chunks, fmt.Errorf("invalid CRC in PNG chunk %q", kind)
+		}
+		rest = rest[12+length:]
+		switch kind {
+		case "IHDR", "PLTE", "IDAT", "tRNS":
+			continue
+		case "IEND":
+			return chunks, nil
+		}
+		chunks = append(chunks, Chunk{Type: kind, Data: append([]byte(nil), data...)})
+	}
+	return chunks, nil
+}
+
+func writeChunk(w io.Writer, c Chunk) error {
+	if len(c.Type) != 4 {
+		return fmt.Errorf("invalid PNG chunk type %q", c.Type)
+	}
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(c.Data)))
+	copy(header[4:], c.Type)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(c.Data)
+	var footer [4]byte
+	binary.BigEndian.PutUint32(footer[:], crc.Sum32())
+	for _, part := range [][]byte{header[:], c.Data, footer[:]} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func inflate(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+func isLatin1(s string) bool {
+	for _, r := range s {
+		if r > 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+func latin1(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff {
+			r = '?'
+		}
+		out = append(out, byte(r))
+	}
+	return out
+}
+
+func fromLatin1(b []byte) string {
+	if isASCII(b) {
+		return string(b)
+	}
+	runes := make([]rune, len(b))
+	for ii, c := range b {
+		runes[ii] = rune(c)
+	}
+	return string(runes)
+}
+
+func isASCII(b []byte) bool {
+	for _, c := range b {
+		if c >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package encoder
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// testPNG 一张 4x3 的 PNG
+func testPNG(t *testing.T) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	img.Set(1, 2, color.RGBA{R: 0xFF, A: 0xFF})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestChunksRoundTrip 插入的数据块可以原样读回，文本可以解析，图片仍然可以解码
+func TestChunksRoundTrip(t *testing.T) {
+	texts := map[string]string{
+		"Software":         "FireShotGo",
+		"Title":            "Café",                             // Latin-1: tEXt
+		"Comment":          "中文标题",                             // UTF-8: iTXt
+		"FireShotGo:Large": strings.Repeat("annotation ", 500), // 压缩的 iTXt
+	}
+	var chunks []Chunk
+	for _, keyword := range []string{"Software", "Title", "Comment", "FireShotGo:Large"} {
+		chunks = append(chunks, TextChunk(keyword, texts[keyword]))
+	}
+	chunks = append(chunks, Chunk{Type: "fsOR", Data: []byte{0, 1, 2, 0xFF}})
+
+	content, err := InsertChunks(testPNG(t), chunks)
+	if err != nil {
+		t.Fatalf("InsertChunks() failed: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("PNG with inserted chunks doesn't decode: %v", err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 4, 3) {
+		t.Errorf("decoded image has bounds %v", img.Bounds())
+	}
+
+	read, err := ReadChunks(content)
+	if err != nil {
+		t.Fatalf("ReadChunks() failed: %v", err)
+	}
+	if len(read) != len(chunks) {
+		t.Fatalf("ReadChunks() returned %d chunks, want %d", len(read), len(chunks))
+	}
+	for ii := range chunks {
+		if read[ii].Type != chunks[ii].Type || !bytes.Equal(read[ii].Data, chunks[ii].Data) {
+			t.Errorf("chunk #%d: got %q, want %q", ii, read[ii].Type, chunks[ii].Type)
+		}
+	}
+	if got := read[1].Type; got != "tEXt" {
+		t.Errorf("Latin-1 text stored as %s, want tEXt", got)
+	}
+	if got := read[2].Type; got != "iTXt" {
+		t.Errorf("UTF-8 text stored as %s, want iTXt", got)
+	}
+	parsed := TextChunks(read)
+	for keyword, want := range texts {
+		if got := parsed[keyword]; got != want {
+			t.Errorf("TextChunks()[%q] = %.40q, want %.40q", keyword, got, want)
+		}
+	}
+}
+
+// TestChunksErrors 不是 PNG 或者损坏的文件
+func TestChunksErrors(t *testing.T) {
+	if _, err := InsertChunks([]byte("GIF89a"), nil); err == nil {
+		t.Error("InsertChunks() on a GIF succeeded, want an error")
+	}
+	if _, err := InsertChunks(testPNG(t), []Chunk{{Type: "toolong", Data: nil}}); err == nil {
+		t.Error("InsertChunks() with an invalid chunk type succeeded, want an error")
+	}
+	if chunks, err := ReadChunks([]byte("GIF89a")); chunks != nil || err != nil {
+		t.Errorf("ReadChunks() on a GIF = %v, %v, want nil, nil", chunks, err)
+	}
+
+	content, err := InsertChunks(testPNG(t), []Chunk{TextChunk("Title", "broken")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 修改文本的内容，CRC 不再匹配
+	corrupted := bytes.Replace(content, []byte("broken"), []byte("BROKEN"), 1)
+	if _, err := ReadChunks(corrupted); err == nil {
+		t.Error("ReadChunks() with a bad CRC succeeded, want an error")
+	}
+	if _, err := ReadChunks(content[:len(content)-20]); err == nil {
+		t.Error("ReadChunks() of a truncated file succeeded, want an error")
+	}
+}
@@ -0,0 +1,257 @@
+package encoder
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"sort"
+)
+
+// Report 编码的结果: 优化前后的字节数。没有优化时两者相同。
+type Report struct {
+	Before, After int64
+}
+
+// Saved 返回优化减少的比例，取值 [0, 1]
+func (r Report) Saved() float64 {
+	if r.Before <= 0 || r.After >= r.Before {
+		return 0
+	}
+	return 1 - float64(r.After)/float64(r.Before)
+}
+
+// encodePNG 编码 PNG，开启 PNGOptimize 时尝试以下方法并选择最小的结果:
+//
+//   - 颜色不超过 256 种时(界面截图通常如此)无损地转换成调色板图片
+//   - 颜色更多并且开启了 PNGQuantize 时，有损地量化到 PNGQuantizeColors 种颜色，可以选择抖动
+//   - 使用最高的压缩级别
+//
+// 优化前的大小是按照 PNGCompression 直接编码的大小。opts.Chunks 在优化之后插入，前后的大小都包含附加数据。
+func encodePNG(w io.Writer, img image.Image, opts Options) (Report, error) {
+	level := png.DefaultCompression
+	if opts.PNGCompression >= 0 && int(opts.PNGCompression) < len(pngLevels) {
+		level = pngLevels[opts.PNGCompression]
+	}
+	var baseline bytes.Buffer
+	if err := (&png.Encoder{CompressionLevel: level}).Encode(&baseline, img); err != nil {
+		return Report{}, err
+	}
+	best := baseline.Bytes()
+
+	if opts.PNGOptimize {
+		candidates := []image.Image{img}
+		if paletted := exactPalette(img); paletted != nil {
+			candidates = []image.Image{paletted}
+		} else if opts.PNGQuantize {
+			candidates = append(candidates, quantize(img, clamp(opts.PNGQuantizeColors, 2, 256), opts.PNGDither))
+		}
+		for _, candidate := range candidates {
+			var optimized bytes.Buffer
+			if err := (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&optimized, candidate); err != nil {
+				return Report{}, err
+			}
+			if optimized.Len() < len(best) {
+				best = optimized.Bytes()
+			}
+		}
+	}
+	before := int64(baseline.Len())
+	if len(opts.Chunks) > 0 && !opts.StripMetadata {
+		withChunks, err := InsertChunks(best, opts.Chunks)
+		if err != nil {
+			return Report{}, err
+		}
+		before += int64(len(withChunks) - len(best))
+		best = withChunks
+	}
+	_, err := w.Write(best)
+	return Report{Before: before, After: int64(len(best))}, err
+}
+
+// exactPalette 颜色不超过 256 种时返回使用相同颜色的调色板图片，否则返回 nil
+func exactPalette(img image.Image) *image.Paletted {
+	bounds := img.Bounds()
+	indices := make(map[color.RGBA64]uint8, 256)
+	var palette color.Palette
+	pix := make([]uint8, 0, bounds.Dx()*bounds.Dy())
+	var last color.RGBA64
+	var lastIndex uint8
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			c := color.RGBA64{R: uint16(r), G: uint16(g), B: uint16(b), A: uint16(a)}
+			// 截图中相邻的像素通常颜色相同，不需要每次都查找 map
+			if len(palette) > 0 && c == last {
+				pix = append(pix, lastIndex)
+				continue
+			}
+			index, found := indices[c]
+			if !found {
+				if len(palette) == 256 {
+					return nil
+				}
+				index = uint8(len(palette))
+				indices[c] = index
+				palette = append(palette, c)
+			}
+			last, lastIndex = c, index
+			pix = append(pix, index)
+		}
+	}
+	return &image.Paletted{Pix: pix, Stride: bounds.Dx(), Rect: bounds, Palette: palette}
+}
+
+// colorBucket 直方图中的一个颜色: 每个通道保留高 5 位，同时累计原始颜色用于计算平均值
+type colorBucket struct {
+	count      uint64
+	r, g, b, a uint64
+}
+
+// channel 返回 bucket 平均颜色的第 ch 个通道(0:R, 1:G, 2:B, 3:A)
+func (cb *colorBucket) channel(ch int) uint64 {
+	switch ch {
+	case 0:
+		return cb.r / cb.count
+	case 1:
+		return cb.g / cb.count
+	case 2:
+		return cb.b / cb.count
+	}
+	return cb.a / cb.count
+}
+
+// quantize 使用中位切分(median cut)生成 numColors 种颜色的调色板，将 img 转换成调色板图片。
+// dither 为 true 时使用 Floyd-Steinberg 抖动，渐变的区域看起来更平滑，但是压缩效果差一些。
+func quantize(img image.Image, numColors int, dither bool) *image.Paletted {
+	bounds := img.Bounds()
+	histogram := make(map[uint32]*colorBucket)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			key := r>>11<<15 | g>>11<<10 | b>>11<<5 | a>>11
+			cb := histogram[key]
+			if cb == nil {
+				cb = &colorBucket{}
+				histogram[key] = cb
+			}
+			cb.count++
+			cb.r += uint64(r)
+			cb.g += uint64(g)
+			cb.b += uint64(b)
+			cb.a += uint64(a)
+		}
+	}
+	buckets := make([]*colorBucket, 0, len(histogram))
+	for _, cb := range histogram {
+		buckets = append(buckets, cb)
+	}
+	palette := medianCut(buckets, numColors)
+
+	paletted := image.NewPaletted(bounds, palette)
+	if dither {
+		draw.FloydSteinberg.Draw(paletted, bounds, img, bounds.Min)
+		return paletted
+	}
+	// 不抖动: 每个颜色使用调色板中最接近的颜色，相同的颜色只查找一次
+	cache := make(map[color.RGBA64]uint8)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			c := color.RGBA64{R: uint16(r), G: uint16(g), B: uint16(b), A: uint16(a)}
+			index, found := cache[c]
+			if !found {
+				index = uint8(palette.Index(c))
+				cache[c] = index
+			}
+			paletted.Pix[paletted.PixOffset(x, y)] = index
+		}
+	}
+	return paletted
+}
+
+// medianCut 将颜色分成最多 numColors 组，每次把像素数乘以颜色范围最大的一组按照范围最大的通道从中位数切开，
+// 每一组的平均颜色作为调色板中的颜色
+func medianCut(buckets []*colorBucket, numColors int) color.Palette {
+	type box struct {
+		buckets []*colorBucket
+		count   uint64
+		// channel, span 范围最大的通道以及范围
+		channel int
+		span    uint64
+	}
+	newBox := func(buckets []*colorBucket) *box {
+		bx := &box{buckets: buckets}
+		var lo, hi [4]uint64
+		for ii := range lo {
+			lo[ii] = 1 << 16
+		}
+		for _, cb := range buckets {
+			bx.count += cb.count
+			for ch := 0; ch < 4; ch++ {
+				v := cb.channel(ch)
+				if v < lo[ch] {
+					lo[ch] = v
+				}
+				if v > hi[ch] {
+					hi[ch] = v
+				}
+			}
+		}
+		for ch := 0; ch < 4; ch++ {
+			if hi[ch]-lo[ch] > bx.span {
+				bx.channel, bx.span = ch, hi[ch]-lo[ch]
+			}
+		}
+		return bx
+	}
+
+	boxes := []*box{newBox(buckets)}
+	for len(boxes) < numColors {
+		// 选择需要切分的组
+		var target int = -1
+		var score uint64
+		for ii, bx := range boxes {
+			if len(bx.buckets) < 2 {
+				continue
+			}
+			if s := bx.count * bx.span; target < 0 || s > score {
+				target, score = ii, s
+			}
+		}
+		if target < 0 {
+			break
+		}
+		bx := boxes[target]
+		sort.Slice(bx.buckets, func(i, j int) bool {
+			return bx.buckets[i].channel(bx.channel) < bx.buckets[j].channel(bx.channel)
+		})
+		// 按照像素数的中位数切分，两边都至少保留一个颜色
+		half, acc, split := bx.count/2, uint64(0), 1
+		for ii, cb := range bx.buckets[:len(bx.buckets)-1] {
+			acc += cb.count
+			split = ii + 1
+			if acc >= half {
+				break
+			}
+		}
+		boxes[target] = newBox(bx.buckets[:split])
+		boxes = append(boxes, newBox(bx.buckets[split:]))
+	}
+
+	palette := make(color.Palette, len(boxes))
+	for ii, bx := range boxes {
+		var r, g, b, a uint64
+		for _, cb := range bx.buckets {
+			r += cb.r
+			g += cb.g
+			b += cb.b
+			a += cb.a
+		}
+		palette[ii] = color.RGBA64{
+			R: uint16(r / bx.count), G: uint16(g / bx.count), B: uint16(b / bx.count), A: uint16(a / bx.count)}
+	}
+	return palette
+}
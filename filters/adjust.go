@@ -0,0 +1,166 @@
+package filters
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// Adjustments 图像调整的参数，零值表示不做任何调整
+type Adjustments struct {
+	// Brightness 亮度 [-1, 1]
+	Brightness float64 `json:",omitempty"`
+	// Contrast 对比度 [-1, 1]
+	Contrast float64 `json:",omitempty"`
+	// Gamma 伽马校正，大于 1 变亮，0 按照 1 处理
+	Gamma float64 `json:",omitempty"`
+	// Saturation 饱和度 [-1, 1]，-1 是灰度
+	Saturation float64 `json:",omitempty"`
+	// Invert 反色
+	Invert bool `json:",omitempty"`
+	// Sharpen 锐化的强度 [0, 2]
+	Sharpen float64 `json:",omitempty"`
+}
+
+// IsZero 是否没有任何调整
+func (a Adjustments) IsZero() bool {
+	return a == Adjustments{} || a == Adjustments{Gamma: 1}
+}
+
+// lut 亮度、对比度、伽马和反色对每个通道的映射
+func (a Adjustments) lut() (table [256]uint8) {
+	gamma := a.Gamma
+	if gamma <= 0 {
+		gamma = 1
+	}
+	// 对比度 [-1, 1] 映射为 [0, +∞) 的斜率，0 对应斜率 1
+	slope := math.Tan((math.Min(a.Contrast, 0.99) + 1) * math.Pi / 4)
+	for ii := range table {
+		v := float64(ii)/0xFF + a.Brightness
+		v = (v-0.5)*slope + 0.5
+		v = math.Pow(math.Max(0, math.Min(1, v)), 1/gamma)
+		if a.Invert {
+			v = 1 - v
+		}
+		table[ii] = uint8(math.Round(v * 0xFF))
+	}
+	return
+}
+
+// everywhere 没有指定区域时调整整张图片，合成时和图片的区域相交
+var everywhere = image.Rect(-1<<30, -1<<30, 1<<30, 1<<30)
+
+// Adjust 调整下面的像素: 亮度、对比度、伽马、饱和度(灰度)、反色以及锐化。
+// 调整的是已经合成的结果，所以之前添加的标注也会被调整，之后添加的标注不受影响。
+type Adjust struct {
+	// Rect 调整的区域，为空时调整整张截图
+	Rect image.Rectangle
+
+	Adjustments
+
+	table [256]uint8
+
+	revision
+}
+
+// NewAdjust creates a new Adjust filter over rect (the whole image if rect is empty).
+func NewAdjust(rect image.Rectangle, adjustments Adjustments) *Adjust {
+	a := &Adjust{Rect: rect.Canon()}
+	a.SetAdjustments(adjustments)
+	return a
+}
+
+// SetRect 修改调整的区域，为空时调整整张截图
+func (a *Adjust) SetRect(rect image.Rectangle) {
+	a.touch()
+	a.Rect = rect.Canon()
+}
+
+// SetAdjustments 修改调整的参数
+func (a *Adjust) SetAdjustments(adjustments Adjustments) {
+	a.touch()
+	a.Adjustments = adjustments
+	a.table = adjustments.lut()
+}
+
+// Bounds implements the Adjuster interface.
+func (a *Adjust) Bounds() image.Rectangle {
+	if a.Rect.Empty() {
+		return everywhere
+	}
+	return a.Rect
+}
+
+// Margin implements the Adjuster interface: 锐化需要上下左右的像素
+func (a *Adjust) Margin() int {
+	if a.Sharpen > 0 {
+		return 1
+	}
+	return 0
+}
+
+// Adjust implements the Adjuster interface.
+func (a *Adjust) Adjust(dst, src *image.RGBA) {
+	k := a.Sharpen
+	saturation := 1 + a.Saturation
+	// channel 返回 src 中 (x, y) 的一个通道(非预乘)，超出 src 时使用最近的像素
+	channel := func(x, y, c int) float64 {
+		x = clampInt(x, src.Rect.Min.X, src.Rect.Max.X-1)
+		y = clampInt(y, src.Rect.Min.Y, src.Rect.Max.Y-1)
+		pos := src.PixOffset(x, y)
+		alpha := src.Pix[pos+3]
+		if alpha == 0 {
+			return 0
+		}
+		return float64(src.Pix[pos+c]) * 0xFF / float64(alpha)
+	}
+	for y := dst.Rect.Min.Y; y < dst.Rect.Max.Y; y++ {
+		for x := dst.Rect.Min.X; x < dst.Rect.Max.X; x++ {
+			var rgb [3]float64
+			for c := range rgb {
+				v := channel(x, y, c)
+				if k > 0 {
+					// 拉普拉斯锐化: 加上和上下左右四个像素的差
+					v += k * (4*v - channel(x-1, y, c) - channel(x+1, y, c) - channel(x, y-1, c) - channel(x, y+1, c))
+				}
+				rgb[c] = v
+			}
+			if saturation != 1 {
+				gray := 0.299*rgb[0] + 0.587*rgb[1] + 0.114*rgb[2]
+				for c := range rgb {
+					rgb[c] = gray + (rgb[c]-gray)*saturation
+				}
+			}
+			alpha := src.Pix[src.PixOffset(x, y)+3]
+			pos := dst.PixOffset(x, y)
+			for c, v := range rgb {
+				mapped := float64(a.table[uint8(math.Max(0, math.Min(0xFF, math.Round(v))))])
+				dst.Pix[pos+c] = uint8(math.Round(mapped * float64(alpha) / 0xFF))
+			}
+			dst.Pix[pos+3] = alpha
+		}
+	}
+}
+
+// Apply implements the Filter interface.
+func (a *Adjust) Apply(img image.Image) image.Image {
+	bounds := img.Bounds()
+	src := image.NewRGBA(bounds)
+	draw.Draw(src, bounds, img, bounds.Min, draw.Src)
+	dst := image.NewRGBA(bounds)
+	copy(dst.Pix, src.Pix)
+	if r := a.Bounds().Intersect(bounds); !r.Empty() {
+		a.Adjust(dst.SubImage(r).(*image.RGBA), src)
+	}
+	return dst
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,84 @@
+package filters
+
+import (
+	"image/color"
+)
+
+// AntiAliaser 可以关闭边缘抗锯齿的图形。
+// 关闭之后每个像素只判断像素本身的位置，边缘是锯齿状的，但是颜色都是精确的，适合需要精确到像素的场合。
+// 设置保存在每个滤镜中，合成时并行绘制的分块只读取滤镜自己的设置。
+type AntiAliaser interface {
+	SetAntiAlias(on bool)
+}
+
+// region 采样点相对图形的位置
+type region uint8
+
+const (
+	// outside 图形外部
+	outside region = iota
+	// inside 图形内部(包括虚线轮廓的间隔)，有填充颜色时使用填充颜色
+	inside
+	// onStroke 轮廓上
+	onStroke
+)
+
+// aaGrid 抗锯齿时每个像素的采样网格是 aaGrid x aaGrid
+const aaGrid = 4
+
+// aaCorners 采样网格的四个角，四个角和像素中心在同一个区域时认为整个像素都在这个区域，
+// 不需要再对整个网格采样
+var aaCorners = [4][2]float64{
+	{-0.5 + 0.5/aaGrid, -0.5 + 0.5/aaGrid},
+	{0.5 - 0.5/aaGrid, -0.5 + 0.5/aaGrid},
+	{-0.5 + 0.5/aaGrid, 0.5 - 0.5/aaGrid},
+	{0.5 - 0.5/aaGrid, 0.5 - 0.5/aaGrid},
+}
+
+// coverage 计算像素 (x, y) 中图形内部和轮廓所占的比例。
+// sample 判断某个点(可以是像素中间的位置)属于图形的哪个区域，antiAlias 为 false 时只判断像素中心。
+func coverage(x, y int, antiAlias bool, sample func(fx, fy float64) region) (inner, stroke float64) {
+	fx, fy := float64(x), float64(y)
+	center := sample(fx, fy)
+	uniform := true
+	if antiAlias {
+		for _, corner := range aaCorners {
+			if sample(fx+corner[0], fy+corner[1]) != center {
+				uniform = false
+				break
+			}
+		}
+	}
+	if uniform {
+		switch center {
+		case inside:
+			return 1, 0
+		case onStroke:
+			return 0, 1
+		}
+		return 0, 0
+	}
+
+	// 边缘上的像素: 对整个网格采样
+	var counts [3]int
+	for i := 0; i < aaGrid; i++ {
+		dy := (float64(i)+0.5)/aaGrid - 0.5
+		for j := 0; j < aaGrid; j++ {
+			dx := (float64(j)+0.5)/aaGrid - 0.5
+			counts[sample(fx+dx, fy+dy)]++
+		}
+	}
+	const total = aaGrid * aaGrid
+	return float64(counts[inside]) / total, float64(counts[onStroke]) / total
+}
+
+// shade 按照覆盖比例将填充颜色和轮廓颜色 strokeColor 叠加到 under 上
+func (s ShapeStyle) shade(under, strokeColor color.Color, inner, stroke float64) color.Color {
+	if inner > 0 && s.hasFill() {
+		under = blend(under, s.Fill, s.FillOpacity*inner)
+	}
+	if stroke > 0 {
+		under = blend(under, strokeColor, s.StrokeOpacity*stroke)
+	}
+	return under
+}
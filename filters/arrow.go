@@ -20,12 +20,23 @@ type Arrow struct {
 	// Thickness 箭头的宽度 实际像素点为 Thickness*arrowHeadWidthFactor
 	Thickness float64
 
+	// Caps 箭头两端的样式，默认只有终点 To 处有实心三角形
+	Caps LineCaps
+
+	// Style 不透明度以及线条主体的虚线样式，线条没有填充
+	Style ShapeStyle
+
 	// rect 箭头的矩形部分 (箭头 + 矩形线框组成一条箭头线)
 	rect image.Rectangle
 	// 现行[3x3]矩阵
 	rebaseMatrix mgl64.Mat3
 	// 箭头需要绘制的长度
 	vectorLength float64
+
+	// AntiAlias 是否对边缘做抗锯齿，创建时默认打开，参考 coverage
+	AntiAlias bool
+
+	revision
 }
 
 // arrowHeadLengthFactor 箭头长度预设
@@ -37,12 +48,20 @@ const (
 
 // NewArrow 创建一个箭头，必须传入起始点和颜色，以及线条的宽度
 func NewArrow(from, to image.Point, color color.Color, thickness float64) *Arrow {
-	c := &Arrow{Color: color, Thickness: thickness}
+	c := &Arrow{Color: color, Thickness: thickness, Caps: LineCaps{Head: CapTriangle}, Style: DefaultShapeStyle(),
+		AntiAlias: true}
 	c.SetPoints(from, to)
 	return c
 }
 
+// SetAntiAlias 打开或者关闭边缘的抗锯齿
+func (c *Arrow) SetAntiAlias(on bool) {
+	c.touch()
+	c.AntiAlias = on
+}
+
 func (c *Arrow) SetPoints(from, to image.Point) {
+	c.touch()
 	// 不支持点，最少绘制一个长度才能画出箭头
 	if to.X == from.X && to.Y == from.Y {
 		to.X += 1 // So that arrow is always at least 1 in size.
@@ -51,8 +70,8 @@ func (c *Arrow) SetPoints(from, to image.Point) {
 	c.From, c.To = from, to
 	// rect 中记录起始位置，位置信息时经过Canon Min.X < Max.X and Min.Y < Max.Y
 	c.rect = image.Rectangle{Min: from, Max: to}.Canon()
-	// 取出线宽，只要> 0.1就进位
-	arrowHeadExtraPixels := int(arrowHeadWidthFactor*c.Thickness + 0.99)
+	// 端点可能超出线段本身，包围矩形需要按照端点大小扩大
+	arrowHeadExtraPixels := c.Caps.extent(c.Thickness)
 	c.rect.Min.X -= arrowHeadExtraPixels
 	c.rect.Min.Y -= arrowHeadExtraPixels
 	c.rect.Max.X += arrowHeadExtraPixels
@@ -74,11 +93,37 @@ func (c *Arrow) SetPoints(from, to image.Point) {
 		mgl64.Translate2D(float64(-c.From.X), float64(-c.From.Y)))
 }
 
+// SetCaps 设置箭头两端的样式，可以是双箭头或者其他样式
+func (c *Arrow) SetCaps(caps LineCaps) {
+	c.Caps = caps
+	c.SetPoints(c.From, c.To)
+}
+
 var (
 	Yellow = color.RGBA{R: 255, G: 255, A: 255}
 	Green  = color.RGBA{R: 80, G: 255, A: 80}
 )
 
+// SetStyle 设置不透明度和虚线样式
+func (c *Arrow) SetStyle(style ShapeStyle) {
+	c.touch()
+	c.Style = style
+}
+
+// sample 判断点 (fx, fy) 是否在箭头上，线条没有内部区域
+func (c *Arrow) sample(fx, fy float64) region {
+	p := c.rebaseMatrix.Mul3x1(mgl64.Vec3{fx, fy, 1.0})
+	if c.Caps.contains(p.X(), p.Y(), c.vectorLength, c.Thickness) {
+		return onStroke
+	}
+	// 实心三角形的端点会占用线条的长度，线条主体只绘制剩余的部分
+	start, end := c.Caps.bodyRange(c.vectorLength, c.Thickness)
+	if p.X() >= start && p.X() < end && math.Abs(p.Y()) < c.Thickness/2 && c.Style.Dash.on(p.X()-start, c.Thickness) {
+		return onStroke
+	}
+	return outside
+}
+
 // at is the function given to the filterImage object.
 func (c *Arrow) at(x, y int, under color.Color) color.Color {
 	if x > c.rect.Max.X || x < c.rect.Min.X || y > c.rect.Max.Y || y < c.rect.Min.Y {
@@ -108,22 +153,18 @@ func (c *Arrow) at(x, y int, under color.Color) color.Color {
 		}
 	}
 
-	if homogPoint.X() < 0 {
-		return under
-	}
-	// arrowHeadLengthFactor*c.Thickness 是预留给剪头的长度
-	if homogPoint.X() < c.vectorLength-arrowHeadLengthFactor*c.Thickness {
-		if math.Abs(homogPoint.Y()) < c.Thickness/2 {
-			return c.Color
-		}
-	} else {
-		// 绘制剪头的地方，上面已经给剪头预留长度了，这里就按照剪头的长度绘制x轴和y轴，返回color的都会绘制成当先选中的颜色
-		// c.vectorLength-homogPoint.X() 的长度就是直线结束，剪头开始的地方的长度
-		if math.Abs(homogPoint.Y()) < (c.vectorLength-homogPoint.X())*arrowHeadWidthFactor/arrowHeadLengthFactor/2.0 {
-			return c.Color
-		}
-	}
-	return under
+	_, stroke := coverage(x, y, c.AntiAlias, c.sample)
+	return c.Style.shade(under, c.Color, 0, stroke)
+}
+
+// Bounds 返回箭头占用的区域，包括两端的端点
+func (c *Arrow) Bounds() image.Rectangle {
+	return image.Rectangle{Min: c.rect.Min, Max: c.rect.Max.Add(image.Pt(1, 1))}
+}
+
+// Rasterize implements the Layer interface.
+func (c *Arrow) Rasterize(dst *image.RGBA) {
+	rasterizeLine(dst, c.rebaseMatrix, float64(c.Caps.extent(c.Thickness))+1, c.at)
 }
 
 // Apply implements the ImageFilter interface.
@@ -3,6 +3,7 @@ package filters
 import (
 	"image"
 	"image/color"
+	"math"
 )
 
 type Circle struct {
@@ -16,23 +17,38 @@ type Circle struct {
 	// Thickness of the circle to be drawn.
 	Thickness float64
 
+	// Style 填充颜色、不透明度以及轮廓的虚线样式
+	Style ShapeStyle
+
 	// Center is generated automatically.
 	Center Vec2
 
 	// Internal dimensions.
 	innerRadius, outerRadius Vec2
+
+	// AntiAlias 是否对边缘做抗锯齿，创建时默认打开，参考 coverage
+	AntiAlias bool
+
+	revision
 }
 
 // NewCircle creates a new circle (or ellipsis) filter. It draws
 // an ellipsis whose dimensions fit the given rectangle.
 // You must specify the color and the thickness of the circle to be drawn.
 func NewCircle(dim image.Rectangle, color color.Color, thickness float64) *Circle {
-	c := &Circle{Color: color, Thickness: thickness}
+	c := &Circle{Color: color, Thickness: thickness, Style: DefaultShapeStyle(), AntiAlias: true}
 	c.SetDim(dim)
 	return c
 }
 
+// SetAntiAlias 打开或者关闭边缘的抗锯齿
+func (c *Circle) SetAntiAlias(on bool) {
+	c.touch()
+	c.AntiAlias = on
+}
+
 func (c *Circle) SetDim(dim image.Rectangle) {
+	c.touch()
 	c.Dim = dim
 	// 取出中心位置
 	center := c.Dim.Min.Add(c.Dim.Max).Div(2)
@@ -49,24 +65,53 @@ func (c *Circle) SetDim(dim image.Rectangle) {
 	}
 }
 
-// at is the function given to the filterImage object.
-func (c *Circle) at(x, y int, under color.Color) color.Color {
-	if x > c.Dim.Max.X || x < c.Dim.Min.X || y > c.Dim.Max.Y || y < c.Dim.Min.Y {
-		return under
-	}
+// SetStyle 设置填充、不透明度和虚线样式
+func (c *Circle) SetStyle(style ShapeStyle) {
+	c.touch()
+	c.Style = style
+}
 
-	oDx := (float64(x) - c.Center.X()) / c.outerRadius.X()
-	oDy := (float64(y) - c.Center.Y()) / c.outerRadius.Y()
+// Bounds 返回圆占用的区域，包括抗锯齿的边缘
+func (c *Circle) Bounds() image.Rectangle {
+	return image.Rectangle{Min: c.Dim.Min.Sub(image.Pt(1, 1)), Max: c.Dim.Max.Add(image.Pt(2, 2))}
+}
+
+// sample 判断点 (fx, fy) 在圆的哪个区域
+func (c *Circle) sample(fx, fy float64) region {
+	oDx := (fx - c.Center.X()) / c.outerRadius.X()
+	oDy := (fy - c.Center.Y()) / c.outerRadius.Y()
 	oDist := oDx*oDx + oDy*oDy
-	iDx := (float64(x) - c.Center.X()) / c.innerRadius.X()
-	iDy := (float64(y) - c.Center.Y()) / c.innerRadius.Y()
+	iDx := (fx - c.Center.X()) / c.innerRadius.X()
+	iDy := (fy - c.Center.Y()) / c.innerRadius.Y()
 	iDist := iDx*iDx + iDy*iDy
 
-	if oDist > 1 || iDist < 1 {
+	if oDist > 1 {
+		return outside
+	}
+	if iDist < 1 {
+		return inside
+	}
+	// 虚线按照角度近似计算轮廓上的弧长
+	angle := math.Atan2(oDy, oDx) + math.Pi
+	arcPos := angle * (c.outerRadius.X() + c.outerRadius.Y()) / 2
+	if !c.Style.Dash.on(arcPos, c.Thickness) {
+		return inside
+	}
+	return onStroke
+}
+
+// at is the function given to the filterImage object.
+func (c *Circle) at(x, y int, under color.Color) color.Color {
+	if x > c.Dim.Max.X+1 || x < c.Dim.Min.X-1 || y > c.Dim.Max.Y+1 || y < c.Dim.Min.Y-1 {
 		return under
 	}
+	inner, stroke := coverage(x, y, c.AntiAlias, c.sample)
+	return c.Style.shade(under, c.Color, inner, stroke)
+}
 
-	return c.Color
+// Rasterize implements the Layer interface.
+func (c *Circle) Rasterize(dst *image.RGBA) {
+	rasterize(dst, c.at)
 }
 
 // Apply implements the ImageFilter interface.
@@ -0,0 +1,211 @@
+package filters
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+)
+
+// Compositor 将原始截图和所有滤镜合成到一张图片上。
+//
+// 支持 Layer 的滤镜只在修改之后重新光栅化到自己的图层(只覆盖滤镜的区域)，
+// 每次合成只重新计算发生变化的分块(参考 TileSize): 和修改、新增或者删除的滤镜在修改前后占用的区域相交的分块，
+// 分块之间并行合成。
+// Adjuster 滤镜没有图层，在它的区域内根据下面已经合成的像素计算，下面的区域改变时一起重新计算。
+// 其它滤镜按照原来的方式逐像素计算，并且每次都需要重新合成整张图片。
+type Compositor struct {
+	mu sync.Mutex
+
+	base image.Image
+	out  *image.RGBA
+
+	// layers 缓存的图层，按照滤镜索引
+	layers map[Filter]*cachedLayer
+
+	// composed 上一次合成时的滤镜，用于比较得到需要重新合成的区域
+	composed []composedFilter
+}
+
+// cachedLayer 滤镜光栅化之后的图层
+type cachedLayer struct {
+	revision uint64
+	img      *image.RGBA
+	op       draw.Op
+}
+
+// composedFilter 记录合成时滤镜的状态
+type composedFilter struct {
+	filter   Filter
+	revision uint64
+	bounds   image.Rectangle
+	layered  bool
+	adjusted bool
+}
+
+// NewCompositor creates an empty compositor, the first call to Compose renders everything.
+func NewCompositor() *Compositor {
+	return &Compositor{}
+}
+
+// Compose 将 filters 按照顺序叠加到 base 上，返回重新合成的分块(base 的坐标)。
+// base 改变时(例如重新截图)会丢弃所有缓存并重新合成整张图片。
+func (c *Compositor) Compose(base image.Image, filters []Filter) []image.Rectangle {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	bounds := base.Bounds()
+	var changed []image.Rectangle
+	if base != c.base || c.out == nil {
+		c.base = base
+		c.out = image.NewRGBA(bounds)
+		c.layers = make(map[Filter]*cachedLayer)
+		c.composed = nil
+		changed = append(changed, bounds)
+	}
+
+	// spread[ii] 是从 ii 开始所有调整的 Margin() 之和: 需要周围像素的调整(例如锐化)
+	// 会把下面的变化向外扩散 Margin() 个像素，叠加的几个调整扩散的距离相加
+	spread := make([]int, len(filters)+1)
+	for ii := len(filters) - 1; ii >= 0; ii-- {
+		spread[ii] = spread[ii+1]
+		if adjuster, ok := filters[ii].(Adjuster); ok {
+			spread[ii] += adjuster.Margin()
+		}
+	}
+
+	grow := func(r image.Rectangle, ii int) image.Rectangle {
+		if r.Empty() {
+			return r
+		}
+		return r.Inset(-spread[ii])
+	}
+
+	composed := make([]composedFilter, len(filters))
+	layers := make(map[Filter]*cachedLayer, len(filters))
+	for ii, filter := range filters {
+		entry := composedFilter{filter: filter, bounds: bounds}
+		if layer, ok := filter.(Layer); ok {
+			entry.layered = true
+			entry.revision = layer.Revision()
+			entry.bounds = layer.Bounds().Intersect(bounds)
+			layers[filter] = c.layer(layer, entry)
+		} else if adjuster, ok := filter.(Adjuster); ok {
+			entry.adjusted = true
+			entry.revision = adjuster.Revision()
+			entry.bounds = adjuster.Bounds().Intersect(bounds)
+		}
+		composed[ii] = entry
+
+		// 和上一次合成时同一位置的滤镜比较，不同的话修改前后的区域都需要重新合成
+		if ii < len(c.composed) && c.composed[ii] == entry && (entry.layered || entry.adjusted) {
+			continue
+		}
+		changed = append(changed, grow(entry.bounds, ii))
+		if ii < len(c.composed) {
+			changed = append(changed, grow(c.composed[ii].bounds, ii))
+		}
+	}
+	for ii := len(filters); ii < len(c.composed); ii++ {
+		// 被删除的滤镜，之后没有其它滤镜
+		changed = append(changed, c.composed[ii].bounds)
+	}
+	c.layers = layers
+	c.composed = composed
+
+	dirty := dirtyTiles(changed, bounds)
+	Parallel(dirty, c.redraw)
+	return dirty
+}
+
+// dirtyTiles 返回和 changed 中任意一个区域相交的分块(不重复)，分块裁剪到 bounds 以内
+func dirtyTiles(changed []image.Rectangle, bounds image.Rectangle) []image.Rectangle {
+	seen := make(map[image.Point]bool)
+	var tiles []image.Rectangle
+	for _, r := range changed {
+		r = r.Intersect(bounds)
+		if r.Empty() {
+			continue
+		}
+		first, last := tileIndex(r.Min), tileIndex(r.Max.Sub(image.Pt(1, 1)))
+		for ty := first.Y; ty <= last.Y; ty++ {
+			for tx := first.X; tx <= last.X; tx++ {
+				idx := image.Pt(tx, ty)
+				if !seen[idx] {
+					seen[idx] = true
+					tiles = append(tiles, tileRect(idx).Intersect(bounds))
+				}
+			}
+		}
+	}
+	return tiles
+}
+
+// layer 返回滤镜的图层，只有滤镜修改过之后才重新光栅化
+func (c *Compositor) layer(layer Layer, entry composedFilter) *cachedLayer {
+	cached, found := c.layers[layer]
+	if found && cached.revision == entry.revision && cached.img.Rect == entry.bounds {
+		return cached
+	}
+	cached = &cachedLayer{revision: entry.revision, img: image.NewRGBA(entry.bounds), op: draw.Over}
+	if r, ok := layer.(replacer); ok && r.replacesUnder() {
+		cached.op = draw.Src
+	}
+	// 大的图层按照分块并行绘制，每个分块只写入自己的部分
+	Parallel(Tiles(entry.bounds), func(tile image.Rectangle) {
+		layer.Rasterize(cached.img.SubImage(tile).(*image.RGBA))
+	})
+	return cached
+}
+
+// redraw 重新合成 r 区域: 从原图开始依次叠加和 r 相交的图层。
+// 不同的分块会被同时调用。
+func (c *Compositor) redraw(r image.Rectangle) {
+	c.render(c.out, r, len(c.composed))
+}
+
+// render 将原图和前 n 个滤镜合成到 dst 的 r 区域，只读写 dst 中 r 的部分
+func (c *Compositor) render(dst *image.RGBA, r image.Rectangle, n int) {
+	draw.Draw(dst, r, c.base, r.Min, draw.Src)
+	for ii, entry := range c.composed[:n] {
+		clip := r.Intersect(entry.bounds)
+		if clip.Empty() {
+			continue
+		}
+		switch {
+		case entry.layered:
+			cached := c.layers[entry.filter]
+			draw.Draw(dst, clip, cached.img, clip.Min, cached.op)
+		case entry.adjusted:
+			adjuster := entry.filter.(Adjuster)
+			target := dst.SubImage(clip).(*image.RGBA)
+			src := target
+			if m := adjuster.Margin(); m > 0 {
+				// 周围的像素可能属于其它正在合成的分块，单独合成一份
+				src = image.NewRGBA(clip.Inset(-m).Intersect(c.base.Bounds()))
+				c.render(src, src.Rect, ii)
+			}
+			adjuster.Adjust(target, src)
+		default:
+			// 不支持图层的滤镜: 在已经合成的结果上逐像素计算
+			under := image.NewRGBA(clip)
+			draw.Draw(under, clip, dst, clip.Min, draw.Src)
+			draw.Draw(dst, clip, entry.filter.Apply(under), clip.Min, draw.Src)
+		}
+	}
+}
+
+// Image 返回合成之后的图片，坐标和 base 相同。
+// 返回的图片会在下一次 Compose 时被修改。
+func (c *Compositor) Image() *image.RGBA {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.out
+}
+
+// Invalidate 丢弃所有缓存的图层，下一次合成时全部重新绘制。
+// 用于影响所有滤镜的全局设置改变之后。
+func (c *Compositor) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.out = nil
+}
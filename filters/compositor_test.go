@@ -0,0 +1,259 @@
+package filters
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+)
+
+// benchmarkSize 4K 截图
+var benchmarkSize = image.Rect(0, 0, 3840, 2160)
+
+// benchmarkScrollSize 拼接之后的长截图
+var benchmarkScrollSize = image.Rect(0, 0, 1920, 24000)
+
+// benchmarkScene 生成一张 4K 的截图以及 100 多个标注，包括若干条画笔笔迹
+func benchmarkScene() (*image.RGBA, []Filter) {
+	return benchmarkSceneWithSize(benchmarkSize)
+}
+
+// benchmarkSceneWithSize 生成一张大小为 size 的截图以及 100 多个标注
+func benchmarkSceneWithSize(size image.Rectangle) (*image.RGBA, []Filter) {
+	rng := rand.New(rand.NewSource(1))
+	base := image.NewRGBA(size)
+	for ii := range base.Pix {
+		base.Pix[ii] = uint8(rng.Intn(256))
+	}
+	for ii := 3; ii < len(base.Pix); ii += 4 {
+		base.Pix[ii] = 0xFF
+	}
+
+	point := func() image.Point {
+		return image.Pt(rng.Intn(size.Dx()), rng.Intn(size.Dy()))
+	}
+	near := func(p image.Point) image.Point {
+		return p.Add(image.Pt(rng.Intn(801)-400, rng.Intn(601)-300))
+	}
+	rect := func() image.Rectangle {
+		p := point()
+		return image.Rectangle{Min: p, Max: p.Add(image.Pt(20+rng.Intn(300), 20+rng.Intn(200)))}
+	}
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+	var filters []Filter
+	for ii := 0; ii < 20; ii++ {
+		from := point()
+		filters = append(filters,
+			NewCircle(rect(), red, 3),
+			NewRectangle(rect(), red, 3),
+			NewArrow(from, near(from), red, 3),
+			NewStraightLine(from, near(from), red, 2),
+			NewDottedLine(from, near(from), red, 2, 8),
+		)
+	}
+	for ii := 0; ii < 5; ii++ {
+		p := point()
+		pen := NewPen(p, red, 1.5)
+		for jj := 0; jj < 200; jj++ {
+			p = p.Add(image.Pt(rng.Intn(21)-10, rng.Intn(21)-10))
+			pen.SetPoints(p)
+		}
+		filters = append(filters, pen)
+	}
+	return base, filters
+}
+
+// applyChain 原来的合成方式: 所有滤镜串成一个链，每个像素都要经过所有的滤镜
+func applyChain(base *image.RGBA, filters []Filter) *image.RGBA {
+	filtered := image.Image(base)
+	for _, filter := range filters {
+		filtered = filter.Apply(filtered)
+	}
+	out := image.NewRGBA(base.Rect)
+	draw.Src.Draw(out, out.Rect, filtered, base.Rect.Min)
+	return out
+}
+
+// BenchmarkChain 原来的方式合成整张截图
+func BenchmarkChain(b *testing.B) {
+	base, filters := benchmarkScene()
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		applyChain(base, filters)
+	}
+}
+
+// BenchmarkComposeFull 第一次合成: 光栅化所有图层并且合成整张截图
+func BenchmarkComposeFull(b *testing.B) {
+	base, filters := benchmarkScene()
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewCompositor().Compose(base, filters)
+	}
+}
+
+// BenchmarkComposeDrag 拖动最后一个图形时: 只有这个图形重新光栅化，只重新合成它前后占用的区域
+func BenchmarkComposeDrag(b *testing.B) {
+	base, filters := benchmarkScene()
+	arrow := NewArrow(image.Pt(100, 100), image.Pt(400, 300), color.RGBA{B: 0xFF, A: 0xFF}, 3)
+	filters = append(filters, arrow)
+	c := NewCompositor()
+	c.Compose(base, filters)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		arrow.SetPoints(image.Pt(100, 100), image.Pt(400+ii%50, 300+ii%30))
+		c.Compose(base, filters)
+	}
+}
+
+// BenchmarkComposePen 画笔绘制时: 每次增加一个点
+func BenchmarkComposePen(b *testing.B) {
+	base, filters := benchmarkScene()
+	p := image.Pt(1000, 1000)
+	pen := NewPen(p, color.RGBA{G: 0xFF, A: 0xFF}, 1.5)
+	filters = append(filters, pen)
+	c := NewCompositor()
+	c.Compose(base, filters)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		p = p.Add(image.Pt(3, ii%5-2))
+		pen.SetPoints(p)
+		c.Compose(base, filters)
+	}
+}
+
+// BenchmarkComposeScrollFull 长截图第一次合成
+func BenchmarkComposeScrollFull(b *testing.B) {
+	base, filters := benchmarkSceneWithSize(benchmarkScrollSize)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		NewCompositor().Compose(base, filters)
+	}
+}
+
+// BenchmarkComposeScrollMove 在长截图中同时修改相距很远的两个图形，只有它们所在的分块会重新合成
+func BenchmarkComposeScrollMove(b *testing.B) {
+	base, filters := benchmarkSceneWithSize(benchmarkScrollSize)
+	blue := color.RGBA{B: 0xFF, A: 0xFF}
+	top := NewRectangle(image.Rect(100, 100, 400, 300), blue, 3)
+	bottom := NewRectangle(image.Rect(100, 23000, 400, 23200), blue, 3)
+	filters = append(filters, top, bottom)
+	c := NewCompositor()
+	c.Compose(base, filters)
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		delta := image.Pt(ii%40, ii%20)
+		top.SetRect(image.Rect(100, 100, 400, 300).Add(delta))
+		bottom.SetRect(image.Rect(100, 23000, 400, 23200).Add(delta))
+		c.Compose(base, filters)
+	}
+}
+
+// maxDiff 返回两张图片每个通道的最大差异以及差异最大的像素
+func maxDiff(a, b *image.RGBA) (int, image.Point) {
+	max, at := 0, image.Point{}
+	for ii := range a.Pix {
+		d := int(a.Pix[ii]) - int(b.Pix[ii])
+		if d < 0 {
+			d = -d
+		}
+		if d > max {
+			pixel := ii / 4
+			max, at = d, a.Rect.Min.Add(image.Pt(pixel%a.Rect.Dx(), pixel/a.Rect.Dx()))
+		}
+	}
+	return max, at
+}
+
+// TestComposeMatchesChain 每种滤镜合成的结果都要和直接调用 Apply 的结果相同。
+// 图层使用预乘 alpha 叠加，和 Apply 的混合方式只有舍入的差别(每个通道最多差 1)。
+func TestComposeMatchesChain(t *testing.T) {
+	base, _ := benchmarkSceneWithSize(image.Rect(0, 0, 800, 600))
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+	pen := NewPen(image.Pt(100, 100), red, 1.5)
+	pen.SetPoints(image.Pt(300, 200))
+	pen.SetPoints(image.Pt(350, 400))
+	tests := []struct {
+		name   string
+		filter Filter
+	}{
+		{"circle", NewCircle(image.Rect(100, 100, 400, 300), red, 3)},
+		{"rectangle", NewRectangle(image.Rect(100, 100, 400, 300), red, 3)},
+		{"arrow", NewArrow(image.Pt(100, 100), image.Pt(400, 300), red, 3)},
+		{"straight line", NewStraightLine(image.Pt(100, 100), image.Pt(400, 300), red, 2)},
+		{"dotted line", NewDottedLine(image.Pt(100, 100), image.Pt(400, 300), red, 2, 8)},
+		{"pen", pen},
+		{"text", NewText("FireShotGo", image.Pt(200, 200), red, color.RGBA{}, 20)},
+		{"shield block", NewShieldBlock(image.Rect(50, 50, 200, 120), color.RGBA{A: 0xFF})},
+		{"adjust", NewAdjust(image.Rect(300, 200, 600, 500), Adjustments{Brightness: 0.2, Sharpen: 1})},
+	}
+	for _, test := range tests {
+		filters := []Filter{test.filter}
+		c := NewCompositor()
+		c.Compose(base, filters)
+		if d, at := maxDiff(c.Image(), applyChain(base, filters)); d > 1 {
+			t.Errorf("%s: Compose() differs from Apply() by %d at %v", test.name, d, at)
+		}
+	}
+}
+
+// TestComposeDirtyTiles 修改一个图层之后只重新合成它修改前后占用的分块，
+// 并且结果和从头合成的完全相同
+func TestComposeDirtyTiles(t *testing.T) {
+	base, filters := benchmarkSceneWithSize(image.Rect(0, 0, 1600, 1200))
+	before, after := image.Rect(100, 100, 300, 200), image.Rect(120, 110, 320, 210)
+	rect := NewRectangle(before, color.RGBA{B: 0xFF, A: 0xFF}, 3)
+	filters = append(filters, rect, NewAdjust(image.Rect(200, 150, 700, 500), Adjustments{Contrast: 0.3, Sharpen: 1}))
+	c := NewCompositor()
+	if dirty := c.Compose(base, filters); len(dirty) != len(Tiles(base.Rect)) {
+		t.Fatalf("first Compose() redrew %d tiles, want all %d", len(dirty), len(Tiles(base.Rect)))
+	}
+	if dirty := c.Compose(base, filters); len(dirty) != 0 {
+		t.Errorf("Compose() without changes redrew %d tiles, want 0", len(dirty))
+	}
+
+	oldBounds := rect.Bounds()
+	rect.SetRect(after)
+	dirty := c.Compose(base, filters)
+	if len(dirty) == 0 || len(dirty) == len(Tiles(base.Rect)) {
+		t.Fatalf("Compose() after moving the rectangle redrew %d of %d tiles", len(dirty), len(Tiles(base.Rect)))
+	}
+	// 锐化需要周围 1 个像素，所以变化的区域向外扩大 1
+	touched := oldBounds.Union(rect.Bounds()).Inset(-1)
+	for _, tile := range dirty {
+		if !tile.Overlaps(touched) {
+			t.Errorf("Compose() redrew tile %v, which the rectangle never touched (%v)", tile, touched)
+		}
+	}
+	fresh := NewCompositor()
+	fresh.Compose(base, filters)
+	if d, at := maxDiff(c.Image(), fresh.Image()); d != 0 {
+		t.Errorf("incremental Compose() differs from a full Compose() by %d at %v", d, at)
+	}
+
+	// 三次锐化叠加: 每次锐化都把变化向外扩散 1 个像素，一共 3 个像素(超过了矩形 Bounds() 留出的余量)。
+	// 在不透明的灰色背景上每次把矩形的右边移动 1 个像素，修改前后的区域都在第一个分块中，
+	// 只有扩散之后才会超过分块的边界
+	gray := image.NewRGBA(image.Rect(0, 0, 2*TileSize, TileSize))
+	draw.Draw(gray, gray.Rect, image.NewUniform(color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xFF}), image.Point{}, draw.Src)
+	edge := NewRectangle(image.Rect(120, 110, TileSize-12, 210), color.RGBA{B: 0xFF, A: 0xFF}, 3)
+	stacked := []Filter{edge,
+		NewAdjust(gray.Rect, Adjustments{Sharpen: 0.25}),
+		NewAdjust(gray.Rect, Adjustments{Sharpen: 0.25}),
+		NewAdjust(gray.Rect, Adjustments{Sharpen: 0.25})}
+	c = NewCompositor()
+	c.Compose(gray, stacked)
+	for maxX := TileSize - 11; maxX <= TileSize; maxX++ {
+		r := edge.Rect
+		r.Max.X = maxX
+		edge.SetRect(r)
+		c.Compose(gray, stacked)
+		fresh := NewCompositor()
+		fresh.Compose(gray, stacked)
+		if d, at := maxDiff(c.Image(), fresh.Image()); d != 0 {
+			t.Errorf("with stacked sharpening, incremental Compose() after moving the rectangle edge to x=%d differs from a full Compose() by %d at %v",
+				maxX, d, at)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package filters
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Measurement 两点之间的测量结果
+type Measurement struct {
+	// Width, Height 水平和垂直方向的像素数(绝对值)
+	Width, Height int
+	// Length 两点之间的距离
+	Length float64
+	// Angle 从 From 到 To 的角度，水平向右为 0，逆时针为正，范围 (-180, 180]
+	Angle float64
+}
+
+// Measure 测量 from 到 to 的宽、高、距离和角度
+func Measure(from, to image.Point) Measurement {
+	delta := to.Sub(from)
+	m := Measurement{
+		Width:  int(math.Abs(float64(delta.X))),
+		Height: int(math.Abs(float64(delta.Y))),
+		Length: math.Hypot(float64(delta.X), float64(delta.Y)),
+	}
+	if delta != (image.Point{}) {
+		// 截图的 Y 轴向下，角度按照屏幕上看到的方向计算
+		m.Angle = math.Atan2(-float64(delta.Y), float64(delta.X)) * 180 / math.Pi
+	}
+	return m
+}
+
+// String implements fmt.Stringer.
+func (m Measurement) String() string {
+	return fmt.Sprintf("W %d x H %d px, distance %.1f px, angle %.1f°", m.Width, m.Height, m.Length, m.Angle)
+}
+
+// dimensionLabelBackground 尺寸标注文字的背景，盖住下面的线条，在任何背景上都容易看清
+var dimensionLabelBackground = color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xD0}
+
+// Dimension 尺寸标注线: 两端是垂直于线条的短横，中间标出长度
+type Dimension struct {
+	// From, To 标注的起点和终点
+	From, To image.Point
+
+	// Color 线条和文字的颜色
+	Color color.Color
+
+	// Thickness 线条的宽度
+	Thickness float64
+
+	// FontSize 长度文字的大小
+	FontSize float64
+
+	line  *StraightLine
+	label *Text
+
+	revision
+}
+
+// NewDimension 创建尺寸标注线
+func NewDimension(from, to image.Point, color color.Color, thickness, fontSize float64) *Dimension {
+	d := &Dimension{Color: color, Thickness: thickness, FontSize: fontSize}
+	d.line = NewStraightLine(from, to, color, thickness)
+	d.line.SetCaps(LineCaps{Tail: CapBar, Head: CapBar})
+	d.label = NewText("", from, color, dimensionLabelBackground, fontSize)
+	d.SetPoints(from, to)
+	return d
+}
+
+// SetAntiAlias 打开或者关闭线条边缘的抗锯齿，文字总是抗锯齿
+func (d *Dimension) SetAntiAlias(on bool) {
+	d.touch()
+	d.line.SetAntiAlias(on)
+}
+
+// SetPoints 修改起点和终点，长度文字放在线条的中点
+func (d *Dimension) SetPoints(from, to image.Point) {
+	d.touch()
+	d.From, d.To = from, to
+	d.line.SetPoints(from, to)
+	d.label.Text = fmt.Sprintf("%.0f px", Measure(from, to).Length)
+	d.label.Center = from.Add(to).Div(2)
+	d.label.SetText(d.label.Text)
+}
+
+// Bounds implements the Layer interface.
+func (d *Dimension) Bounds() image.Rectangle {
+	return d.line.Bounds().Union(d.label.Bounds())
+}
+
+// Rasterize implements the Layer interface.
+// 文字叠加在线条上面，和 Apply 的结果相同
+func (d *Dimension) Rasterize(dst *image.RGBA) {
+	d.line.Rasterize(dst)
+	bounds := d.label.Bounds().Intersect(dst.Rect)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			dst.Set(x, y, d.label.at(x, y, dst.RGBAAt(x, y)))
+		}
+	}
+}
+
+// Apply implements the Filter interface.
+func (d *Dimension) Apply(image image.Image) image.Image {
+	return d.label.Apply(d.line.Apply(image))
+}
+
+// SVG implements the SVGElement interface.
+func (d *Dimension) SVG() string {
+	return d.line.SVG() + d.label.SVG()
+}
@@ -19,6 +19,9 @@ type DottedLine struct {
 	// Thickness 指定虚线的宽度
 	Thickness float64
 
+	// Caps 两端的样式，默认两端都没有端点
+	Caps LineCaps
+
 	// Rectangle enclosing dotted line.
 	rect image.Rectangle
 	// 转换矩阵
@@ -28,17 +31,29 @@ type DottedLine struct {
 
 	// 虚线间隔
 	dottedLineSpacing float64
+
+	// AntiAlias 是否对边缘做抗锯齿，创建时默认打开，参考 coverage
+	AntiAlias bool
+
+	revision
 }
 
 // NewDottedLine 创建一个新的虚线，接口中必须传入虚线的宽度、颜色以及起点.
 func NewDottedLine(from, to image.Point, color color.Color, thickness float64, dottedLineSpacing float64) *DottedLine {
-	c := &DottedLine{Color: color, Thickness: thickness, dottedLineSpacing: dottedLineSpacing}
+	c := &DottedLine{Color: color, Thickness: thickness, dottedLineSpacing: dottedLineSpacing, AntiAlias: true}
 	c.SetPoints(from, to)
 	return c
 }
 
+// SetAntiAlias 打开或者关闭边缘的抗锯齿
+func (c *DottedLine) SetAntiAlias(on bool) {
+	c.touch()
+	c.AntiAlias = on
+}
+
 // SetPoints 虚线打点
 func (c *DottedLine) SetPoints(from, to image.Point) {
+	c.touch()
 	if to.X == from.X && to.Y == from.Y {
 		to.X += 1 // 保证虚线最少为一个像素大小，否则图像上显示不出来
 	}
@@ -47,7 +62,7 @@ func (c *DottedLine) SetPoints(from, to image.Point) {
 	c.rect = image.Rectangle{Min: from, Max: to}.Canon()
 	// 保证线条的宽度，在做矩阵转换的时候能保证起点和结束点的宽度一致
 	// 设置线条的宽度
-	headExtraPixels := int(c.Thickness)
+	headExtraPixels := c.Caps.extent(c.Thickness)
 	c.rect.Min.X -= headExtraPixels
 	c.rect.Min.Y -= headExtraPixels
 	c.rect.Max.X += headExtraPixels
@@ -71,6 +86,28 @@ func (c *DottedLine) SetPoints(from, to image.Point) {
 		mgl64.Translate2D(float64(-c.From.X), float64(-c.From.Y)))
 }
 
+// SetCaps 设置两端的样式，修改之后需要重新计算包围矩形
+func (c *DottedLine) SetCaps(caps LineCaps) {
+	c.Caps = caps
+	c.SetPoints(c.From, c.To)
+}
+
+// sample 判断点 (fx, fy) 是否在虚线上
+func (c *DottedLine) sample(fx, fy float64) region {
+	p := c.rebaseMatrix.Mul3x1(mgl64.Vec3{fx, fy, 1.0})
+	if c.Caps.contains(p.X(), p.Y(), c.vectorLength, c.Thickness) {
+		return onStroke
+	}
+	start, end := c.Caps.bodyRange(c.vectorLength, c.Thickness)
+	if p.X() >= start && p.X() < end && math.Abs(p.Y()) < c.Thickness/2 {
+		spacing := math.Max(c.dottedLineSpacing, 0.01)
+		if (int)(p.X()/spacing)%2 == 0 {
+			return onStroke
+		}
+	}
+	return outside
+}
+
 // at is the function given to the filterImage object.
 // under 是当前背景图片上的当前颜色
 func (c *DottedLine) at(x, y int, under color.Color) color.Color {
@@ -101,22 +138,21 @@ func (c *DottedLine) at(x, y int, under color.Color) color.Color {
 		}
 	}
 
-	if homogPoint.X() < 0 {
+	_, stroke := coverage(x, y, c.AntiAlias, c.sample)
+	if stroke == 0 {
 		return under
 	}
+	return blend(under, c.Color, stroke)
+}
 
-	if homogPoint.X() < c.vectorLength {
-		if math.Abs(homogPoint.Y()) < c.Thickness/2 {
-			if c.dottedLineSpacing < 0.01 {
-				c.dottedLineSpacing = 0.01
-			}
-			if (int)(homogPoint.X()/c.dottedLineSpacing)%2 == 0 {
-				return c.Color
-			}
-		}
-	}
+// Bounds 返回虚线占用的区域，包括两端的端点
+func (c *DottedLine) Bounds() image.Rectangle {
+	return image.Rectangle{Min: c.rect.Min, Max: c.rect.Max.Add(image.Pt(1, 1))}
+}
 
-	return under
+// Rasterize implements the Layer interface.
+func (c *DottedLine) Rasterize(dst *image.RGBA) {
+	rasterizeLine(dst, c.rebaseMatrix, float64(c.Caps.extent(c.Thickness))+1, c.at)
 }
 
 // Apply 接口ImageFilter的实现.
@@ -0,0 +1,226 @@
+package filters
+
+import (
+	"fmt"
+	"gitee.com/andrewgithub/FireShotGo/firetheme"
+	"github.com/golang/freetype/truetype"
+	"github.com/golang/glog"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultFontName 默认字体，也就是主题中使用的中文字体
+const DefaultFontName = "上首简宋"
+
+// fontVariants 同一个字体的 常规/粗体/斜体/粗斜体 四种变体，没有的变体为空
+type fontVariants struct {
+	regular, bold, italic, boldItalic fontSource
+}
+
+// fontSource 字体的来源，内置字体直接使用 data，系统字体在用到的时候才从 path 读取
+type fontSource struct {
+	data []byte
+	path string
+}
+
+func (fs fontSource) valid() bool { return fs.data != nil || fs.path != "" }
+
+// key 用于缓存解析之后的字体
+func (fs fontSource) key() string {
+	if fs.path != "" {
+		return fs.path
+	}
+	return fmt.Sprintf("embedded:%p", fs.data)
+}
+
+var (
+	fontsOnce sync.Once
+	// fontRegistry 所有可用的字体，key 是字体名称
+	fontRegistry map[string]*fontVariants
+	fontNames    []string
+
+	parsedFontsLock sync.Mutex
+	parsedFonts     = make(map[string]*truetype.Font)
+)
+
+// embeddedFonts 程序内置的字体
+func embeddedFonts() map[string]*fontVariants {
+	return map[string]*fontVariants{
+		DefaultFontName: {regular: fontSource{data: firetheme.ShangShouJianSongXianXiTi}},
+		"Go": {
+			regular:    fontSource{data: goregular.TTF},
+			bold:       fontSource{data: gobold.TTF},
+			italic:     fontSource{data: goitalic.TTF},
+			boldItalic: fontSource{data: gobolditalic.TTF},
+		},
+		"Go Mono": {
+			regular:    fontSource{data: gomono.TTF},
+			bold:       fontSource{data: gomonobold.TTF},
+			italic:     fontSource{data: gomonoitalic.TTF},
+			boldItalic: fontSource{data: gomonobolditalic.TTF},
+		},
+	}
+}
+
+// systemFontDirs 各个平台上系统字体所在的目录
+func systemFontDirs() []string {
+	home, _ := os.UserHomeDir()
+	switch runtime.GOOS {
+	case "windows":
+		return []string{filepath.Join(os.Getenv("WINDIR"), "Fonts"),
+			filepath.Join(os.Getenv("LOCALAPPDATA"), "Microsoft", "Windows", "Fonts")}
+	case "darwin":
+		return []string{"/System/Library/Fonts", "/Library/Fonts", filepath.Join(home, "Library", "Fonts")}
+	}
+	return []string{"/usr/share/fonts", "/usr/local/share/fonts",
+		filepath.Join(home, ".fonts"), filepath.Join(home, ".local", "share", "fonts")}
+}
+
+// variantSuffixes 通过文件名后缀识别字体变体，例如 DejaVuSans-BoldOblique.ttf
+var variantSuffixes = []struct {
+	suffix       string
+	bold, italic bool
+}{
+	{"bolditalic", true, true}, {"boldoblique", true, true},
+	{"bold", true, false}, {"italic", false, true}, {"oblique", false, true},
+	{"regular", false, false}, {"book", false, false},
+}
+
+// scanSystemFonts 扫描系统字体目录中的 TrueType 字体，只记录路径，不解析文件
+func scanSystemFonts(registry map[string]*fontVariants) {
+	for _, dir := range systemFontDirs() {
+		_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !strings.EqualFold(filepath.Ext(path), ".ttf") {
+				return nil
+			}
+			base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+			name, bold, italic := base, false, false
+			lower := strings.ToLower(base)
+			for _, v := range variantSuffixes {
+				if strings.HasSuffix(lower, v.suffix) && len(base) > len(v.suffix) {
+					name = strings.TrimRight(base[:len(base)-len(v.suffix)], "-_ ")
+					bold, italic = v.bold, v.italic
+					break
+				}
+			}
+			if name == "" {
+				return nil
+			}
+			variants := registry[name]
+			if variants == nil {
+				variants = &fontVariants{}
+				registry[name] = variants
+			}
+			src := fontSource{path: path}
+			switch {
+			case bold && italic:
+				variants.boldItalic = src
+			case bold:
+				variants.bold = src
+			case italic:
+				variants.italic = src
+			default:
+				variants.regular = src
+			}
+			return nil
+		})
+	}
+}
+
+func initFonts() {
+	fontsOnce.Do(func() {
+		fontRegistry = embeddedFonts()
+		system := make(map[string]*fontVariants)
+		scanSystemFonts(system)
+		var systemNames []string
+		for name, variants := range system {
+			if _, found := fontRegistry[name]; found || !variants.regular.valid() {
+				// 没有常规体的字体不列出来，避免列表里出现大量的变体
+				continue
+			}
+			fontRegistry[name] = variants
+			systemNames = append(systemNames, name)
+		}
+		sort.Strings(systemNames)
+		fontNames = append([]string{DefaultFontName, "Go", "Go Mono"}, systemNames...)
+		glog.V(2).Infof("Found %d system fonts", len(systemNames))
+	})
+}
+
+// FontNames 返回所有可用的字体名称，内置字体在前，系统字体按照名称排序
+func FontNames() []string {
+	initFonts()
+	return fontNames
+}
+
+// parseFont 解析字体文件，解析结果会被缓存
+func parseFont(src fontSource) (*truetype.Font, error) {
+	parsedFontsLock.Lock()
+	defer parsedFontsLock.Unlock()
+	if f, found := parsedFonts[src.key()]; found {
+		return f, nil
+	}
+	data := src.data
+	if data == nil {
+		var err error
+		data, err = ioutil.ReadFile(src.path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	f, err := truetype.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	parsedFonts[src.key()] = f
+	return f, nil
+}
+
+// loadFont 加载字体，字体没有对应的粗体或者斜体变体时返回常规体，
+// 并通过 fakeBold, fakeItalic 告知调用者需要自己模拟
+func loadFont(name string, bold, italic bool) (f *truetype.Font, fakeBold, fakeItalic bool) {
+	initFonts()
+	variants := fontRegistry[name]
+	if variants == nil {
+		if name != "" {
+			glog.Warningf("Font %q not found, using %q", name, DefaultFontName)
+		}
+		variants = fontRegistry[DefaultFontName]
+	}
+
+	src := variants.regular
+	fakeBold, fakeItalic = bold, italic
+	switch {
+	case bold && italic && variants.boldItalic.valid():
+		src, fakeBold, fakeItalic = variants.boldItalic, false, false
+	case bold && variants.bold.valid():
+		src, fakeBold = variants.bold, false
+	case italic && variants.italic.valid():
+		src, fakeItalic = variants.italic, false
+	}
+
+	var err error
+	f, err = parseFont(src)
+	if err != nil {
+		glog.Errorf("Failed to parse font %q (%s): %s", name, src.path, err)
+		f, err = parseFont(fontRegistry[DefaultFontName].regular)
+		if err != nil {
+			glog.Fatalf("Failed to parse embedded font %q: %s", DefaultFontName, err)
+		}
+		fakeBold, fakeItalic = bold, italic
+	}
+	return
+}
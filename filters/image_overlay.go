@@ -0,0 +1,157 @@
+package filters
+
+import (
+	"gitee.com/andrewgithub/FireShotGo/beautify"
+	xdraw "golang.org/x/image/draw"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// ImageOverlay 在截图上插入另一张图片(例如提示框或者终端的截图)，
+// 图片可以移动和缩放，并且可以加上边框和阴影
+type ImageOverlay struct {
+	// Source 插入的原始图片
+	Source image.Image
+
+	// Rect 图片缩放之后在截图中的位置，不包括边框和阴影
+	Rect image.Rectangle
+
+	// BorderWidth 边框宽度，边框画在图片的外侧
+	BorderWidth int
+	// BorderColor 边框颜色
+	BorderColor color.Color
+
+	// ShadowBlur 阴影模糊半径，0 表示没有阴影
+	ShadowBlur int
+	// ShadowOffset 阴影的偏移
+	ShadowOffset image.Point
+	// ShadowColor 阴影颜色，透明度决定阴影的深浅
+	ShadowColor color.Color
+
+	scaled *image.RGBA
+	shadow *image.Alpha
+	bounds image.Rectangle
+
+	revision
+}
+
+// NewImageOverlay creates an overlay that draws source scaled to fit rect.
+func NewImageOverlay(source image.Image, rect image.Rectangle) *ImageOverlay {
+	o := &ImageOverlay{
+		Source:       source,
+		BorderColor:  color.RGBA{R: 0x99, G: 0x99, B: 0x99, A: 0xFF},
+		ShadowOffset: image.Point{X: 2, Y: 4},
+		ShadowColor:  color.RGBA{A: 0x80},
+	}
+	o.SetRect(rect)
+	return o
+}
+
+// SetRect 改变图片的位置和大小，大小改变时重新缩放
+func (o *ImageOverlay) SetRect(rect image.Rectangle) {
+	o.touch()
+	rect = rect.Canon()
+	if o.scaled != nil && rect.Size() == o.Rect.Size() {
+		// 只是移动: 阴影跟着平移，不需要重新模糊
+		delta := rect.Min.Sub(o.Rect.Min)
+		o.Rect = rect
+		o.bounds = o.bounds.Add(delta)
+		if o.shadow != nil {
+			// 复制一份，像素是共享的，但是不能修改之前的 Rect (可能被保存下来用于撤销修改)
+			shifted := *o.shadow
+			shifted.Rect = shifted.Rect.Add(delta)
+			o.shadow = &shifted
+		}
+		return
+	}
+	o.scaled = image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	if rect.Size() == o.Source.Bounds().Size() {
+		draw.Draw(o.scaled, o.scaled.Rect, o.Source, o.Source.Bounds().Min, draw.Src)
+	} else {
+		xdraw.CatmullRom.Scale(o.scaled, o.scaled.Rect, o.Source, o.Source.Bounds(), xdraw.Src, nil)
+	}
+	o.Rect = rect
+	o.updateShadow()
+}
+
+// SetDecoration 设置边框和阴影
+func (o *ImageOverlay) SetDecoration(borderWidth int, borderColor color.Color, shadowBlur int, shadowOffset image.Point, shadowColor color.Color) {
+	o.BorderWidth, o.BorderColor = borderWidth, borderColor
+	o.ShadowBlur, o.ShadowOffset, o.ShadowColor = shadowBlur, shadowOffset, shadowColor
+	o.updateShadow()
+}
+
+// MoveTo 移动图片，左上角移动到 topLeft
+func (o *ImageOverlay) MoveTo(topLeft image.Point) {
+	o.SetRect(o.Rect.Add(topLeft.Sub(o.Rect.Min)))
+}
+
+// Bounds 返回图片(包括边框和阴影)在截图中占用的区域
+func (o *ImageOverlay) Bounds() image.Rectangle {
+	return o.bounds
+}
+
+// framed 返回包括边框在内的区域
+func (o *ImageOverlay) framed() image.Rectangle {
+	return o.Rect.Inset(-o.BorderWidth)
+}
+
+// updateShadow 重新生成阴影的遮罩: 带边框的矩形偏移之后做均值模糊
+func (o *ImageOverlay) updateShadow() {
+	o.touch()
+	framed := o.framed()
+	o.bounds = framed
+	o.shadow = nil
+	if o.ShadowBlur <= 0 || o.ShadowColor == nil {
+		return
+	}
+	if _, _, _, a := o.ShadowColor.RGBA(); a == 0 {
+		return
+	}
+	shadowRect := framed.Add(o.ShadowOffset)
+	o.shadow = image.NewAlpha(shadowRect.Inset(-2 * o.ShadowBlur))
+	draw.Draw(o.shadow, shadowRect, image.Opaque, image.Point{}, draw.Src)
+	beautify.BoxBlur(o.shadow, o.ShadowBlur/2+1)
+	o.bounds = o.bounds.Union(o.shadow.Rect)
+}
+
+// at is the function given to the filterImage object.
+func (o *ImageOverlay) at(x, y int, under color.Color) color.Color {
+	p := image.Point{X: x, Y: y}
+	if !p.In(o.bounds) {
+		return under
+	}
+	if p.In(o.Rect) {
+		over := o.scaled.RGBAAt(x-o.Rect.Min.X, y-o.Rect.Min.Y)
+		if over.A == 0xFF {
+			return over
+		}
+		under = o.decoration(p, under)
+		return blend(under, over, 1)
+	}
+	return o.decoration(p, under)
+}
+
+// decoration 图片以外的部分: 边框和阴影
+func (o *ImageOverlay) decoration(p image.Point, under color.Color) color.Color {
+	if o.BorderWidth > 0 && p.In(o.framed()) && !p.In(o.Rect) {
+		return blend(under, o.BorderColor, 1)
+	}
+	if o.shadow != nil && p.In(o.shadow.Rect) {
+		if a := o.shadow.AlphaAt(p.X, p.Y).A; a > 0 {
+			return blend(under, o.ShadowColor, float64(a)/0xFF)
+		}
+	}
+	return under
+}
+
+// Rasterize implements the Layer interface.
+func (o *ImageOverlay) Rasterize(dst *image.RGBA) {
+	rasterize(dst, o.at)
+}
+
+// Apply implements the ImageFilter interface.
+func (o *ImageOverlay) Apply(image image.Image) image.Image {
+	return &filterImage{image, o.at}
+}
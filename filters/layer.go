@@ -0,0 +1,113 @@
+package filters
+
+import (
+	"github.com/go-gl/mathgl/mgl64"
+	"image"
+	"image/color"
+	"math"
+	"sync/atomic"
+)
+
+// Filter 在图片上叠加标注的滤镜，和 screenshot.ImageFilter 的定义相同
+type Filter interface {
+	Apply(image image.Image) image.Image
+}
+
+// Layer 可以光栅化到独立图层的滤镜。
+// 图层只覆盖 Bounds() 的区域，并且只在滤镜修改之后(Revision() 改变)才需要重新绘制，
+// 合成的时候直接叠加缓存的图层，不需要每个像素都经过所有的滤镜。
+type Layer interface {
+	Filter
+
+	// Bounds 返回滤镜会修改的区域，区域以外的像素保持不变
+	Bounds() image.Rectangle
+
+	// Revision 返回滤镜的版本，每次修改之后都会改变
+	Revision() uint64
+
+	// Rasterize 将滤镜绘制到透明的 dst 上(预乘 alpha)，只需要绘制 dst.Rect 的部分
+	Rasterize(dst *image.RGBA)
+}
+
+// Adjuster 调整下面已经合成的像素的滤镜，例如亮度、对比度和锐化。
+// 结果依赖下面的像素，所以不能缓存成图层: 下面的区域改变之后会重新计算。
+type Adjuster interface {
+	Filter
+
+	// Bounds 返回滤镜会修改的区域，区域以外的像素保持不变
+	Bounds() image.Rectangle
+
+	// Revision 返回滤镜的版本，每次修改之后都会改变
+	Revision() uint64
+
+	// Margin 计算一个像素需要用到的周围像素的距离，例如锐化是 1
+	Margin() int
+
+	// Adjust 根据 src 计算 dst.Rect 的像素。src 包含 dst.Rect 向外扩大 Margin() 的区域(图片以内的部分)，
+	// Margin() 为 0 时 src 和 dst 可能是同一张图片。
+	Adjust(dst, src *image.RGBA)
+}
+
+// replacer 图层直接替换下面的像素而不是叠加在上面，例如遮挡块
+type replacer interface {
+	replacesUnder() bool
+}
+
+// revisionCounter 所有滤镜共用的版本计数器。
+// 使用全局计数器可以保证每次修改得到的版本都是唯一的，
+// 即使滤镜被恢复成之前保存的副本(取消编辑)也不会和缓存的图层混淆。
+var revisionCounter uint64
+
+// revision 嵌入到滤镜中记录版本，滤镜的每个修改方法都需要调用 touch()
+type revision struct {
+	rev uint64
+}
+
+// touch 标记滤镜已经修改，缓存的图层需要重新绘制
+func (r *revision) touch() {
+	r.rev = atomic.AddUint64(&revisionCounter, 1)
+}
+
+// Revision implements the Layer interface.
+func (r *revision) Revision() uint64 {
+	return r.rev
+}
+
+// rasterize 使用滤镜的 at 函数在透明背景上逐像素绘制图层
+func rasterize(dst *image.RGBA, at func(x, y int, under color.Color) color.Color) {
+	for y := dst.Rect.Min.Y; y < dst.Rect.Max.Y; y++ {
+		for x := dst.Rect.Min.X; x < dst.Rect.Max.X; x++ {
+			if c := at(x, y, color.Transparent); c != color.Transparent {
+				dst.Set(x, y, c)
+			}
+		}
+	}
+}
+
+// rasterizeLine 与 rasterize 相同，但是斜线的包围矩形大部分都是空的，所以每一行只绘制离线条 reach 以内的部分。
+// rebase 是从截图坐标到线条局部坐标的变换，局部坐标的 Y 轴垂直于线条。
+func rasterizeLine(dst *image.RGBA, rebase mgl64.Mat3, reach float64, at func(x, y int, under color.Color) color.Color) {
+	// 局部坐标 Y = a*x + b(y)
+	a := rebase.At(1, 0)
+	for y := dst.Rect.Min.Y; y < dst.Rect.Max.Y; y++ {
+		b := rebase.At(1, 1)*float64(y) + rebase.At(1, 2)
+		minX, maxX := dst.Rect.Min.X, dst.Rect.Max.X
+		if math.Abs(a) < 1e-9 {
+			if math.Abs(b) > reach {
+				continue
+			}
+		} else {
+			x0, x1 := (-reach-b)/a, (reach-b)/a
+			if x0 > x1 {
+				x0, x1 = x1, x0
+			}
+			minX = int(math.Max(float64(minX), math.Floor(x0)))
+			maxX = int(math.Min(float64(maxX), math.Ceil(x1)+1))
+		}
+		for x := minX; x < maxX; x++ {
+			if c := at(x, y, color.Transparent); c != color.Transparent {
+				dst.Set(x, y, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package filters
+
+import (
+	"math"
+)
+
+// LineCap 线条端点的样式，箭头、直线、虚线的起点和终点都可以单独配置
+type LineCap int
+
+const (
+	// CapNone 无端点，线条直接结束
+	CapNone LineCap = iota
+	// CapTriangle 实心三角形，也就是普通箭头
+	CapTriangle
+	// CapOpenTriangle 空心三角形，只绘制三角形的两条边
+	CapOpenTriangle
+	// CapDot 圆点
+	CapDot
+	// CapSquare 方块
+	CapSquare
+	// CapBar 垂直于线条的短横，类似尺寸标注线
+	CapBar
+)
+
+// LineCapNames 端点样式的显示名称，下标和 LineCap 的取值一一对应
+var LineCapNames = []string{"无", "实心三角", "空心三角", "圆点", "方块", "横杠"}
+
+// String implements fmt.Stringer.
+func (lc LineCap) String() string {
+	if lc < 0 || int(lc) >= len(LineCapNames) {
+		return "未知"
+	}
+	return LineCapNames[lc]
+}
+
+// ParseLineCap 通过显示名称查找端点样式，找不到返回 CapNone
+func ParseLineCap(name string) LineCap {
+	for ii, n := range LineCapNames {
+		if n == name {
+			return LineCap(ii)
+		}
+	}
+	return CapNone
+}
+
+// capLengthFactor 端点沿线条方向的长度预设
+// capWidthFactor 端点垂直线条方向的宽度预设
+// 实际像素为 factor * Thickness * LineCaps.Size
+const (
+	capLengthFactor = arrowHeadLengthFactor
+	capWidthFactor  = arrowHeadWidthFactor
+)
+
+// LineCaps 线条两端的样式: Tail 在起点 From, Head 在终点 To
+type LineCaps struct {
+	Tail, Head LineCap
+
+	// Size 端点大小相对于线宽的倍数，0 按照 1 处理
+	Size float64
+}
+
+func (lc LineCaps) scale() float64 {
+	if lc.Size <= 0 {
+		return 1
+	}
+	return lc.Size
+}
+
+// dims 返回端点沿线条方向的长度和垂直方向的宽度
+func (lc LineCaps) dims(thickness float64) (length, width float64) {
+	s := lc.scale() * thickness
+	return capLengthFactor * s, capWidthFactor * s
+}
+
+// extent 返回端点超出线条矩形的像素数，用于扩大包围矩形
+func (lc LineCaps) extent(thickness float64) int {
+	extra := thickness
+	if lc.Tail != CapNone || lc.Head != CapNone {
+		length, width := lc.dims(thickness)
+		extra += math.Max(length, width)
+	}
+	return int(extra + 0.99)
+}
+
+// bodyRange 返回线条主体在局部坐标 X 轴上的起止位置，实心三角形会让出空间给端点
+func (lc LineCaps) bodyRange(vectorLength, thickness float64) (start, end float64) {
+	length, _ := lc.dims(thickness)
+	end = vectorLength
+	if lc.Tail == CapTriangle {
+		start = length
+	}
+	if lc.Head == CapTriangle {
+		end = vectorLength - length
+	}
+	return
+}
+
+// contains 判断局部坐标 (x, y) 是否落在某个端点上，坐标系以 From 为原点，X 轴指向 To
+func (lc LineCaps) contains(x, y, vectorLength, thickness float64) bool {
+	length, width := lc.dims(thickness)
+	if lc.Tail != CapNone && capContains(lc.Tail, x, y, length, width, thickness) {
+		return true
+	}
+	if lc.Head != CapNone && capContains(lc.Head, vectorLength-x, y, length, width, thickness) {
+		return true
+	}
+	return false
+}
+
+// capContains 判断点是否在端点内，u 是从端点向线条内部的距离，v 是到线条中轴的距离
+func capContains(style LineCap, u, v, length, width, thickness float64) bool {
+	halfWidth := width / 2
+	switch style {
+	case CapTriangle:
+		return u >= 0 && u <= length && math.Abs(v) < u*halfWidth/length
+	case CapOpenTriangle:
+		return distanceToSegment(u, math.Abs(v), length, halfWidth) < thickness/2
+	case CapDot:
+		return u*u+v*v < halfWidth*halfWidth
+	case CapSquare:
+		return math.Abs(u) < halfWidth && math.Abs(v) < halfWidth
+	case CapBar:
+		return math.Abs(u) < thickness/2 && math.Abs(v) < halfWidth
+	}
+	return false
+}
+
+// distanceToSegment 点 (x, y) 到 (0, 0)-(x1, y1) 线段的距离
+func distanceToSegment(x, y, x1, y1 float64) float64 {
+	lengthSq := x1*x1 + y1*y1
+	if lengthSq == 0 {
+		return math.Hypot(x, y)
+	}
+	t := (x*x1 + y*y1) / lengthSq
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(x-t*x1, y-t*y1)
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/go-gl/mathgl/mgl64"
 	"image"
 	"image/color"
+	"image/draw"
 	"math"
 	"sync"
 )
@@ -19,16 +20,30 @@ type Pen struct {
 	Thickness float64
 
 	i int
+
+	// bounds 所有点占用的区域，随着打点扩大
+	bounds image.Rectangle
+
+	// AntiAlias 是否对边缘做抗锯齿，创建时默认打开，参考 coverage
+	AntiAlias bool
+
+	revision
 }
 
 // NewPen 创建一个新的虚线，接口中必须传入虚线的宽度、颜色以及起点.
 func NewPen(to image.Point, color color.Color, thickness float64) *Pen {
-	c := &Pen{Color: color, Thickness: thickness}
+	c := &Pen{Color: color, Thickness: thickness, AntiAlias: true}
 	c.points = make([]image.Point, 0)
 	c.SetPoints(to)
 	return c
 }
 
+// SetAntiAlias 打开或者关闭边缘的抗锯齿
+func (c *Pen) SetAntiAlias(on bool) {
+	c.touch()
+	c.AntiAlias = on
+}
+
 // SetPoints 虚线打点
 func (c *Pen) SetPoints(to image.Point) {
 
@@ -39,6 +54,7 @@ func (c *Pen) SetPoints(to image.Point) {
 	}
 	c.sliceLock.Lock()
 	defer c.sliceLock.Unlock()
+	c.touch()
 
 	//c.points = append(c.points, to)
 	//c.i ++
@@ -76,40 +92,98 @@ func (c *Pen) SetPoints(to image.Point) {
 		for i := 1; i < (int)(deltaSize)-1; i++ {
 			point := image.Point{X: from.X + (int)((float64)(i)*(float64)(preX)*(deltaX)/deltaSize),
 				Y: from.Y + (int)((float64)(i)*(float64)(preY)*(deltaY)/deltaSize)}
-			c.points = append(c.points, point)
+			c.addPoint(point)
 		}
 
 	}
 
-	c.points = append(c.points, to)
+	c.addPoint(to)
+}
+
+// addPoint 添加一个点并扩大占用的区域，调用时必须持有 sliceLock
+func (c *Pen) addPoint(point image.Point) {
+	c.points = append(c.points, point)
+	r := c.radius()
+	c.bounds = c.bounds.Union(image.Rect(point.X-r, point.Y-r, point.X+r+1, point.Y+r+1))
 }
 
-func (c *Pen) getColor(x, y int) bool {
+// radius 每个点影响的像素范围
+func (c *Pen) radius() int {
+	return int(math.Ceil(c.Thickness*2 + 0.5))
+}
+
+// penCoverage 按照到笔迹上最近的点的距离 dist 计算像素被覆盖的比例，
+// 抗锯齿时距离笔迹边缘半个像素以内的部分按照距离线性过渡
+func penCoverage(dist, thi float64, antiAlias bool) float64 {
+	if !antiAlias {
+		if dist < thi {
+			return 1
+		}
+		return 0
+	}
+	return math.Max(0, math.Min(1, thi+0.5-dist))
+}
+
+// coverage 像素 (x, y) 被笔迹覆盖的比例: 按照到最近的点的距离计算，
+// 距离笔迹边缘半个像素以内的部分按照距离线性过渡
+func (c *Pen) coverage(x, y int) float64 {
 	c.sliceLock.Lock()
 	defer c.sliceLock.Unlock()
 
 	point := image.Point{X: x, Y: y}
 	thi := c.Thickness * 2
+	minDist := math.Inf(1)
 	for _, v := range c.points {
 		delta := v.Sub(point)
 		vector := mgl64.Vec2{float64(delta.X), float64(delta.Y)}
-		// 求出当前直线的长度
-		if vector.Len() < thi {
-			return true
-		}
+		// 求出当前点到笔迹上的点的距离
+		minDist = math.Min(minDist, vector.Len())
 	}
-	return false
+	return penCoverage(minDist, thi, c.AntiAlias)
 }
 
 // at is the function given to the filterImage object.
 // under 是当前背景图片上的当前颜色
 func (c *Pen) at(x, y int, under color.Color) color.Color {
-	if c.getColor(x, y) {
-		return c.Color
+	if !image.Pt(x, y).In(c.Bounds()) {
+		return under
+	}
+	if cov := c.coverage(x, y); cov > 0 {
+		return blend(under, c.Color, cov)
 	}
 	return under
 }
 
+// Bounds 返回笔迹占用的区域
+func (c *Pen) Bounds() image.Rectangle {
+	c.sliceLock.Lock()
+	defer c.sliceLock.Unlock()
+	return c.bounds
+}
+
+// Rasterize implements the Layer interface.
+// 不需要对每个像素查找最近的点: 每个点只绘制周围的圆形区域，重叠部分取覆盖比例的最大值
+func (c *Pen) Rasterize(dst *image.RGBA) {
+	c.sliceLock.Lock()
+	defer c.sliceLock.Unlock()
+
+	thi := c.Thickness * 2
+	r := c.radius()
+	mask := image.NewAlpha(dst.Rect)
+	for _, p := range c.points {
+		area := image.Rect(p.X-r, p.Y-r, p.X+r+1, p.Y+r+1).Intersect(mask.Rect)
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			for x := area.Min.X; x < area.Max.X; x++ {
+				cov := penCoverage(math.Hypot(float64(x-p.X), float64(y-p.Y)), thi, c.AntiAlias)
+				if a := uint8(cov*0xFF + 0.5); a > mask.AlphaAt(x, y).A {
+					mask.SetAlpha(x, y, color.Alpha{A: a})
+				}
+			}
+		}
+	}
+	draw.DrawMask(dst, dst.Rect, image.NewUniform(c.Color), image.Point{}, mask, dst.Rect.Min, draw.Src)
+}
+
 // Apply 接口ImageFilter的实现.
 // 实现方式，若是需要绘制的图，就替换为当先选中的颜色，若是不是就返回背景颜色 under
 func (c *Pen) Apply(image image.Image) image.Image {
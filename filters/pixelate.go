@@ -0,0 +1,106 @@
+package filters
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Pixelate 马赛克: 将 Source 中 Rect 区域按照 BlockSize 分块，每块使用块内的平均颜色。
+// 马赛克使用的是原始截图(而不是叠加了其它标注之后的结果)，用于遮挡敏感信息。
+type Pixelate struct {
+	Rect      image.Rectangle
+	BlockSize int
+
+	// Source 需要打马赛克的图片，通常是原始截图。为空时使用灰色填充。
+	Source image.Image
+
+	revision
+}
+
+// pixelateGray Source 为空时使用的颜色
+var pixelateGray = color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xFF}
+
+// NewPixelate creates a new Pixelate filter over rect of source, with blocks of blockSize pixels.
+func NewPixelate(source image.Image, rect image.Rectangle, blockSize int) *Pixelate {
+	if blockSize < 1 {
+		blockSize = 1
+	}
+	return &Pixelate{Rect: rect.Canon(), BlockSize: blockSize, Source: source}
+}
+
+func (c *Pixelate) SetRect(rect image.Rectangle) {
+	c.touch()
+	c.Rect = rect.Canon()
+}
+
+// SetSource 改变马赛克使用的图片，例如重新打开保存的截图之后
+func (c *Pixelate) SetSource(source image.Image) {
+	c.touch()
+	c.Source = source
+}
+
+// Bounds 返回马赛克占用的区域
+func (c *Pixelate) Bounds() image.Rectangle {
+	return c.Rect
+}
+
+// replacesUnder 马赛克直接替换下面的像素
+func (c *Pixelate) replacesUnder() bool {
+	return true
+}
+
+// block 返回包含 (x, y) 的块，块从 Rect.Min 开始对齐
+func (c *Pixelate) block(x, y int) image.Rectangle {
+	bx := c.Rect.Min.X + (x-c.Rect.Min.X)/c.BlockSize*c.BlockSize
+	by := c.Rect.Min.Y + (y-c.Rect.Min.Y)/c.BlockSize*c.BlockSize
+	return image.Rect(bx, by, bx+c.BlockSize, by+c.BlockSize).Intersect(c.Rect)
+}
+
+// average 返回 Source 中 r 区域的平均颜色
+func (c *Pixelate) average(r image.Rectangle) color.Color {
+	if c.Source == nil {
+		return pixelateGray
+	}
+	r = r.Intersect(c.Source.Bounds())
+	if r.Empty() {
+		return pixelateGray
+	}
+	var sr, sg, sb, sa uint64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			cr, cg, cb, ca := c.Source.At(x, y).RGBA()
+			sr, sg, sb, sa = sr+uint64(cr), sg+uint64(cg), sb+uint64(cb), sa+uint64(ca)
+		}
+	}
+	n := uint64(r.Dx() * r.Dy())
+	return color.RGBA64{R: uint16(sr / n), G: uint16(sg / n), B: uint16(sb / n), A: uint16(sa / n)}
+}
+
+// Rasterize implements the Layer interface. 只计算和 dst 相交的块。
+func (c *Pixelate) Rasterize(dst *image.RGBA) {
+	r := dst.Rect.Intersect(c.Rect)
+	if r.Empty() {
+		return
+	}
+	first := c.block(r.Min.X, r.Min.Y)
+	for by := first.Min.Y; by < r.Max.Y; by += c.BlockSize {
+		for bx := first.Min.X; bx < r.Max.X; bx += c.BlockSize {
+			blk := c.block(bx, by)
+			draw.Draw(dst, blk.Intersect(r), &image.Uniform{C: c.average(blk)}, image.Point{}, draw.Src)
+		}
+	}
+}
+
+// at is the function given to the filterImage object.
+func (c *Pixelate) at(x, y int, under color.Color) color.Color {
+	if !(image.Point{X: x, Y: y}).In(c.Rect) {
+		return under
+	}
+	return c.average(c.block(x, y))
+}
+
+// Apply implements the ImageFilter interface.
+func (c *Pixelate) Apply(image image.Image) image.Image {
+	return &filterImage{image, c.at}
+}
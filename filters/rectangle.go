@@ -3,6 +3,7 @@ package filters
 import (
 	"image"
 	"image/color"
+	"math"
 )
 
 type Rectangle struct {
@@ -15,54 +16,103 @@ type Rectangle struct {
 	// Thickness 指定矩形边框的宽度
 	Thickness float64
 
-	// 计算出内部矩形大小
-	rectInside image.Rectangle
+	// Radius 圆角半径，0 表示直角矩形
+	Radius float64
+
+	// Style 填充颜色、不透明度以及边框的虚线样式
+	Style ShapeStyle
+
+	// AntiAlias 是否对边缘做抗锯齿，创建时默认打开，参考 coverage
+	AntiAlias bool
+
+	revision
 }
 
 // NewRectangle creates a new Rectangle (or ellipsis) filter. It draws
 // an ellipsis whose dimensions fit the given rectangle.
 // You must specify the color and the thickness of the Rectangle to be drawn.
 func NewRectangle(rect image.Rectangle, color color.Color, thickness float64) *Rectangle {
-	c := &Rectangle{Color: color, Thickness: thickness}
+	c := &Rectangle{Color: color, Thickness: thickness, Style: DefaultShapeStyle(), AntiAlias: true}
 	c.Rect = rect
 	return c
 }
 
+// SetAntiAlias 打开或者关闭边缘的抗锯齿
+func (c *Rectangle) SetAntiAlias(on bool) {
+	c.touch()
+	c.AntiAlias = on
+}
+
 func (c *Rectangle) SetRect(rect image.Rectangle) {
+	c.touch()
 	c.Rect = rect
+}
 
-	dThickness := (int)(2 * c.Thickness)
+// SetStyle 设置填充、不透明度和虚线样式以及圆角半径
+func (c *Rectangle) SetStyle(style ShapeStyle, radius float64) {
+	c.touch()
+	c.Style = style
+	c.Radius = radius
+}
 
-	center := c.Rect.Min.Add(c.Rect.Max).Div(2)
-	if c.Rect.Dx() > dThickness {
-		c.rectInside.Min.X = c.Rect.Min.X + (int)(c.Thickness)
-		c.rectInside.Max.X = c.Rect.Max.X - (int)(c.Thickness)
-	} else {
-		c.rectInside.Min.X = center.X
-		c.rectInside.Max.X = center.X
-	}
+// Bounds 返回矩形占用的区域，包括抗锯齿的边缘
+func (c *Rectangle) Bounds() image.Rectangle {
+	return image.Rectangle{Min: c.Rect.Min.Sub(image.Pt(1, 1)), Max: c.Rect.Max.Add(image.Pt(2, 2))}
+}
 
-	if c.Rect.Dy() > dThickness {
-		c.rectInside.Min.Y = c.Rect.Min.Y + (int)(c.Thickness)
-		c.rectInside.Max.Y = c.Rect.Max.Y - (int)(c.Thickness)
-	} else {
-		c.rectInside.Min.Y = center.Y
-		c.rectInside.Max.Y = center.Y
+// roundedDistance 点到(圆角)矩形边界的有向距离，矩形内部为负数
+func (c *Rectangle) roundedDistance(fx, fy float64) float64 {
+	halfW := float64(c.Rect.Dx()) / 2
+	halfH := float64(c.Rect.Dy()) / 2
+	radius := math.Max(0, math.Min(c.Radius, math.Min(halfW, halfH)))
+	px := math.Abs(fx-float64(c.Rect.Min.X)-halfW) - (halfW - radius)
+	py := math.Abs(fy-float64(c.Rect.Min.Y)-halfH) - (halfH - radius)
+	outside := math.Hypot(math.Max(px, 0), math.Max(py, 0))
+	return outside + math.Min(math.Max(px, py), 0) - radius
+}
+
+// perimeterPos 边框上某点到左上角沿着边框顺时针方向的距离，用于计算虚线
+func (c *Rectangle) perimeterPos(fx, fy float64) float64 {
+	w, h := float64(c.Rect.Dx()), float64(c.Rect.Dy())
+	dx, dy := fx-float64(c.Rect.Min.X), fy-float64(c.Rect.Min.Y)
+	// 按照离得最近的边计算
+	top, right, bottom, left := dy, w-dx, h-dy, dx
+	switch math.Min(math.Min(top, right), math.Min(bottom, left)) {
+	case top:
+		return dx
+	case right:
+		return w + dy
+	case bottom:
+		return w + h + (w - dx)
+	default:
+		return 2*w + h + (h - dy)
 	}
+}
 
+// sample 判断点 (fx, fy) 在矩形的哪个区域
+func (c *Rectangle) sample(fx, fy float64) region {
+	dist := c.roundedDistance(fx, fy)
+	if dist > 0 {
+		return outside
+	}
+	if dist < -c.Thickness || !c.Style.Dash.on(c.perimeterPos(fx, fy), c.Thickness) {
+		return inside
+	}
+	return onStroke
 }
 
 // at is the function given to the filterImage object.
 func (c *Rectangle) at(x, y int, under color.Color) color.Color {
-	if x > c.Rect.Max.X || x < c.Rect.Min.X || y > c.Rect.Max.Y || y < c.Rect.Min.Y {
-		return under
-	}
-
-	if x > c.rectInside.Min.X && x < c.rectInside.Max.X && y > c.rectInside.Min.Y && y < c.rectInside.Max.Y {
+	if x > c.Rect.Max.X+1 || x < c.Rect.Min.X-1 || y > c.Rect.Max.Y+1 || y < c.Rect.Min.Y-1 {
 		return under
 	}
+	inner, stroke := coverage(x, y, c.AntiAlias, c.sample)
+	return c.Style.shade(under, c.Color, inner, stroke)
+}
 
-	return c.Color
+// Rasterize implements the Layer interface.
+func (c *Rectangle) Rasterize(dst *image.RGBA) {
+	rasterize(dst, c.at)
 }
 
 // Apply implements the ImageFilter interface.
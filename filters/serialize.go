@@ -0,0 +1,354 @@
+package filters
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gitee.com/andrewgithub/FireShotGo/stamps"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// 标注的序列化: 每个滤镜保存为 {"type": 类型, "data": 参数}，参数只包含重新创建滤镜需要的字段，
+// 缓存和渲染结果在恢复时重新生成。颜色保存为非预乘的 RGBA，图片保存为 PNG。
+
+// serialized 序列化之后的一个滤镜
+type serialized struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// rgba 可以序列化的颜色，nil 表示没有颜色
+type rgba *color.NRGBA
+
+func toRGBA(c color.Color) rgba {
+	if c == nil {
+		return nil
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return &n
+}
+
+func fromRGBA(c rgba) color.Color {
+	if c == nil {
+		return nil
+	}
+	return *c
+}
+
+type shapeStyleData struct {
+	Fill                       rgba `json:",omitempty"`
+	StrokeOpacity, FillOpacity float64
+	Dash                       DashStyle
+}
+
+func toShapeStyle(s ShapeStyle) shapeStyleData {
+	return shapeStyleData{Fill: toRGBA(s.Fill), StrokeOpacity: s.StrokeOpacity, FillOpacity: s.FillOpacity, Dash: s.Dash}
+}
+
+func (s shapeStyleData) style() ShapeStyle {
+	return ShapeStyle{Fill: fromRGBA(s.Fill), StrokeOpacity: s.StrokeOpacity, FillOpacity: s.FillOpacity, Dash: s.Dash}
+}
+
+type circleData struct {
+	Dim       image.Rectangle
+	Color     rgba
+	Thickness float64
+	Style     shapeStyleData
+	Aliased   bool `json:",omitempty"`
+}
+
+type rectangleData struct {
+	Rect      image.Rectangle
+	Color     rgba
+	Thickness float64
+	Radius    float64
+	Style     shapeStyleData
+	Aliased   bool `json:",omitempty"`
+}
+
+// lineData 箭头、直线和虚线共用
+type lineData struct {
+	From, To  image.Point
+	Color     rgba
+	Thickness float64
+	Caps      LineCaps
+	Style     shapeStyleData `json:",omitempty"`
+	Spacing   float64        `json:",omitempty"`
+	Aliased   bool           `json:",omitempty"`
+}
+
+type dimensionData struct {
+	From, To  image.Point
+	Color     rgba
+	Thickness float64
+	FontSize  float64
+	Aliased   bool `json:",omitempty"`
+}
+
+type shieldBlockData struct {
+	Rect  image.Rectangle
+	Color rgba
+}
+
+// pixelateData 马赛克使用的原始截图不保存，恢复之后通过 SetSource 设置
+type pixelateData struct {
+	Rect      image.Rectangle
+	BlockSize int
+}
+
+type adjustData struct {
+	Rect        image.Rectangle
+	Adjustments Adjustments
+}
+
+type penData struct {
+	Points    []image.Point
+	Color     rgba
+	Thickness float64
+	Aliased   bool `json:",omitempty"`
+}
+
+type textData struct {
+	Text              string
+	Center            image.Point
+	Color, Background rgba
+	Size              float64
+	Font              string `json:",omitempty"`
+	Bold, Italic      bool
+	Align             TextAlign
+	LineSpacing       float64
+	OutlineColor      rgba `json:",omitempty"`
+	OutlineWidth      float64
+	MaxWidth          int
+}
+
+type stampData struct {
+	Rect    image.Rectangle
+	Opacity float64
+	// Source 图章的 SVG 或者 PNG，参考 stamps.Stamp.Data
+	Source []byte
+}
+
+type imageOverlayData struct {
+	Rect         image.Rectangle
+	Source       []byte // PNG
+	BorderWidth  int
+	BorderColor  rgba
+	ShadowBlur   int
+	ShadowOffset image.Point
+	ShadowColor  rgba
+}
+
+// MarshalFilters 将滤镜序列化成 JSON，不支持的滤镜会返回错误
+func MarshalFilters(list []Filter) ([]byte, error) {
+	out := make([]serialized, 0, len(list))
+	for _, filter := range list {
+		kind, data, err := marshalFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize %s: %w", kind, err)
+		}
+		out = append(out, serialized{Type: kind, Data: raw})
+	}
+	return json.Marshal(out)
+}
+
+func marshalFilter(filter Filter) (kind string, data interface{}, err error) {
+	switch f := filter.(type) {
+	case *Circle:
+		return "circle", circleData{f.Dim, toRGBA(f.Color), f.Thickness, toShapeStyle(f.Style), !f.AntiAlias}, nil
+	case *Rectangle:
+		return "rectangle", rectangleData{f.Rect, toRGBA(f.Color), f.Thickness, f.Radius, toShapeStyle(f.Style), !f.AntiAlias}, nil
+	case *Arrow:
+		return "arrow", lineData{From: f.From, To: f.To, Color: toRGBA(f.Color), Thickness: f.Thickness,
+			Caps: f.Caps, Style: toShapeStyle(f.Style), Aliased: !f.AntiAlias}, nil
+	case *StraightLine:
+		return "line", lineData{From: f.From, To: f.To, Color: toRGBA(f.Color), Thickness: f.Thickness,
+			Caps: f.Caps, Style: toShapeStyle(f.Style), Aliased: !f.AntiAlias}, nil
+	case *DottedLine:
+		return "dotted_line", lineData{From: f.From, To: f.To, Color: toRGBA(f.Color), Thickness: f.Thickness,
+			Caps: f.Caps, Spacing: f.dottedLineSpacing, Aliased: !f.AntiAlias}, nil
+	case *Dimension:
+		return "dimension", dimensionData{f.From, f.To, toRGBA(f.Color), f.Thickness, f.FontSize, !f.line.AntiAlias}, nil
+	case *ShieldBlock:
+		return "shield_block", shieldBlockData{f.Rect, toRGBA(f.Color)}, nil
+	case *Pixelate:
+		return "pixelate", pixelateData{f.Rect, f.BlockSize}, nil
+	case *Adjust:
+		return "adjust", adjustData{f.Rect, f.Adjustments}, nil
+	case *Pen:
+		f.sliceLock.Lock()
+		points := append([]image.Point(nil), f.points...)
+		f.sliceLock.Unlock()
+		return "pen", penData{points, toRGBA(f.Color), f.Thickness, !f.AntiAlias}, nil
+	case *Text:
+		s := f.Style
+		return "text", textData{
+			Text: f.Text, Center: f.Center, Color: toRGBA(f.Color), Background: toRGBA(f.Background), Size: f.Size,
+			Font: s.Font, Bold: s.Bold, Italic: s.Italic, Align: s.Align, LineSpacing: s.LineSpacing,
+			OutlineColor: toRGBA(s.OutlineColor), OutlineWidth: s.OutlineWidth, MaxWidth: s.MaxWidth}, nil
+	case *Stamp:
+		source, ok := f.Source.(*stamps.Stamp)
+		if !ok {
+			return "stamp", nil, fmt.Errorf("can't serialize stamp source %T", f.Source)
+		}
+		content, err := source.Data()
+		if err != nil {
+			return "stamp", nil, err
+		}
+		return "stamp", stampData{f.Rect, f.Opacity, content}, nil
+	case *ImageOverlay:
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, f.Source); err != nil {
+			return "image", nil, err
+		}
+		return "image", imageOverlayData{f.Rect, buf.Bytes(), f.BorderWidth, toRGBA(f.BorderColor),
+			f.ShadowBlur, f.ShadowOffset, toRGBA(f.ShadowColor)}, nil
+	}
+	return "", nil, fmt.Errorf("can't serialize filter of type %T", filter)
+}
+
+// UnmarshalFilters 从 MarshalFilters 的结果恢复滤镜
+func UnmarshalFilters(content []byte) ([]Filter, error) {
+	var list []serialized
+	if err := json.Unmarshal(content, &list); err != nil {
+		return nil, err
+	}
+	out := make([]Filter, 0, len(list))
+	for ii, s := range list {
+		filter, err := unmarshalFilter(s)
+		if err != nil {
+			return nil, fmt.Errorf("filter #%d (%s): %w", ii, s.Type, err)
+		}
+		out = append(out, filter)
+	}
+	return out, nil
+}
+
+func unmarshalFilter(s serialized) (Filter, error) {
+	switch s.Type {
+	case "circle":
+		var d circleData
+		if err := json.Unmarshal(s.Data, &d); err != nil {
+			return nil, err
+		}
+		c := NewCircle(d.Dim, fromRGBA(d.Color), d.Thickness)
+		c.SetStyle(d.Style.style())
+		c.SetAntiAlias(!d.Aliased)
+		return c, nil
+	case "rectangle":
+		var d rectangleData
+		if err := json.Unmarshal(s.Data, &d); err != nil {
+			return nil, err
+		}
+		r := NewRectangle(d.Rect, fromRGBA(d.Color), d.Thickness)
+		r.SetStyle(d.Style.style(), d.Radius)
+		r.SetAntiAlias(!d.Aliased)
+		return r, nil
+	case "arrow", "line", "dotted_line":
+		var d lineData
+		if err := json.Unmarshal(s.Data, &d); err != nil {
+			return nil, err
+		}
+		switch s.Type {
+		case "arrow":
+			a := NewArrow(d.From, d.To, fromRGBA(d.Color), d.Thickness)
+			a.SetCaps(d.Caps)
+			a.SetStyle(d.Style.style())
+			a.SetAntiAlias(!d.Aliased)
+			return a, nil
+		case "line":
+			l := NewStraightLine(d.From, d.To, fromRGBA(d.Color), d.Thickness)
+			l.SetCaps(d.Caps)
+			l.SetStyle(d.Style.style())
+			l.SetAntiAlias(!d.Aliased)
+			return l, nil
+		}
+		l := NewDottedLine(d.From, d.To, fromRGBA(d.Color), d.Thickness, d.Spacing)
+		l.SetCaps(d.Caps)
+		l.SetAntiAlias(!d.Aliased)
+		return l, nil
+	case "dimension":
+		var d dimensionData
+		if err := json.Unmarshal(s.Data, &d); err != nil {
+			return nil, err
+		}
+		dim := NewDimension(d.From, d.To, fromRGBA(d.Color), d.Thickness, d.FontSize)
+		dim.SetAntiAlias(!d.Aliased)
+		return dim, nil
+	case "shield_block":
+		var d shieldBlockData
+		if err := json.Unmarshal(s.Data, &d); err != nil {
+			return nil, err
+		}
+		return NewShieldBlock(d.Rect, fromRGBA(d.Color)), nil
+	case "pixelate":
+		var d pixelateData
+		if err := json.Unmarshal(s.Data, &d); err != nil {
+			return nil, err
+		}
+		return NewPixelate(nil, d.Rect, d.BlockSize), nil
+	case "adjust":
+		var d adjustData
+		if err := json.Unmarshal(s.Data, &d); err != nil {
+			return nil, err
+		}
+		return NewAdjust(d.Rect, d.Adjustments), nil
+	case "pen":
+		var d penData
+		if err := json.Unmarshal(s.Data, &d); err != nil {
+			return nil, err
+		}
+		if len(d.Points) == 0 {
+			return nil, fmt.Errorf("pen without points")
+		}
+		// 保存的点已经是插值之后的结果，直接恢复
+		p := &Pen{Color: fromRGBA(d.Color), Thickness: d.Thickness, AntiAlias: !d.Aliased}
+		p.sliceLock.Lock()
+		for _, point := range d.Points {
+			p.addPoint(point)
+		}
+		p.touch()
+		p.sliceLock.Unlock()
+		return p, nil
+	case "text":
+		var d textData
+		if err := json.Unmarshal(s.Data, &d); err != nil {
+			return nil, err
+		}
+		t := NewText(d.Text, d.Center, fromRGBA(d.Color), fromRGBA(d.Background), d.Size)
+		t.SetStyle(TextStyle{Font: d.Font, Bold: d.Bold, Italic: d.Italic, Align: d.Align, LineSpacing: d.LineSpacing,
+			OutlineColor: fromRGBA(d.OutlineColor), OutlineWidth: d.OutlineWidth, MaxWidth: d.MaxWidth})
+		return t, nil
+	case "stamp":
+		var d stampData
+		if err := json.Unmarshal(s.Data, &d); err != nil {
+			return nil, err
+		}
+		source, err := stamps.Decode("", d.Source)
+		if err != nil {
+			return nil, err
+		}
+		st := NewStamp(source, d.Rect)
+		st.Opacity = d.Opacity
+		return st, nil
+	case "image":
+		var d imageOverlayData
+		if err := json.Unmarshal(s.Data, &d); err != nil {
+			return nil, err
+		}
+		source, err := png.Decode(bytes.NewReader(d.Source))
+		if err != nil {
+			return nil, err
+		}
+		o := NewImageOverlay(source, d.Rect)
+		o.SetDecoration(d.BorderWidth, fromRGBA(d.BorderColor), d.ShadowBlur, d.ShadowOffset, fromRGBA(d.ShadowColor))
+		return o, nil
+	}
+	return nil, fmt.Errorf("unknown filter type %q", s.Type)
+}
@@ -0,0 +1,95 @@
+package filters
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// serializeScene 每种可以序列化的滤镜各一个，使用非默认的样式
+func serializeScene() []Filter {
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+	blue := color.RGBA{B: 0xFF, A: 0x80}
+
+	circle := NewCircle(image.Rect(10, 10, 110, 60), red, 3)
+	circle.SetStyle(ShapeStyle{Fill: blue, StrokeOpacity: 0.5, FillOpacity: 0.25, Dash: DashDotted})
+	rect := NewRectangle(image.Rect(20, 30, 220, 130), red, 2)
+	rect.SetStyle(ShapeStyle{StrokeOpacity: 1, FillOpacity: 1, Dash: DashDashed}, 8)
+	rect.SetAntiAlias(false)
+	arrow := NewArrow(image.Pt(5, 5), image.Pt(150, 90), red, 4)
+	arrow.SetCaps(LineCaps{Tail: CapDot, Head: CapOpenTriangle})
+	line := NewStraightLine(image.Pt(0, 100), image.Pt(200, 100), blue, 1)
+	line.SetCaps(LineCaps{Tail: CapSquare, Head: CapTriangle})
+	line.SetStyle(ShapeStyle{StrokeOpacity: 0.75, Dash: DashDashDot})
+	dotted := NewDottedLine(image.Pt(10, 200), image.Pt(300, 250), red, 2, 6)
+	dotted.SetCaps(LineCaps{Head: CapTriangle})
+	pen := NewPen(image.Pt(50, 50), red, 1.5)
+	pen.SetPoints(image.Pt(80, 70))
+	pen.SetPoints(image.Pt(120, 60))
+	pen.SetAntiAlias(false)
+	text := NewText("多行\nText", image.Pt(150, 150), red, blue, 18)
+	text.SetStyle(TextStyle{Bold: true, Align: AlignRight, LineSpacing: 1.5,
+		OutlineColor: color.RGBA{A: 0xFF}, OutlineWidth: 2, MaxWidth: 120})
+	overlay := NewImageOverlay(image.NewRGBA(image.Rect(0, 0, 16, 8)), image.Rect(200, 200, 232, 216))
+
+	return []Filter{
+		circle, rect, arrow, line, dotted,
+		NewDimension(image.Pt(10, 300), image.Pt(210, 300), red, 1, 12),
+		NewShieldBlock(image.Rect(0, 0, 40, 20), color.RGBA{A: 0xFF}),
+		NewPixelate(nil, image.Rect(40, 40, 90, 80), 8),
+		NewAdjust(image.Rect(0, 0, 100, 100), Adjustments{Brightness: 0.1, Gamma: 1.2, Invert: true, Sharpen: 0.5}),
+		pen, text, overlay,
+	}
+}
+
+// TestMarshalFiltersRoundTrip 序列化之后恢复的滤镜必须和原来的相同: 类型相同，再次序列化的结果也相同
+func TestMarshalFiltersRoundTrip(t *testing.T) {
+	list := serializeScene()
+	content, err := MarshalFilters(list)
+	if err != nil {
+		t.Fatalf("MarshalFilters() failed: %v", err)
+	}
+	restored, err := UnmarshalFilters(content)
+	if err != nil {
+		t.Fatalf("UnmarshalFilters() failed: %v", err)
+	}
+	if len(restored) != len(list) {
+		t.Fatalf("UnmarshalFilters() returned %d filters, want %d", len(restored), len(list))
+	}
+	for ii := range list {
+		if got, want := fmt.Sprintf("%T", restored[ii]), fmt.Sprintf("%T", list[ii]); got != want {
+			t.Errorf("filter #%d: got %s, want %s", ii, got, want)
+		}
+	}
+	again, err := MarshalFilters(restored)
+	if err != nil {
+		t.Fatalf("MarshalFilters() of the restored filters failed: %v", err)
+	}
+	if !bytes.Equal(again, content) {
+		t.Errorf("restored filters serialize differently:\n got %s\nwant %s", again, content)
+	}
+}
+
+// TestUnmarshalFiltersErrors 损坏的内容或者不认识的滤镜必须返回错误
+func TestUnmarshalFiltersErrors(t *testing.T) {
+	for _, content := range []string{
+		`not json`,
+		`[{"type":"hologram","data":{}}]`,
+		`[{"type":"circle","data":"not an object"}]`,
+		`[{"type":"pen","data":{"Points":[]}}]`,
+	} {
+		if _, err := UnmarshalFilters([]byte(content)); err == nil {
+			t.Errorf("UnmarshalFilters(%s) succeeded, want an error", content)
+		}
+	}
+	if _, err := MarshalFilters([]Filter{filterFunc(nil)}); err == nil {
+		t.Error("MarshalFilters() of an unsupported filter succeeded, want an error")
+	}
+}
+
+// filterFunc 不支持序列化的滤镜
+type filterFunc func(image.Image) image.Image
+
+func (f filterFunc) Apply(img image.Image) image.Image { return img }
@@ -0,0 +1,129 @@
+package filters
+
+import (
+	"image/color"
+	"math"
+)
+
+// DashStyle 图形轮廓的虚线样式，间隔按照线宽的倍数计算
+type DashStyle int
+
+const (
+	// DashSolid 实线
+	DashSolid DashStyle = iota
+	// DashDashed 虚线
+	DashDashed
+	// DashDotted 点线
+	DashDotted
+	// DashDashDot 点划线
+	DashDashDot
+)
+
+// DashStyleNames 虚线样式的显示名称，下标和 DashStyle 的取值一一对应
+var DashStyleNames = []string{"实线", "虚线", "点线", "点划线"}
+
+// dashPatterns 每种样式交替的 实线/间隔 长度，单位是线宽
+var dashPatterns = [][]float64{
+	nil,
+	{4, 2},
+	{1, 1},
+	{4, 1.5, 1, 1.5},
+}
+
+// String implements fmt.Stringer.
+func (ds DashStyle) String() string {
+	if ds < 0 || int(ds) >= len(DashStyleNames) {
+		return "未知"
+	}
+	return DashStyleNames[ds]
+}
+
+// ParseDashStyle 通过显示名称查找虚线样式，找不到返回 DashSolid
+func ParseDashStyle(name string) DashStyle {
+	for ii, n := range DashStyleNames {
+		if n == name {
+			return DashStyle(ii)
+		}
+	}
+	return DashSolid
+}
+
+// on 判断轮廓上距离起点 pos 像素的位置是否需要绘制
+func (ds DashStyle) on(pos, thickness float64) bool {
+	if ds <= DashSolid || int(ds) >= len(dashPatterns) {
+		return true
+	}
+	if thickness < 1 {
+		thickness = 1
+	}
+	pattern := dashPatterns[ds]
+	var period float64
+	for _, v := range pattern {
+		period += v * thickness
+	}
+	pos = math.Mod(pos, period)
+	if pos < 0 {
+		pos += period
+	}
+	for ii, v := range pattern {
+		pos -= v * thickness
+		if pos < 0 {
+			return ii%2 == 0
+		}
+	}
+	return true
+}
+
+// ShapeStyle 图形的绘制样式: 填充颜色、轮廓和填充的不透明度以及轮廓的虚线样式
+type ShapeStyle struct {
+	// Fill 填充颜色，nil 或者完全透明表示不填充
+	Fill color.Color
+
+	// StrokeOpacity, FillOpacity 轮廓和填充的不透明度，取值 [0, 1]
+	StrokeOpacity, FillOpacity float64
+
+	// Dash 轮廓的虚线样式
+	Dash DashStyle
+}
+
+// DefaultShapeStyle 默认样式: 不填充，完全不透明的实线轮廓
+func DefaultShapeStyle() ShapeStyle {
+	return ShapeStyle{StrokeOpacity: 1, FillOpacity: 1}
+}
+
+// hasFill 是否需要填充
+func (s ShapeStyle) hasFill() bool {
+	if s.Fill == nil || s.FillOpacity <= 0 {
+		return false
+	}
+	_, _, _, a := s.Fill.RGBA()
+	return a > 0
+}
+
+// blend 按照不透明度 opacity 将 over 叠加到 under 之上
+func blend(under, over color.Color, opacity float64) color.Color {
+	if opacity <= 0 {
+		return under
+	}
+	const M = 1<<16 - 1
+	overR, overG, overB, overA := over.RGBA()
+	if opacity >= 1 && overA == M {
+		return over
+	}
+	if opacity > 1 {
+		opacity = 1
+	}
+	underR, underG, underB, underA := under.RGBA()
+	op := uint32(opacity*M + 0.5)
+	// RGBA() 返回的是预乘之后的颜色值
+	inv := M - overA*op/M
+	mix := func(u, o uint32) uint16 {
+		return uint16((o*op + u*inv) / M)
+	}
+	return color.RGBA64{
+		R: mix(underR, overR),
+		G: mix(underG, overG),
+		B: mix(underB, overB),
+		A: mix(underA, overA),
+	}
+}
@@ -11,6 +11,8 @@ type ShieldBlock struct {
 
 	// Color of the ShieldBlock to be drawn.
 	Color color.Color
+
+	revision
 }
 
 // NewShieldBlock creates a new ShieldBlock (or ellipsis) filter. It draws
@@ -23,9 +25,25 @@ func NewShieldBlock(rect image.Rectangle, color color.Color) *ShieldBlock {
 }
 
 func (c *ShieldBlock) SetRect(rect image.Rectangle) {
+	c.touch()
 	c.Rect = rect
 }
 
+// Bounds 返回遮挡块占用的区域
+func (c *ShieldBlock) Bounds() image.Rectangle {
+	return image.Rectangle{Min: c.Rect.Min, Max: c.Rect.Max.Add(image.Pt(1, 1))}
+}
+
+// replacesUnder 遮挡块直接替换下面的像素，即使颜色是半透明的
+func (c *ShieldBlock) replacesUnder() bool {
+	return true
+}
+
+// Rasterize implements the Layer interface.
+func (c *ShieldBlock) Rasterize(dst *image.RGBA) {
+	rasterize(dst, c.at)
+}
+
 // at is the function given to the filterImage object.
 func (c *ShieldBlock) at(x, y int, under color.Color) color.Color {
 	if x > c.Rect.Max.X || x < c.Rect.Min.X || y > c.Rect.Max.Y || y < c.Rect.Min.Y {
@@ -0,0 +1,72 @@
+package filters
+
+import (
+	"image"
+	"image/color"
+)
+
+// StampSource 图章的来源，可以按照任意大小渲染(参考 stamps.Stamp)
+type StampSource interface {
+	// Render 渲染宽为 w 高为 h 的图片
+	Render(w, h int) *image.RGBA
+}
+
+// Stamp 在截图上放置图章(贴纸)，图章会在大小改变的时候重新渲染，所以矢量图章在任何大小下都是清晰的
+type Stamp struct {
+	// Rect 图章所在的区域
+	Rect image.Rectangle
+
+	// Source 图章的来源
+	Source StampSource
+
+	// Opacity 图章的不透明度，0 到 1
+	Opacity float64
+
+	rendered *image.RGBA
+
+	revision
+}
+
+// NewStamp creates a stamp filter that draws source inside rect.
+func NewStamp(source StampSource, rect image.Rectangle) *Stamp {
+	s := &Stamp{Source: source, Opacity: 1}
+	s.SetRect(rect)
+	return s
+}
+
+// SetRect 改变图章的位置和大小，大小改变时重新渲染
+func (s *Stamp) SetRect(rect image.Rectangle) {
+	s.touch()
+	rect = rect.Canon()
+	if s.rendered == nil || rect.Dx() != s.Rect.Dx() || rect.Dy() != s.Rect.Dy() {
+		s.rendered = s.Source.Render(rect.Dx(), rect.Dy())
+	}
+	s.Rect = rect
+}
+
+// Bounds 返回图章在截图中占用的区域
+func (s *Stamp) Bounds() image.Rectangle {
+	return s.Rect
+}
+
+// at is the function given to the filterImage object.
+func (s *Stamp) at(x, y int, under color.Color) color.Color {
+	if !image.Pt(x, y).In(s.Rect) {
+		return under
+	}
+	over := s.rendered.RGBAAt(x-s.Rect.Min.X, y-s.Rect.Min.Y)
+	if over.A == 0 {
+		return under
+	}
+	return blend(under, over, s.Opacity)
+}
+
+// Rasterize implements the Layer interface.
+func (s *Stamp) Rasterize(dst *image.RGBA) {
+	rasterize(dst, s.at)
+}
+
+// Apply implements the ImageFilter interface.
+func (s *Stamp) Apply(image image.Image) image.Image {
+	return &filterImage{image, s.at}
+}
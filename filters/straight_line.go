@@ -19,23 +19,41 @@ type StraightLine struct {
 	// Thickness 指定直线的宽度
 	Thickness float64
 
+	// Caps 两端的样式，默认两端都没有端点
+	Caps LineCaps
+
+	// Style 不透明度以及线条主体的虚线样式，线条没有填充
+	Style ShapeStyle
+
 	// Rectangle enclosing straight line.
 	rect image.Rectangle
 
 	rebaseMatrix mgl64.Mat3
 	// 按照矢量求出当前绘制的长度
 	vectorLength float64
+
+	// AntiAlias 是否对边缘做抗锯齿，创建时默认打开，参考 coverage
+	AntiAlias bool
+
+	revision
 }
 
 // NewStraightLine 创建一个新的直线，接口中国捏必须传入直线的宽度、颜色以及起点.
 func NewStraightLine(from, to image.Point, color color.Color, thickness float64) *StraightLine {
-	c := &StraightLine{Color: color, Thickness: thickness}
+	c := &StraightLine{Color: color, Thickness: thickness, Style: DefaultShapeStyle(), AntiAlias: true}
 	c.SetPoints(from, to)
 	return c
 }
 
+// SetAntiAlias 打开或者关闭边缘的抗锯齿
+func (c *StraightLine) SetAntiAlias(on bool) {
+	c.touch()
+	c.AntiAlias = on
+}
+
 // SetPoints 图形打点
 func (c *StraightLine) SetPoints(from, to image.Point) {
+	c.touch()
 	if to.X == from.X && to.Y == from.Y {
 		to.X += 1 // 保证直线最少为一个像素大小，否则图像上显示不出来
 	}
@@ -44,7 +62,7 @@ func (c *StraightLine) SetPoints(from, to image.Point) {
 	c.rect = image.Rectangle{Min: from, Max: to}.Canon()
 	// 保证线条的宽度，在做矩阵转换的时候能保证起点和结束点的宽度一致
 	// 设置线条的宽度
-	headExtraPixels := int(c.Thickness)
+	headExtraPixels := c.Caps.extent(c.Thickness)
 	c.rect.Min.X -= headExtraPixels
 	c.rect.Min.Y -= headExtraPixels
 	c.rect.Max.X += headExtraPixels
@@ -68,6 +86,32 @@ func (c *StraightLine) SetPoints(from, to image.Point) {
 		mgl64.Translate2D(float64(-c.From.X), float64(-c.From.Y)))
 }
 
+// SetCaps 设置两端的样式，修改之后需要重新计算包围矩形
+func (c *StraightLine) SetCaps(caps LineCaps) {
+	c.Caps = caps
+	c.SetPoints(c.From, c.To)
+}
+
+// SetStyle 设置不透明度和虚线样式
+func (c *StraightLine) SetStyle(style ShapeStyle) {
+	c.touch()
+	c.Style = style
+}
+
+// sample 判断点 (fx, fy) 是否在直线上，线条没有内部区域
+func (c *StraightLine) sample(fx, fy float64) region {
+	p := c.rebaseMatrix.Mul3x1(mgl64.Vec3{fx, fy, 1.0})
+	if c.Caps.contains(p.X(), p.Y(), c.vectorLength, c.Thickness) {
+		return onStroke
+	}
+	// 端点为实心三角形时，线条主体只绘制剩余的部分
+	start, end := c.Caps.bodyRange(c.vectorLength, c.Thickness)
+	if p.X() >= start && p.X() < end && math.Abs(p.Y()) < c.Thickness/2 && c.Style.Dash.on(p.X()-start, c.Thickness) {
+		return onStroke
+	}
+	return outside
+}
+
 // at is the function given to the filterImage object.
 func (c *StraightLine) at(x, y int, under color.Color) color.Color {
 	if x > c.rect.Max.X || x < c.rect.Min.X || y > c.rect.Max.Y || y < c.rect.Min.Y {
@@ -97,17 +141,18 @@ func (c *StraightLine) at(x, y int, under color.Color) color.Color {
 		}
 	}
 
-	if homogPoint.X() < 0 {
-		return under
-	}
+	_, stroke := coverage(x, y, c.AntiAlias, c.sample)
+	return c.Style.shade(under, c.Color, 0, stroke)
+}
 
-	if homogPoint.X() < c.vectorLength {
-		if math.Abs(homogPoint.Y()) < c.Thickness/2 {
-			return c.Color
-		}
-	}
+// Bounds 返回直线占用的区域，包括两端的端点
+func (c *StraightLine) Bounds() image.Rectangle {
+	return image.Rectangle{Min: c.rect.Min, Max: c.rect.Max.Add(image.Pt(1, 1))}
+}
 
-	return under
+// Rasterize implements the Layer interface.
+func (c *StraightLine) Rasterize(dst *image.RGBA) {
+	rasterizeLine(dst, c.rebaseMatrix, float64(c.Caps.extent(c.Thickness))+1, c.at)
 }
 
 // Apply 接口ImageFilter的实现.
@@ -0,0 +1,363 @@
+package filters
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"github.com/golang/glog"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SVGElement 可以导出为 SVG 矢量元素的滤镜(参考 WriteSVG)
+type SVGElement interface {
+	// SVG 返回滤镜对应的 SVG 元素，使用截图的坐标
+	SVG() string
+}
+
+// WriteSVG 将截图导出为 SVG: 裁剪之后的原始截图以 base64 PNG 的形式嵌入为 <image>，
+// 每个标注导出为对应的 SVG 元素，在高分屏上放大之后仍然清晰，并且可以在 Inkscape 中继续编辑。
+// 不能导出为矢量的滤镜(例如图章、插入的图片)光栅化之后作为 <image> 嵌入。
+//
+// 遮挡块和马赛克不能作为单独的元素导出，否则在编辑器中删除它们就能看到下面的内容:
+// 最后一个遮挡块或者马赛克以及之前的所有滤镜合成到嵌入的截图中，只有之后的滤镜作为单独的元素导出。
+func WriteSVG(w io.Writer, base image.Image, crop image.Rectangle, filters []Filter) error {
+	crop = crop.Intersect(base.Bounds())
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%d" height="%d" viewBox="%d %d %d %d">
+`, crop.Dx(), crop.Dy(), crop.Min.X, crop.Min.Y, crop.Dx(), crop.Dy())
+
+	// 所有的合成共用一个合成器: 每次只增加了后面的滤镜，只有它们所在的分块重新合成
+	var compositor *Compositor
+	flattened := redactedPrefix(filters)
+	img := base
+	if flattened > 0 {
+		compositor = NewCompositor()
+		compositor.Compose(base, filters[:flattened])
+		img = compositor.Image()
+	}
+	screenshot, err := svgImage(img, crop, "screenshot")
+	if err != nil {
+		return err
+	}
+	bw.WriteString(screenshot)
+
+	bw.WriteString(`<g id="annotations">` + "\n")
+	for ii := flattened; ii < len(filters); ii++ {
+		filter := filters[ii]
+		var element string
+		switch f := filter.(type) {
+		case SVGElement:
+			element = f.SVG()
+		case Adjuster:
+			// 调整依赖下面的像素: 合成到这个滤镜为止的结果，调整的区域作为 <image> 嵌入
+			bounds := f.Bounds().Intersect(crop)
+			if bounds.Empty() {
+				continue
+			}
+			if compositor == nil {
+				compositor = NewCompositor()
+			}
+			compositor.Compose(base, filters[:ii+1])
+			if element, err = svgImage(compositor.Image(), bounds, fmt.Sprintf("adjustment-%d", ii)); err != nil {
+				return err
+			}
+		case Layer:
+			// 没有对应的矢量元素: 光栅化之后嵌入
+			bounds := f.Bounds().Intersect(crop)
+			if bounds.Empty() {
+				continue
+			}
+			layer := image.NewRGBA(bounds)
+			f.Rasterize(layer)
+			if element, err = svgImage(layer, bounds, fmt.Sprintf("annotation-%d", ii)); err != nil {
+				return err
+			}
+		default:
+			glog.Warningf("WriteSVG(): filter %T can't be exported to SVG, skipped", filter)
+			continue
+		}
+		bw.WriteString(element)
+	}
+	bw.WriteString("</g>\n</svg>\n")
+	return bw.Flush()
+}
+
+// redactedPrefix 返回到最后一个替换下面像素的图层(遮挡块、马赛克)为止的滤镜个数，没有时返回 0
+func redactedPrefix(filters []Filter) int {
+	n := 0
+	for ii, filter := range filters {
+		if r, ok := filter.(replacer); ok && r.replacesUnder() {
+			n = ii + 1
+		}
+	}
+	return n
+}
+
+// svgImage 将图片 img 中 r 的部分编码为 PNG，返回嵌入图片的 <image> 元素
+func svgImage(img image.Image, r image.Rectangle, id string) (string, error) {
+	sub := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(sub, sub.Rect, img, r.Min, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, sub); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`<image id="%s" x="%d" y="%d" width="%d" height="%d" xlink:href="data:image/png;base64,%s"/>
+`, id, r.Min.X, r.Min.Y, r.Dx(), r.Dy(), base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+// svgNum 格式化 SVG 中的数字，保留两位小数并去掉多余的 0
+func svgNum(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// svgPaint 返回 SVG 的颜色属性，例如 fill="#ff0000" fill-opacity="0.5"。
+// attr 是 "fill" 或者 "stroke"，颜色自身的透明度和 opacity 相乘。
+func svgPaint(attr string, c color.Color, opacity float64) string {
+	if c == nil {
+		return fmt.Sprintf(` %s="none"`, attr)
+	}
+	nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+	alpha := float64(nc.A) / 0xFF * opacity
+	if alpha <= 0 {
+		return fmt.Sprintf(` %s="none"`, attr)
+	}
+	paint := fmt.Sprintf(` %s="#%02x%02x%02x"`, attr, nc.R, nc.G, nc.B)
+	if alpha < 1 {
+		paint += fmt.Sprintf(` %s-opacity="%s"`, attr, svgNum(alpha))
+	}
+	return paint
+}
+
+// svgDash 返回虚线样式对应的 stroke-dasharray 属性，实线返回空字符串
+func svgDash(ds DashStyle, thickness float64) string {
+	if ds <= DashSolid || int(ds) >= len(dashPatterns) {
+		return ""
+	}
+	if thickness < 1 {
+		thickness = 1
+	}
+	parts := make([]string, len(dashPatterns[ds]))
+	for ii, v := range dashPatterns[ds] {
+		parts[ii] = svgNum(v * thickness)
+	}
+	return fmt.Sprintf(` stroke-dasharray="%s"`, strings.Join(parts, " "))
+}
+
+// SVG implements the SVGElement interface.
+func (c *Circle) SVG() string {
+	rx := math.Max(c.outerRadius.X()-c.Thickness/2, 0)
+	ry := math.Max(c.outerRadius.Y()-c.Thickness/2, 0)
+	fill := ` fill="none"`
+	if c.Style.hasFill() {
+		fill = svgPaint("fill", c.Style.Fill, c.Style.FillOpacity)
+	}
+	return fmt.Sprintf(`<ellipse cx="%s" cy="%s" rx="%s" ry="%s"%s%s stroke-width="%s"%s/>
+`, svgNum(c.Center.X()), svgNum(c.Center.Y()), svgNum(rx), svgNum(ry), fill,
+		svgPaint("stroke", c.Color, c.Style.StrokeOpacity), svgNum(c.Thickness), svgDash(c.Style.Dash, c.Thickness))
+}
+
+// SVG implements the SVGElement interface.
+func (c *Rectangle) SVG() string {
+	r := c.Rect.Canon()
+	w, h := float64(r.Dx())-c.Thickness, float64(r.Dy())-c.Thickness
+	if w <= 0 || h <= 0 {
+		// 边框比矩形还宽: 整个矩形都是边框的颜色
+		return fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d"%s/>
+`, r.Min.X, r.Min.Y, r.Dx(), r.Dy(), svgPaint("fill", c.Color, c.Style.StrokeOpacity))
+	}
+	radius := ""
+	if c.Radius > 0 {
+		radius = fmt.Sprintf(` rx="%s"`, svgNum(math.Max(c.Radius-c.Thickness/2, 0)))
+	}
+	fill := ` fill="none"`
+	if c.Style.hasFill() {
+		fill = svgPaint("fill", c.Style.Fill, c.Style.FillOpacity)
+	}
+	return fmt.Sprintf(`<rect x="%s" y="%s" width="%s" height="%s"%s%s%s stroke-width="%s"%s/>
+`, svgNum(float64(r.Min.X)+c.Thickness/2), svgNum(float64(r.Min.Y)+c.Thickness/2), svgNum(w), svgNum(h),
+		radius, fill, svgPaint("stroke", c.Color, c.Style.StrokeOpacity), svgNum(c.Thickness), svgDash(c.Style.Dash, c.Thickness))
+}
+
+// SVG implements the SVGElement interface.
+func (c *ShieldBlock) SVG() string {
+	r := c.Bounds().Canon()
+	return fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d"%s/>
+`, r.Min.X, r.Min.Y, r.Dx(), r.Dy(), svgPaint("fill", c.Color, 1))
+}
+
+// SVG implements the SVGElement interface.
+func (c *Arrow) SVG() string {
+	return lineSVG(c.From, c.To, c.Thickness, c.Caps, c.Color, c.Style.StrokeOpacity, svgDash(c.Style.Dash, c.Thickness), 0)
+}
+
+// SVG implements the SVGElement interface.
+func (c *StraightLine) SVG() string {
+	return lineSVG(c.From, c.To, c.Thickness, c.Caps, c.Color, c.Style.StrokeOpacity, svgDash(c.Style.Dash, c.Thickness), 0)
+}
+
+// SVG implements the SVGElement interface.
+func (c *DottedLine) SVG() string {
+	spacing := svgNum(math.Max(c.dottedLineSpacing, 0.01))
+	// 虚线的间隔从起点 From 开始计算，而不是线条主体的起点
+	start, _ := c.Caps.bodyRange(c.vectorLength, c.Thickness)
+	return lineSVG(c.From, c.To, c.Thickness, c.Caps, c.Color, 1,
+		fmt.Sprintf(` stroke-dasharray="%s %s"`, spacing, spacing), start)
+}
+
+// lineSVG 导出线条(箭头、直线、虚线): 线条主体和两端的端点放在一个 <g> 中，
+// 不透明度设置在 <g> 上，这样主体和端点重叠的部分不会叠加两次。
+// dashOffset 是虚线图案在线条主体起点的偏移。
+func lineSVG(from, to image.Point, thickness float64, caps LineCaps, c color.Color, opacity float64, dash string, dashOffset float64) string {
+	nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+	opacity *= float64(nc.A) / 0xFF
+	nc.A = 0xFF
+	stroke := svgPaint("stroke", nc, 1)
+	fill := svgPaint("fill", nc, 1)
+
+	dx, dy := float64(to.X-from.X), float64(to.Y-from.Y)
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		dx, length = 1, 1
+	}
+	// 沿线条方向的单位向量和法向量
+	ux, uy := dx/length, dy/length
+	nx, ny := -uy, ux
+	point := func(u, v float64) string {
+		return svgNum(float64(from.X)+ux*u+nx*v) + "," + svgNum(float64(from.Y)+uy*u+ny*v)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<g")
+	if opacity < 1 {
+		sb.WriteString(fmt.Sprintf(` opacity="%s"`, svgNum(opacity)))
+	}
+	sb.WriteString(">\n")
+
+	start, end := caps.bodyRange(length, thickness)
+	if end > start {
+		offset := ""
+		if dash != "" && dashOffset != 0 {
+			offset = fmt.Sprintf(` stroke-dashoffset="%s"`, svgNum(dashOffset))
+		}
+		x1, y1 := float64(from.X)+ux*start, float64(from.Y)+uy*start
+		x2, y2 := float64(from.X)+ux*end, float64(from.Y)+uy*end
+		sb.WriteString(fmt.Sprintf(`  <line x1="%s" y1="%s" x2="%s" y2="%s"%s stroke-width="%s"%s%s/>
+`, svgNum(x1), svgNum(y1), svgNum(x2), svgNum(y2), stroke, svgNum(thickness), dash, offset))
+	}
+
+	capLength, capWidth := caps.dims(thickness)
+	halfWidth := capWidth / 2
+	// capAt 在 tip 处绘制端点，inward 是从端点指向线条内部的方向(+1 或者 -1)
+	capAt := func(style LineCap, tip, inward float64) {
+		switch style {
+		case CapTriangle:
+			sb.WriteString(fmt.Sprintf(`  <polygon points="%s %s %s"%s/>
+`, point(tip, 0), point(tip+inward*capLength, halfWidth), point(tip+inward*capLength, -halfWidth), fill))
+		case CapOpenTriangle:
+			sb.WriteString(fmt.Sprintf(`  <polyline points="%s %s %s" fill="none"%s stroke-width="%s" stroke-linecap="round" stroke-linejoin="round"/>
+`, point(tip+inward*capLength, halfWidth), point(tip, 0), point(tip+inward*capLength, -halfWidth), stroke, svgNum(thickness)))
+		case CapDot:
+			x, y := float64(from.X)+ux*tip, float64(from.Y)+uy*tip
+			sb.WriteString(fmt.Sprintf(`  <circle cx="%s" cy="%s" r="%s"%s/>
+`, svgNum(x), svgNum(y), svgNum(halfWidth), fill))
+		case CapSquare:
+			sb.WriteString(fmt.Sprintf(`  <polygon points="%s %s %s %s"%s/>
+`, point(tip-halfWidth, -halfWidth), point(tip+halfWidth, -halfWidth), point(tip+halfWidth, halfWidth), point(tip-halfWidth, halfWidth), fill))
+		case CapBar:
+			x, y := float64(from.X)+ux*tip, float64(from.Y)+uy*tip
+			sb.WriteString(fmt.Sprintf(`  <line x1="%s" y1="%s" x2="%s" y2="%s"%s stroke-width="%s"/>
+`, svgNum(x-nx*halfWidth), svgNum(y-ny*halfWidth), svgNum(x+nx*halfWidth), svgNum(y+ny*halfWidth), stroke, svgNum(thickness)))
+		}
+	}
+	capAt(caps.Tail, 0, 1)
+	capAt(caps.Head, length, -1)
+	sb.WriteString("</g>\n")
+	return sb.String()
+}
+
+// SVG implements the SVGElement interface.
+// 笔迹导出为一条圆角的折线
+func (c *Pen) SVG() string {
+	c.sliceLock.Lock()
+	defer c.sliceLock.Unlock()
+	if len(c.points) == 0 {
+		return ""
+	}
+	width := c.Thickness * 4
+	if len(c.points) == 1 {
+		return fmt.Sprintf(`<circle cx="%d" cy="%d" r="%s"%s/>
+`, c.points[0].X, c.points[0].Y, svgNum(width/2), svgPaint("fill", c.Color, 1))
+	}
+	points := make([]string, len(c.points))
+	for ii, p := range c.points {
+		points[ii] = fmt.Sprintf("%d,%d", p.X, p.Y)
+	}
+	return fmt.Sprintf(`<polyline points="%s" fill="none"%s stroke-width="%s" stroke-linecap="round" stroke-linejoin="round"/>
+`, strings.Join(points, " "), svgPaint("stroke", c.Color, 1), svgNum(width))
+}
+
+// SVG implements the SVGElement interface.
+// 每一行是一个 <tspan>，对齐方式使用 text-anchor，描边使用 paint-order 画在文字下面。
+func (t *Text) SVG() string {
+	var sb strings.Builder
+	sb.WriteString("<g>\n")
+	origin := t.rect.Min
+	if t.Background != nil {
+		if _, _, _, a := t.Background.RGBA(); a > 0 {
+			sb.WriteString(fmt.Sprintf(`  <rect x="%d" y="%d" width="%d" height="%d"%s/>
+`, origin.X, origin.Y, t.renderedText.Rect.Dx(), t.renderedText.Rect.Dy(), svgPaint("fill", t.Background, 1)))
+		}
+	}
+
+	fontName := t.Style.Font
+	if fontName == "" {
+		fontName = DefaultFontName
+	}
+	attrs := fmt.Sprintf(` font-family="%s, sans-serif" font-size="%spx"`, xmlEscape(fontName), svgNum(t.Size*DPI/72))
+	if t.Style.Bold {
+		attrs += ` font-weight="bold"`
+	}
+	if t.Style.Italic {
+		attrs += ` font-style="italic"`
+	}
+	layout := t.layout
+	x := float64(origin.X + layout.pad)
+	switch t.Style.Align {
+	case AlignCenter:
+		attrs += ` text-anchor="middle"`
+		x += float64(layout.textWidth) / 2
+	case AlignRight:
+		attrs += ` text-anchor="end"`
+		x += float64(layout.textWidth)
+	}
+	attrs += svgPaint("fill", t.Color, 1)
+	if t.Style.OutlineColor != nil && t.Style.OutlineWidth > 0 {
+		attrs += svgPaint("stroke", t.Style.OutlineColor, 1)
+		attrs += fmt.Sprintf(` stroke-width="%s" stroke-linejoin="round" paint-order="stroke"`, svgNum(2*t.Style.OutlineWidth))
+	}
+
+	sb.WriteString(fmt.Sprintf(`  <text xml:space="preserve"%s>`, attrs))
+	for ii, line := range layout.lines {
+		y := origin.Y + layout.pad + layout.ascent + ii*layout.lineHeight
+		sb.WriteString(fmt.Sprintf(`<tspan x="%s" y="%d">%s</tspan>`, svgNum(x), y, xmlEscape(line)))
+	}
+	sb.WriteString("</text>\n</g>\n")
+	return sb.String()
+}
+
+// xmlEscape 转义 XML 中的特殊字符
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,133 @@
+package filters
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// svgImagePattern 匹配 svgImage 输出的 <image> 元素，取出 id 和 base64 PNG
+var svgImagePattern = regexp.MustCompile(`<image id="([^"]+)" x="(-?\d+)" y="(-?\d+)"[^>]*xlink:href="data:image/png;base64,([^"]+)"/>`)
+
+// svgImages 解码 SVG 中嵌入的所有图片，按照 id 返回
+func svgImages(t *testing.T, svg string) map[string]image.Image {
+	t.Helper()
+	images := make(map[string]image.Image)
+	for _, m := range svgImagePattern.FindAllStringSubmatch(svg, -1) {
+		data, err := base64.StdEncoding.DecodeString(m[4])
+		if err != nil {
+			t.Fatalf("image %q: invalid base64: %v", m[1], err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("image %q: invalid PNG: %v", m[1], err)
+		}
+		images[m[1]] = img
+	}
+	return images
+}
+
+// noiseImage 随机颜色的不透明图片，没有黑色的像素
+func noiseImage(rect image.Rectangle) *image.RGBA {
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(rect)
+	for ii := range img.Pix {
+		img.Pix[ii] = uint8(rng.Intn(256))
+	}
+	for ii := 0; ii < len(img.Pix); ii += 4 {
+		img.Pix[ii] |= 1
+		img.Pix[ii+3] = 0xFF
+	}
+	return img
+}
+
+func writeSVGString(t *testing.T, base image.Image, crop image.Rectangle, filters []Filter) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteSVG(&buf, base, crop, filters); err != nil {
+		t.Fatalf("WriteSVG(): %v", err)
+	}
+	return buf.String()
+}
+
+func TestWriteSVGRedactions(t *testing.T) {
+	base := noiseImage(image.Rect(0, 0, 64, 64))
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+	green := color.RGBA{G: 0xFF, A: 0xFF}
+
+	under := NewRectangle(image.Rect(4, 4, 20, 20), red, 2)
+	shield := NewShieldBlock(image.Rect(0, 0, 31, 63), color.Black)
+	pixelate := NewPixelate(base, image.Rect(32, 0, 64, 64), 8)
+	above := NewRectangle(image.Rect(10, 10, 50, 50), green, 2)
+	filters := []Filter{under, shield, pixelate, above}
+	svg := writeSVGString(t, base, base.Rect, filters)
+
+	// 遮挡之前的标注和遮挡本身都合成到截图中，之后的标注照常导出
+	if strings.Contains(svg, under.SVG()) || strings.Contains(svg, shield.SVG()) {
+		t.Errorf("WriteSVG() exported filters under the redaction as separate elements:\n%s", svg)
+	}
+	if !strings.Contains(svg, above.SVG()) {
+		t.Errorf("WriteSVG() didn't export the rectangle above the redaction:\n%s", svg)
+	}
+	images := svgImages(t, svg)
+	if len(images) != 1 {
+		t.Fatalf("WriteSVG() embedded %d images, want only the screenshot", len(images))
+	}
+	screenshot, ok := images["screenshot"]
+	if !ok {
+		t.Fatalf("WriteSVG() didn't embed the screenshot")
+	}
+
+	// 遮挡块下面没有原始截图的像素
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 32; x++ {
+			if got, want := screenshot.At(x, y), base.At(x, y); color.RGBAModel.Convert(got) == want {
+				t.Fatalf("embedded screenshot has the original pixel %v at (%d, %d) under the shield block", want, x, y)
+			}
+		}
+	}
+
+	// 嵌入的截图和显示的结果相同
+	c := NewCompositor()
+	c.Compose(base, filters[:3])
+	want := c.Image()
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			if got := color.RGBAModel.Convert(screenshot.At(x, y)); got != want.At(x, y) {
+				t.Fatalf("embedded screenshot at (%d, %d) = %v, want the composed %v", x, y, got, want.At(x, y))
+			}
+		}
+	}
+}
+
+func TestWriteSVGWithoutRedactions(t *testing.T) {
+	base := noiseImage(image.Rect(0, 0, 32, 32))
+	crop := image.Rect(4, 4, 28, 28)
+	rect := NewRectangle(image.Rect(8, 8, 20, 20), color.RGBA{R: 0xFF, A: 0xFF}, 2)
+	svg := writeSVGString(t, base, crop, []Filter{rect})
+	if !strings.Contains(svg, rect.SVG()) {
+		t.Errorf("WriteSVG() didn't export the rectangle:\n%s", svg)
+	}
+
+	// 没有遮挡时嵌入的是没有修改的截图(裁剪区域)
+	screenshot, ok := svgImages(t, svg)["screenshot"]
+	if !ok {
+		t.Fatalf("WriteSVG() didn't embed the screenshot")
+	}
+	if got := screenshot.Bounds().Size(); got != crop.Size() {
+		t.Fatalf("embedded screenshot size = %v, want %v", got, crop.Size())
+	}
+	for y := 0; y < crop.Dy(); y++ {
+		for x := 0; x < crop.Dx(); x++ {
+			if got, want := color.RGBAModel.Convert(screenshot.At(x, y)), base.At(crop.Min.X+x, crop.Min.Y+y); got != want {
+				t.Fatalf("embedded screenshot at (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
@@ -1,14 +1,14 @@
 package filters
 
 import (
-	"gitee.com/andrewgithub/FireShotGo/firetheme"
 	"github.com/golang/freetype/truetype"
 	"github.com/golang/glog"
 	"golang.org/x/image/font"
 	"golang.org/x/image/math/fixed"
 	"image"
 	"image/color"
-	"strings"
+	"image/draw"
+	"math"
 )
 
 // DPI constant. Ideally it would be read from the various system.
@@ -27,13 +27,56 @@ type Text struct {
 	// Font size.
 	Size float64
 
+	// Style 字体、粗斜体、对齐、行距、描边以及自动换行
+	Style TextStyle
+
 	// Rectangle enclosing text.
 	rect image.Rectangle
 
 	// Text rendered.
 	renderedText *image.RGBA
+
+	// layout 排版的结果，导出 SVG 时使用
+	layout textLayout
+
+	revision
+}
+
+// TextStyle 文本的排版样式，零值表示默认字体、左对齐、单倍行距、没有描边、不自动换行
+type TextStyle struct {
+	// Font 字体名称，可选的值参考 FontNames()，空表示 DefaultFontName
+	Font string
+
+	// Bold, Italic 粗体和斜体，字体没有对应的变体时会模拟出来
+	Bold, Italic bool
+
+	// Align 多行文本的对齐方式
+	Align TextAlign
+
+	// LineSpacing 行距倍数，0 按照 1 处理
+	LineSpacing float64
+
+	// OutlineColor, OutlineWidth 文字描边，在复杂的背景上更容易看清
+	OutlineColor color.Color
+	OutlineWidth float64
+
+	// MaxWidth 文本的最大宽度(像素)，超过之后自动换行，0 表示不自动换行
+	MaxWidth int
+}
+
+// textLayout 文本排版的结果，坐标相对于渲染出的图片的左上角
+type textLayout struct {
+	lines      []string
+	lineWidths []int
+	textWidth  int
+	lineHeight int
+	ascent     int
+	pad        int
 }
 
+// italicShear 模拟斜体时的倾斜比例
+const italicShear = 0.2
+
 // NewText creates a new Text (or ellipsis) filter. It draws
 // an ellipsis whose dimensions fit the given rectangle.
 // You must specify the color and the thickness of the Text to be drawn.
@@ -49,84 +92,184 @@ func NewText(text string, center image.Point, color, background color.Color, siz
 	return c
 }
 
+// SetStyle 设置文本样式并重新渲染
+func (t *Text) SetStyle(style TextStyle) {
+	t.Style = style
+	t.SetText(t.Text)
+}
+
 func (t *Text) SetText(text string) {
 	t.Text = text
-	point := fixed.Point26_6{X: 0, Y: fixed.Int26_6(t.Size * 64)}
-	goBoldFont, err := truetype.Parse(firetheme.ShangShouJianSongXianXiTi)
-	if err != nil {
-		glog.Fatalf("Failed to generate font for golang.org/x/image/font/gofont/gobold TTF.")
-	}
-	d := &font.Drawer{
-		Dst: t.renderedText,
-		Src: image.NewUniform(t.Color),
-		Face: truetype.NewFace(goBoldFont, &truetype.Options{
-			Size:       t.Size,
-			DPI:        DPI,
-			Hinting:    font.HintingFull,
-			SubPixelsX: 8,
-			SubPixelsY: 8,
-		}),
-		Dot: point,
-	}
-
-	// Handle multi-line content.
-	margins := int((t.Size*DPI/100.0)/2.0 + 0.99)
-	lines := strings.Split(text, "\n")
-	var boundingRect image.Rectangle
-	for _, line := range lines {
-		lineRect, _ := d.BoundString(line)
-		boundingRect.Max.Y += lineRect.Max.Y.Ceil() + margins
-		if lineRect.Max.X.Ceil() > boundingRect.Max.X {
-			boundingRect.Max.X = lineRect.Max.X.Ceil()
+	ttf, fakeBold, fakeItalic := loadFont(t.Style.Font, t.Style.Bold, t.Style.Italic)
+	face := truetype.NewFace(ttf, &truetype.Options{
+		Size:       t.Size,
+		DPI:        DPI,
+		Hinting:    font.HintingFull,
+		SubPixelsX: 8,
+		SubPixelsY: 8,
+	})
+
+	// Handle multi-line content, wrapping lines longer than MaxWidth.
+	lines := wrapText(face, text, t.Style.MaxWidth)
+	lineWidths := make([]int, len(lines))
+	textWidth := 0
+	for ii, line := range lines {
+		lineWidths[ii] = font.MeasureString(face, line).Ceil()
+		if lineWidths[ii] > textWidth {
+			textWidth = lineWidths[ii]
 		}
 	}
-	boundingRect.Max.X += 2 * margins
-	boundingRect.Max.Y += margins
+	spacing := t.Style.LineSpacing
+	if spacing <= 0 {
+		spacing = 1
+	}
+	metrics := face.Metrics()
+	lineHeight := int(float64(metrics.Height.Ceil())*spacing + 0.5)
+	textHeight := lineHeight*(len(lines)-1) + metrics.Ascent.Ceil() + metrics.Descent.Ceil()
 
-	// Prepare background.
-	img := image.NewRGBA(boundingRect)
-	t.renderedText = img
-	to8 := func(v uint32) uint8 { return uint8(v >> 8) }
-	for ii := 0; ii < len(img.Pix); ii += 4 {
-		r, g, b, a := t.Background.RGBA()
-		img.Pix[ii] = to8(r)
-		img.Pix[ii+1] = to8(g)
-		img.Pix[ii+2] = to8(b)
-		img.Pix[ii+3] = to8(a)
+	// 留白需要包含描边、模拟粗体和斜体额外占用的像素
+	margins := int((t.Size*DPI/100.0)/2.0 + 0.99)
+	outline := 0
+	if t.Style.OutlineColor != nil && t.Style.OutlineWidth > 0 {
+		outline = int(math.Ceil(t.Style.OutlineWidth))
+	}
+	boldExtra := 0
+	if fakeBold {
+		boldExtra = int(t.Size*DPI/72.0/20.0 + 0.99)
+	}
+	italicExtra := 0
+	if fakeItalic {
+		italicExtra = int(float64(textHeight)*italicShear + 0.99)
+	}
+	pad := margins + outline
+	boundingRect := image.Rect(0, 0, textWidth+2*pad+boldExtra+italicExtra, textHeight+2*pad)
+
+	t.layout = textLayout{
+		lines:      lines,
+		lineWidths: lineWidths,
+		textWidth:  textWidth,
+		lineHeight: lineHeight,
+		ascent:     metrics.Ascent.Ceil(),
+		pad:        pad,
 	}
-	d.Dst = t.renderedText
 
-	// Draw lines.
+	// Draw lines into an alpha mask, aligned within the text block.
+	mask := image.NewAlpha(boundingRect)
+	d := &font.Drawer{Dst: mask, Src: image.Opaque, Face: face}
 	for ii, line := range lines {
-		d.Dot = fixed.Point26_6{
-			X: fixed.Int26_6(margins * 64),
-			Y: fixed.Int26_6((float64(ii+1) * (t.Size + float64(margins))) * 64)}
+		x := pad
+		switch t.Style.Align {
+		case AlignCenter:
+			x += (textWidth - lineWidths[ii]) / 2
+		case AlignRight:
+			x += textWidth - lineWidths[ii]
+		}
+		d.Dot = fixed.P(x, pad+metrics.Ascent.Ceil()+ii*lineHeight)
 		d.DrawString(line)
 	}
+	if fakeBold {
+		mask = emboldenMask(mask, boldExtra)
+	}
+	if fakeItalic {
+		mask = shearMask(mask, italicShear)
+	}
+
+	// Compose background, outline and text.
+	img := image.NewRGBA(boundingRect)
+	draw.Draw(img, img.Rect, image.NewUniform(t.Background), image.Point{}, draw.Src)
+	if outline > 0 {
+		draw.DrawMask(img, img.Rect, image.NewUniform(t.Style.OutlineColor), image.Point{},
+			dilateMask(mask, t.Style.OutlineWidth), image.Point{}, draw.Over)
+	}
+	draw.DrawMask(img, img.Rect, image.NewUniform(t.Color), image.Point{}, mask, image.Point{}, draw.Over)
+	t.renderedText = img
+	t.updateRect()
+}
 
-	normalizeAlpha(t.renderedText)
+// SetCenter 移动文本的位置，不需要重新渲染
+func (t *Text) SetCenter(center image.Point) {
+	t.Center = center
+	t.updateRect()
+}
+
+// Bounds 返回文本在截图中占用的区域
+func (t *Text) Bounds() image.Rectangle {
+	return image.Rectangle{Min: t.rect.Min, Max: t.rect.Max.Add(image.Pt(1, 1))}
+}
 
+func (t *Text) updateRect() {
+	t.touch()
 	cx, cy := t.Center.X, t.Center.Y
 	dx, dy := t.renderedText.Rect.Dx(), t.renderedText.Rect.Dy()
 	t.rect = image.Rect(cx-dx/2, cy-dy/2, cx+dx/2, cy+dy/2)
 }
 
-func normalizeAlpha(img *image.RGBA) {
-	var maxAlpha uint8
-	for ii := 0; ii < len(img.Pix); ii += 4 {
-		alpha := img.Pix[ii+3]
-		if alpha > maxAlpha {
-			maxAlpha = alpha
+// emboldenMask 模拟粗体: 将字形向右扩展 extra 个像素
+func emboldenMask(mask *image.Alpha, extra int) *image.Alpha {
+	out := image.NewAlpha(mask.Rect)
+	for y := mask.Rect.Min.Y; y < mask.Rect.Max.Y; y++ {
+		for x := mask.Rect.Min.X; x < mask.Rect.Max.X; x++ {
+			var a uint8
+			for dx := 0; dx <= extra && x-dx >= mask.Rect.Min.X; dx++ {
+				if v := mask.AlphaAt(x-dx, y).A; v > a {
+					a = v
+				}
+			}
+			out.SetAlpha(x, y, color.Alpha{A: a})
 		}
 	}
-	const M = 1<<8 - 1
-	maxAlpha16 := uint16(maxAlpha)
-	if maxAlpha == 0 {
-		maxAlpha = 1
+	return out
+}
+
+// shearMask 模拟斜体: 越靠上的行向右偏移越多，偏移的小数部分做线性插值
+func shearMask(mask *image.Alpha, shear float64) *image.Alpha {
+	out := image.NewAlpha(mask.Rect)
+	h := mask.Rect.Dy()
+	for y := mask.Rect.Min.Y; y < mask.Rect.Max.Y; y++ {
+		offset := float64(h-1-(y-mask.Rect.Min.Y)) * shear
+		whole := int(offset)
+		frac := offset - float64(whole)
+		for x := mask.Rect.Min.X; x < mask.Rect.Max.X; x++ {
+			src := x - whole
+			var a, b float64
+			if src >= mask.Rect.Min.X && src < mask.Rect.Max.X {
+				a = float64(mask.AlphaAt(src, y).A)
+			}
+			if src-1 >= mask.Rect.Min.X && src-1 < mask.Rect.Max.X {
+				b = float64(mask.AlphaAt(src-1, y).A)
+			}
+			out.SetAlpha(x, y, color.Alpha{A: uint8(a*(1-frac) + b*frac + 0.5)})
+		}
 	}
-	for ii := 0; ii < len(img.Pix); ii += 4 {
-		img.Pix[ii+3] = uint8(uint16(img.Pix[ii+3]) * M / maxAlpha16)
+	return out
+}
+
+// dilateMask 描边: 将字形向四周扩展 radius 个像素
+func dilateMask(mask *image.Alpha, radius float64) *image.Alpha {
+	out := image.NewAlpha(mask.Rect)
+	r := int(math.Ceil(radius))
+	r2 := radius * radius
+	for y := mask.Rect.Min.Y; y < mask.Rect.Max.Y; y++ {
+		for x := mask.Rect.Min.X; x < mask.Rect.Max.X; x++ {
+			var a uint8
+			for dy := -r; dy <= r && a < 0xFF; dy++ {
+				for dx := -r; dx <= r; dx++ {
+					if float64(dx*dx+dy*dy) > r2 {
+						continue
+					}
+					p := image.Point{X: x + dx, Y: y + dy}
+					if !p.In(mask.Rect) {
+						continue
+					}
+					if v := mask.AlphaAt(p.X, p.Y).A; v > a {
+						a = v
+					}
+				}
+			}
+			out.SetAlpha(x, y, color.Alpha{A: a})
+		}
 	}
+	return out
 }
 
 // at is the function given to the filterImage object.
@@ -148,18 +291,24 @@ func (t *Text) at(x, y int, under color.Color) color.Color {
 	}
 	const M = 1<<16 - 1
 
+	// renderedText 中的颜色已经预乘了透明度
 	underR, underG, underB, underA := under.RGBA()
 	blend := func(underChan uint32, fontChan uint32) uint8 {
-		return uint8((fontChan*a + underChan*(M-a)) / M >> 8)
+		return uint8((fontChan + underChan*(M-a)/M) >> 8)
 	}
 	return color.RGBA{
 		R: blend(underR, fontR),
 		G: blend(underG, fontG),
 		B: blend(underB, fontB),
-		A: uint8(underA >> 8),
+		A: blend(underA, a),
 	}
 }
 
+// Rasterize implements the Layer interface.
+func (t *Text) Rasterize(dst *image.RGBA) {
+	rasterize(dst, t.at)
+}
+
 // Apply implements the ImageFilter interface.
 func (t *Text) Apply(image image.Image) image.Image {
 	return &filterImage{image, t.at}
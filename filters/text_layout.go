@@ -0,0 +1,138 @@
+package filters
+
+import (
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+	"strings"
+	"unicode"
+)
+
+// TextAlign 多行文本的对齐方式
+type TextAlign int
+
+const (
+	// AlignLeft 左对齐
+	AlignLeft TextAlign = iota
+	// AlignCenter 居中
+	AlignCenter
+	// AlignRight 右对齐
+	AlignRight
+)
+
+// TextAlignNames 对齐方式的显示名称，下标和 TextAlign 的取值一一对应
+var TextAlignNames = []string{"左对齐", "居中", "右对齐"}
+
+// String implements fmt.Stringer.
+func (a TextAlign) String() string {
+	if a < 0 || int(a) >= len(TextAlignNames) {
+		return "未知"
+	}
+	return TextAlignNames[a]
+}
+
+// ParseTextAlign 通过显示名称查找对齐方式，找不到返回 AlignLeft
+func ParseTextAlign(name string) TextAlign {
+	for ii, n := range TextAlignNames {
+		if n == name {
+			return TextAlign(ii)
+		}
+	}
+	return AlignLeft
+}
+
+// noLineStart 不能出现在行首的标点，换行时需要和前一个字符放在一起
+const noLineStart = "，。、！？；：）》」』】〕〉’”,.!?;:)]}%…—～·ー々ぁぃぅぇぉっゃゅょゎァィゥェォッャュョヮ"
+
+// noLineEnd 不能出现在行尾的标点，换行时需要和后一个字符放在一起
+const noLineEnd = "（《「『【〔〈‘“([{"
+
+// isCJK 中日韩文字，每个字符都可以单独换行
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) ||
+		(r >= 0x3000 && r <= 0x303F) || (r >= 0xFF00 && r <= 0xFFEF)
+}
+
+// splitBreakable 把一行文本切分成不可再分的片段，换行只会发生在片段之间。
+// 西文按照单词切分(单词后面的空格属于该单词)，中日韩文字每个字符一个片段，
+// 同时遵守简单的避头尾规则。
+func splitBreakable(line string) []string {
+	var tokens []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	runes := []rune(line)
+	for ii, r := range runes {
+		switch {
+		case strings.ContainsRune(noLineStart, r):
+			// 跟随前一个片段
+			current.WriteRune(r)
+		case unicode.IsSpace(r):
+			current.WriteRune(r)
+			flush()
+		case isCJK(r):
+			if current.Len() > 0 {
+				last := []rune(current.String())
+				if !strings.ContainsRune(noLineEnd, last[len(last)-1]) {
+					flush()
+				}
+			}
+			current.WriteRune(r)
+			if ii+1 < len(runes) && !strings.ContainsRune(noLineStart, runes[ii+1]) && !strings.ContainsRune(noLineEnd, r) {
+				flush()
+			}
+		default:
+			// 西文字符: 如果前面是中日韩文字，在这里可以换行
+			if current.Len() > 0 {
+				last := []rune(current.String())
+				if isCJK(last[len(last)-1]) && !strings.ContainsRune(noLineEnd, last[len(last)-1]) {
+					flush()
+				}
+			}
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// wrapText 按照最大宽度 maxWidth 自动换行，maxWidth <= 0 时只按照换行符分行
+func wrapText(face font.Face, text string, maxWidth int) []string {
+	paragraphs := strings.Split(text, "\n")
+	if maxWidth <= 0 {
+		return paragraphs
+	}
+	limit := fixed.I(maxWidth)
+	var lines []string
+	for _, paragraph := range paragraphs {
+		var line string
+		for _, token := range splitBreakable(paragraph) {
+			candidate := line + token
+			if line != "" && font.MeasureString(face, strings.TrimRightFunc(candidate, unicode.IsSpace)) > limit {
+				lines = append(lines, strings.TrimRightFunc(line, unicode.IsSpace))
+				line = ""
+				candidate = token
+			}
+			// 单个片段超过最大宽度时(例如很长的单词)，按照字符强制换行
+			for {
+				runes := []rune(candidate)
+				if len(runes) <= 1 || font.MeasureString(face, strings.TrimRightFunc(candidate, unicode.IsSpace)) <= limit {
+					break
+				}
+				n := len(runes) - 1
+				for n > 1 && font.MeasureString(face, string(runes[:n])) > limit {
+					n--
+				}
+				lines = append(lines, string(runes[:n]))
+				candidate = string(runes[n:])
+			}
+			line = candidate
+		}
+		lines = append(lines, strings.TrimRightFunc(line, unicode.IsSpace))
+	}
+	return lines
+}
@@ -0,0 +1,78 @@
+package filters
+
+import (
+	"image"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// TileSize 分块的边长(像素)。合成时按照这个大小的网格切分图片，
+// 只有和修改过的滤镜相交的分块才会重新合成，并且分块之间可以并行处理。
+const TileSize = 256
+
+// floorDiv 向下取整的除法，坐标可能是负数
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return -((-a + b - 1) / b)
+	}
+	return a / b
+}
+
+// tileIndex 返回点 p 所在分块在网格中的索引
+func tileIndex(p image.Point) image.Point {
+	return image.Point{X: floorDiv(p.X, TileSize), Y: floorDiv(p.Y, TileSize)}
+}
+
+// tileRect 返回网格中索引为 idx 的分块
+func tileRect(idx image.Point) image.Rectangle {
+	min := idx.Mul(TileSize)
+	return image.Rectangle{Min: min, Max: min.Add(image.Pt(TileSize, TileSize))}
+}
+
+// Tiles 将 r 按照网格切分成分块，边缘的分块会被裁剪到 r 以内
+func Tiles(r image.Rectangle) []image.Rectangle {
+	if r.Empty() {
+		return nil
+	}
+	first, last := tileIndex(r.Min), tileIndex(r.Max.Sub(image.Pt(1, 1)))
+	tiles := make([]image.Rectangle, 0, (last.X-first.X+1)*(last.Y-first.Y+1))
+	for ty := first.Y; ty <= last.Y; ty++ {
+		for tx := first.X; tx <= last.X; tx++ {
+			tiles = append(tiles, tileRect(image.Pt(tx, ty)).Intersect(r))
+		}
+	}
+	return tiles
+}
+
+// Parallel 使用 GOMAXPROCS 个 goroutine 并行地对每个 rect 调用 fn，全部完成之后才返回。
+// fn 会被同时调用，不同的 rect 之间不能写入相同的像素。
+func Parallel(rects []image.Rectangle, fn func(r image.Rectangle)) {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(rects) {
+		workers = len(rects)
+	}
+	if workers <= 1 {
+		for _, r := range rects {
+			fn(r)
+		}
+		return
+	}
+
+	next := int64(-1)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				ii := int(atomic.AddInt64(&next, 1))
+				if ii >= len(rects) {
+					return
+				}
+				fn(rects[ii])
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"flag"
+	"gitee.com/andrewgithub/FireShotGo/diff"
 	"gitee.com/andrewgithub/FireShotGo/screenshot"
 	"github.com/golang/glog"
+	"os"
 )
 
 /**
@@ -16,6 +18,13 @@ func main() {
 	flag.Parse()
 	defer glog.Flush()
 
+	// fireshotgo diff before.png after.png: 在命令行中比较两张截图，不启动界面
+	if flag.Arg(0) == "diff" {
+		code := diff.Main(flag.Args()[1:], os.Stdout, os.Stderr)
+		glog.Flush()
+		os.Exit(code)
+	}
+
 	// 开启截屏软件主程序
 	screenshot.Run()
 }
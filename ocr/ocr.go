@@ -0,0 +1,92 @@
+// Package ocr 识别截图中的文字。
+//
+// 识别引擎通过 Engine 接口提供，默认的实现 Tesseract 调用本机安装的 tesseract 命令，不需要联网。
+package ocr
+
+import (
+	"context"
+	"image"
+	"strings"
+	"unicode"
+)
+
+// Word 识别出的一个词以及它在图片中的位置
+type Word struct {
+	Text string
+	// Box 词在图片中的位置，使用传入图片的坐标
+	Box image.Rectangle
+	// Confidence 识别的可信度，取值 [0, 100]
+	Confidence float64
+
+	// Block, Paragraph, Line 词所在的区块、段落和行的序号，用于还原文本的排版
+	Block, Paragraph, Line int
+}
+
+// Result 识别的结果
+type Result struct {
+	Words []Word
+}
+
+// Engine 文字识别引擎
+type Engine interface {
+	// Recognize 识别 img 中的文字，lang 是引擎的语言代码，多种语言用 "+" 连接，例如 "eng+chi_sim"
+	Recognize(ctx context.Context, img image.Image, lang string) (*Result, error)
+
+	// Languages 返回引擎支持的语言代码
+	Languages(ctx context.Context) ([]string, error)
+}
+
+// Text 按照识别出的排版还原文本: 同一行的词用空格分隔(中日韩文字之间不加空格)，
+// 行之间换行，段落之间空一行
+func (r *Result) Text() string {
+	var sb strings.Builder
+	var prev *Word
+	for ii := range r.Words {
+		w := &r.Words[ii]
+		if w.Text == "" {
+			continue
+		}
+		if prev != nil {
+			switch {
+			case w.Block != prev.Block || w.Paragraph != prev.Paragraph:
+				sb.WriteString("\n\n")
+			case w.Line != prev.Line:
+				sb.WriteString("\n")
+			case !isCJK(lastRune(prev.Text)) || !isCJK(firstRune(w.Text)):
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString(w.Text)
+		prev = w
+	}
+	return sb.String()
+}
+
+// Translate 将所有词的位置平移 delta，识别图片的一部分时用于转换成整张图片的坐标
+func (r *Result) Translate(delta image.Point) {
+	for ii := range r.Words {
+		r.Words[ii].Box = r.Words[ii].Box.Add(delta)
+	}
+}
+
+func isCJK(r rune) bool {
+	// 包括中日韩的标点(U+3000~U+303F)以及全角字符(U+FF00~U+FFEF)。
+	// 韩文的词之间有空格，所以不包括在内
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) ||
+		(r >= 0x3000 && r <= 0x303f) || (r >= 0xff00 && r <= 0xffef)
+}
+
+func firstRune(s string) rune {
+	for _, r := range s {
+		return r
+	}
+	return 0
+}
+
+func lastRune(s string) rune {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return 0
+	}
+	return runes[len(runes)-1]
+}
@@ -0,0 +1,151 @@
+package ocr
+
+import (
+	"context"
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// stubEngine 返回固定结果的识别引擎，用于测试不依赖 tesseract 的部分
+type stubEngine struct {
+	words []Word
+	langs []string
+}
+
+var _ Engine = (*stubEngine)(nil)
+
+// Recognize implements Engine. 固定的结果使用图片左上角为原点的坐标，转换成传入图片的坐标。
+func (s *stubEngine) Recognize(ctx context.Context, img image.Image, lang string) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result := &Result{Words: append([]Word(nil), s.words...)}
+	result.Translate(img.Bounds().Min)
+	return result, nil
+}
+
+// Languages implements Engine.
+func (s *stubEngine) Languages(context.Context) ([]string, error) {
+	return s.langs, nil
+}
+
+// tsvHeader tesseract TSV 输出的第一行
+const tsvHeader = "level\tpage_num\tblock_num\tpar_num\tline_num\tword_num\tleft\ttop\twidth\theight\tconf\ttext\n"
+
+func TestParseTSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		tsv     string
+		want    []Word
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			tsv:  tsvHeader,
+		},
+		{
+			name: "words only",
+			tsv: tsvHeader +
+				"1\t1\t0\t0\t0\t0\t0\t0\t800\t600\t-1\t\n" +
+				"4\t1\t1\t1\t1\t0\t10\t20\t200\t30\t-1\t\n" +
+				"5\t1\t1\t1\t1\t1\t10\t20\t80\t30\t96.5\tHello\n" +
+				"5\t1\t1\t1\t1\t2\t100\t20\t110\t30\t91\tworld\n",
+			want: []Word{
+				{Text: "Hello", Box: image.Rect(10, 20, 90, 50), Confidence: 96.5, Block: 1, Paragraph: 1, Line: 1},
+				{Text: "world", Box: image.Rect(100, 20, 210, 50), Confidence: 91, Block: 1, Paragraph: 1, Line: 1},
+			},
+		},
+		{
+			name: "blank words skipped",
+			tsv: tsvHeader +
+				"5\t1\t1\t1\t1\t1\t0\t0\t10\t10\t95\t \n" +
+				"5\t1\t2\t3\t4\t1\t5\t6\t7\t8\t50\t中文\n",
+			want: []Word{
+				{Text: "中文", Box: image.Rect(5, 6, 12, 14), Confidence: 50, Block: 2, Paragraph: 3, Line: 4},
+			},
+		},
+		{
+			name: "short lines ignored",
+			tsv:  tsvHeader + "5\t1\t1\n",
+		},
+		{
+			name:    "invalid number",
+			tsv:     tsvHeader + "5\t1\tx\t1\t1\t1\t0\t0\t10\t10\t95\tword\n",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		result, err := ParseTSV(strings.NewReader(test.tsv))
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: ParseTSV() succeeded, want an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ParseTSV() failed: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(result.Words, test.want) {
+			t.Errorf("%s: ParseTSV() = %+v, want %+v", test.name, result.Words, test.want)
+		}
+	}
+}
+
+func TestResultText(t *testing.T) {
+	word := func(text string, block, par, line int) Word {
+		return Word{Text: text, Block: block, Paragraph: par, Line: line}
+	}
+	tests := []struct {
+		name  string
+		words []Word
+		want  string
+	}{
+		{"empty", nil, ""},
+		{"one line", []Word{word("Hello", 1, 1, 1), word("world", 1, 1, 1)}, "Hello world"},
+		{"lines", []Word{word("first", 1, 1, 1), word("line", 1, 1, 1), word("second", 1, 1, 2)}, "first line\nsecond"},
+		{"paragraphs", []Word{word("one", 1, 1, 1), word("two", 1, 2, 1)}, "one\n\ntwo"},
+		{"blocks", []Word{word("one", 1, 1, 1), word("two", 2, 1, 1)}, "one\n\ntwo"},
+		{"cjk", []Word{word("截图", 1, 1, 1), word("工具", 1, 1, 1), word("FireShotGo", 1, 1, 1)}, "截图工具 FireShotGo"},
+		{"cjk punctuation", []Word{word("你好", 1, 1, 1), word("，", 1, 1, 1), word("世界", 1, 1, 1)}, "你好，世界"},
+		{"japanese", []Word{word("スクリーン", 1, 1, 1), word("ショット", 1, 1, 1)}, "スクリーンショット"},
+		{"korean", []Word{word("스크린샷", 1, 1, 1), word("도구", 1, 1, 1)}, "스크린샷 도구"},
+		{"empty words", []Word{word("a", 1, 1, 1), word("", 1, 1, 2), word("b", 1, 1, 1)}, "a b"},
+	}
+	for _, test := range tests {
+		result := &Result{Words: test.words}
+		if got := result.Text(); got != test.want {
+			t.Errorf("%s: Text() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+// TestStubEngine 引擎返回的位置使用传入图片的坐标，识别图片的一部分时不需要再转换
+func TestStubEngine(t *testing.T) {
+	engine := &stubEngine{words: []Word{
+		{Text: "token", Box: image.Rect(0, 0, 40, 10), Block: 1, Paragraph: 1, Line: 1},
+		{Text: "sk-123", Box: image.Rect(50, 0, 100, 10), Block: 1, Paragraph: 1, Line: 1},
+	}}
+	region := image.NewRGBA(image.Rect(200, 300, 400, 400))
+	result, err := engine.Recognize(context.Background(), region, "eng")
+	if err != nil {
+		t.Fatalf("Recognize() failed: %v", err)
+	}
+	if got, want := result.Words[1].Box, image.Rect(250, 300, 300, 310); got != want {
+		t.Errorf("Recognize() box = %v, want %v", got, want)
+	}
+	if got, want := result.Text(), "token sk-123"; got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+	if engine.words[0].Box.Min != (image.Point{}) {
+		t.Error("Recognize() modified the engine's words")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := engine.Recognize(ctx, region, "eng"); err == nil {
+		t.Error("Recognize() with a cancelled context succeeded, want an error")
+	}
+}
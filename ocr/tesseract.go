@@ -0,0 +1,160 @@
+package ocr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	xdraw "golang.org/x/image/draw"
+	"image"
+	"image/png"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Tesseract 调用本机安装的 tesseract 命令(https://github.com/tesseract-ocr/tesseract)进行识别。
+// 语言需要安装对应的数据，例如 Debian/Ubuntu 上的 tesseract-ocr-chi-sim。
+type Tesseract struct {
+	// Path tesseract 可执行文件，为空时在 PATH 中查找
+	Path string
+
+	// Scale 识别之前将图片放大的倍数。屏幕上的文字通常很小，放大之后识别效果好很多。
+	Scale int
+}
+
+// NewTesseract 创建使用 path 的引擎，默认将图片放大 2 倍
+func NewTesseract(path string) *Tesseract {
+	return &Tesseract{Path: path, Scale: 2}
+}
+
+var _ Engine = (*Tesseract)(nil)
+
+func (t *Tesseract) command(ctx context.Context, args ...string) *exec.Cmd {
+	path := t.Path
+	if path == "" {
+		path = "tesseract"
+	}
+	return exec.CommandContext(ctx, path, args...)
+}
+
+// run 执行 tesseract，出错时返回的错误包含 tesseract 的错误输出
+func (t *Tesseract) run(cmd *exec.Cmd) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("tesseract failed: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("tesseract failed: %w", err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// Languages implements Engine. 返回 `tesseract --list-langs` 列出的语言，不包括方向检测使用的 osd。
+func (t *Tesseract) Languages(ctx context.Context) ([]string, error) {
+	out, err := t.run(t.command(ctx, "--list-langs"))
+	if err != nil {
+		return nil, err
+	}
+	var langs []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// 第一行是 `List of available languages in "..." (N):`
+		if line == "" || line == "osd" || strings.HasSuffix(line, ":") {
+			continue
+		}
+		langs = append(langs, line)
+	}
+	return langs, scanner.Err()
+}
+
+// Recognize implements Engine. 图片通过标准输入以 PNG 传给 tesseract，结果使用 TSV 格式读取。
+func (t *Tesseract) Recognize(ctx context.Context, img image.Image, lang string) (*Result, error) {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return &Result{}, nil
+	}
+	scale := t.Scale
+	if scale < 1 {
+		scale = 1
+	}
+	input := img
+	if scale > 1 {
+		scaled := image.NewRGBA(image.Rect(0, 0, bounds.Dx()*scale, bounds.Dy()*scale))
+		xdraw.CatmullRom.Scale(scaled, scaled.Rect, img, bounds, xdraw.Src, nil)
+		input = scaled
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, input); err != nil {
+		return nil, err
+	}
+
+	args := []string{"stdin", "stdout"}
+	if lang != "" {
+		args = append(args, "-l", lang)
+	}
+	// 截图的分辨率和屏幕相同，放大之后相应地提高
+	args = append(args, "--dpi", strconv.Itoa(96*scale), "tsv")
+	cmd := t.command(ctx, args...)
+	cmd.Stdin = &buf
+	out, err := t.run(cmd)
+	if err != nil {
+		return nil, err
+	}
+	result, err := ParseTSV(bytes.NewReader(out))
+	if err != nil {
+		return nil, err
+	}
+	// 转换回原始图片的坐标
+	for ii := range result.Words {
+		box := result.Words[ii].Box
+		result.Words[ii].Box = image.Rect(box.Min.X/scale, box.Min.Y/scale,
+			(box.Max.X+scale-1)/scale, (box.Max.Y+scale-1)/scale).Add(bounds.Min)
+	}
+	return result, nil
+}
+
+// ParseTSV 解析 tesseract 的 TSV 输出，只保留词(level 5)。
+// 列依次是: level page_num block_num par_num line_num word_num left top width height conf text
+func ParseTSV(r io.Reader) (*Result, error) {
+	result := &Result{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	header := true
+	for scanner.Scan() {
+		if header {
+			header = false
+			continue
+		}
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 12 || fields[0] != "5" {
+			continue
+		}
+		text := strings.TrimSpace(strings.Join(fields[11:], "\t"))
+		if text == "" {
+			continue
+		}
+		var nums [10]int
+		for ii := 1; ii < 10; ii++ {
+			n, err := strconv.Atoi(fields[ii])
+			if err != nil {
+				return nil, fmt.Errorf("invalid tesseract TSV line %q: %w", scanner.Text(), err)
+			}
+			nums[ii] = n
+		}
+		conf, _ := strconv.ParseFloat(fields[10], 64)
+		left, top, width, height := nums[6], nums[7], nums[8], nums[9]
+		result.Words = append(result.Words, Word{
+			Text:       text,
+			Box:        image.Rect(left, top, left+width, top+height),
+			Confidence: conf,
+			Block:      nums[2],
+			Paragraph:  nums[3],
+			Line:       nums[4],
+		})
+	}
+	return result, scanner.Err()
+}
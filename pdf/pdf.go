@@ -0,0 +1,400 @@
+// Package pdf 将截图导出为 PDF 文档: 每张截图一页，缩放到页面以内并且保留页边距，
+// 页面顶部可以打印标题和截图时间。
+//
+// 纯 Go 实现，不依赖外部程序或者网络，离线也可以使用。
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"gitee.com/andrewgithub/FireShotGo/filters"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// PageSize 纸张大小，单位是 pt (1/72 英寸)，按照纵向定义
+type PageSize struct {
+	Name          string
+	Width, Height float64
+}
+
+// PageSizes 支持的纸张大小，第一个是默认值
+var PageSizes = []PageSize{
+	{Name: "A4", Width: 595.28, Height: 841.89},
+	{Name: "A3", Width: 841.89, Height: 1190.55},
+	{Name: "Letter", Width: 612, Height: 792},
+	{Name: "Legal", Width: 612, Height: 1008},
+}
+
+// ParsePageSize 通过名称查找纸张大小，找不到返回 PageSizes[0]
+func ParsePageSize(name string) PageSize {
+	for _, ps := range PageSizes {
+		if ps.Name == name {
+			return ps
+		}
+	}
+	return PageSizes[0]
+}
+
+// Orientation 页面方向
+type Orientation int
+
+const (
+	// OrientationAuto 根据每张截图的宽高比自动选择纵向或者横向
+	OrientationAuto Orientation = iota
+	// OrientationPortrait 纵向
+	OrientationPortrait
+	// OrientationLandscape 横向
+	OrientationLandscape
+)
+
+// OrientationNames 页面方向的显示名称，下标和 Orientation 的取值一一对应
+var OrientationNames = []string{"自动", "纵向", "横向"}
+
+// String implements fmt.Stringer.
+func (o Orientation) String() string {
+	if o < 0 || int(o) >= len(OrientationNames) {
+		return "未知"
+	}
+	return OrientationNames[o]
+}
+
+// ParseOrientation 通过显示名称查找页面方向，找不到返回 OrientationAuto
+func ParseOrientation(name string) Orientation {
+	for ii, n := range OrientationNames {
+		if n == name {
+			return Orientation(ii)
+		}
+	}
+	return OrientationAuto
+}
+
+// Options 导出参数
+type Options struct {
+	PageSize    PageSize
+	Orientation Orientation
+
+	// Margin 页边距，单位是 pt
+	Margin float64
+
+	// Header 是否在每一页顶部打印标题和截图时间
+	Header bool
+}
+
+// DefaultOptions 默认参数: A4 自动方向，1cm 页边距，打印页眉
+func DefaultOptions() Options {
+	return Options{PageSize: PageSizes[0], Margin: 28.35, Header: true}
+}
+
+// Page 一页的内容
+type Page struct {
+	// Image 截图，透明的部分显示为白色
+	Image image.Image
+
+	// Title 页眉中的标题，可以为空
+	Title string
+
+	// Time 页眉中的截图时间，零值表示不打印
+	Time time.Time
+}
+
+const (
+	// maxScale 截图每个像素最多占用的 pt，也就是 96 DPI 屏幕上的大小，小的截图不会被放大
+	maxScale = 72.0 / 96.0
+
+	// headerFontSize 页眉文字的大小(pt)
+	headerFontSize = 10.0
+
+	// headerGap 页眉和截图之间的距离(pt)
+	headerGap = 8.0
+
+	// headerOversample 页眉文字按照实际大小的倍数光栅化，打印时保持清晰
+	headerOversample = 4.0
+)
+
+// Write 将 pages 写成一个 PDF 文档，每个 Page 一页
+func Write(w io.Writer, pages []Page, opts Options) error {
+	if len(pages) == 0 {
+		return fmt.Errorf("no pages to export")
+	}
+	pw := &writer{w: bufio.NewWriter(w)}
+	pw.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+
+	catalogID, pagesID, infoID := pw.newID(), pw.newID(), pw.newID()
+	var kids []string
+	for _, page := range pages {
+		pageID, err := pw.page(page, opts, pagesID)
+		if err != nil {
+			return err
+		}
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageID))
+	}
+	pw.object(catalogID, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesID))
+	pw.object(pagesID, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+	pw.object(infoID, fmt.Sprintf("<< /Title %s /Producer %s /CreationDate %s >>",
+		textString(pages[0].Title), textString("FireShotGo"), textString(dateString(time.Now()))))
+
+	// 交叉引用表
+	xref := pw.offset
+	pw.printf("xref\n0 %d\n0000000000 65535 f \n", len(pw.offsets)+1)
+	for _, offset := range pw.offsets {
+		pw.printf("%010d 00000 n \n", offset)
+	}
+	pw.printf("trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(pw.offsets)+1, catalogID, infoID, xref)
+	if pw.err != nil {
+		return pw.err
+	}
+	return pw.w.Flush()
+}
+
+// page 写入一页以及它用到的图片，返回页面对象的编号
+func (pw *writer) page(page Page, opts Options, pagesID int) (int, error) {
+	bounds := page.Image.Bounds()
+	if bounds.Empty() {
+		return 0, fmt.Errorf("empty image")
+	}
+	width, height := opts.PageSize.Width, opts.PageSize.Height
+	landscape := opts.Orientation == OrientationLandscape ||
+		(opts.Orientation == OrientationAuto && bounds.Dx() > bounds.Dy())
+	if landscape {
+		width, height = height, width
+	}
+
+	// 可用区域，PDF 的坐标原点在左下角
+	margin := math.Max(opts.Margin, 0)
+	left, right := margin, width-margin
+	top, bottom := height-margin, margin
+	if right-left < 1 || top-bottom < 1 {
+		return 0, fmt.Errorf("margin %g too large for page %gx%g", margin, width, height)
+	}
+
+	var content bytes.Buffer
+	xObjects := map[string]int{}
+	if opts.Header {
+		var err error
+		top, err = pw.header(page, &content, xObjects, left, right, top)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// 截图: 等比缩放到可用区域内，水平居中，紧接在页眉下面
+	imageID, err := pw.image(page.Image)
+	if err != nil {
+		return 0, err
+	}
+	xObjects["Im0"] = imageID
+	scale := math.Min(maxScale, math.Min((right-left)/float64(bounds.Dx()), (top-bottom)/float64(bounds.Dy())))
+	w, h := float64(bounds.Dx())*scale, float64(bounds.Dy())*scale
+	x := left + (right-left-w)/2
+	fmt.Fprintf(&content, "q %s 0 0 %s %s %s cm /Im0 Do Q\n", num(w), num(h), num(x), num(top-h))
+
+	contentID, err := pw.stream(content.Bytes(), "")
+	if err != nil {
+		return 0, err
+	}
+	var resources []string
+	for _, name := range []string{"Im0", "Hd0", "Hd1"} {
+		if id, found := xObjects[name]; found {
+			resources = append(resources, fmt.Sprintf("/%s %d 0 R", name, id))
+		}
+	}
+	pageID := pw.newID()
+	pw.object(pageID, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] "+
+		"/Resources << /XObject << %s >> >> /Contents %d 0 R >>",
+		pagesID, num(width), num(height), strings.Join(resources, " "), contentID))
+	return pageID, nil
+}
+
+// header 绘制页眉: 标题靠左，截图时间靠右，下面是一条分割线。返回页眉以下可用区域的顶部。
+// 文字先光栅化成图片，这样中文标题不需要在 PDF 中嵌入字体。
+func (pw *writer) header(page Page, content *bytes.Buffer, xObjects map[string]int, left, right, top float64) (float64, error) {
+	var texts []string
+	if page.Title != "" {
+		texts = append(texts, page.Title)
+	}
+	if !page.Time.IsZero() {
+		texts = append(texts, page.Time.Format("2006-01-02 15:04:05"))
+	}
+	if len(texts) == 0 {
+		return top, nil
+	}
+
+	// 渲染出的文字图片按照 headerOversample 缩小，得到 headerFontSize 大小的文字
+	imgs := make([]image.Image, len(texts))
+	lineHeight := 0.0
+	for ii, text := range texts {
+		imgs[ii] = renderText(text)
+		lineHeight = math.Max(lineHeight, float64(imgs[ii].Bounds().Dy())/headerOversample)
+	}
+	for ii, img := range imgs {
+		b := img.Bounds()
+		w, h := float64(b.Dx())/headerOversample, float64(b.Dy())/headerOversample
+		maxWidth := right - left
+		if ii == 0 && len(imgs) > 1 {
+			// 标题不能和右边的时间重叠
+			maxWidth -= float64(imgs[1].Bounds().Dx())/headerOversample + headerGap
+		}
+		if w > maxWidth {
+			// 标题太长时缩小
+			w, h = maxWidth, h*maxWidth/w
+		}
+		x := left
+		if page.Title == "" || ii > 0 {
+			// 时间靠右
+			x = right - w
+		}
+		id, err := pw.image(img)
+		if err != nil {
+			return top, err
+		}
+		name := fmt.Sprintf("Hd%d", ii)
+		xObjects[name] = id
+		fmt.Fprintf(content, "q %s 0 0 %s %s %s cm /%s Do Q\n", num(w), num(h), num(x), num(top-h), name)
+	}
+	lineY := top - lineHeight - headerGap/2
+	fmt.Fprintf(content, "q 0.6 G 0.5 w %s %s m %s %s l S Q\n", num(left), num(lineY), num(right), num(lineY))
+	return top - lineHeight - headerGap, nil
+}
+
+// renderText 使用默认字体将文字渲染到白色背景上
+func renderText(text string) image.Image {
+	// filters.Text 的大小按照 filters.DPI 计算
+	size := headerFontSize * headerOversample * 72 / filters.DPI
+	t := filters.NewText(text, image.Point{}, color.Black, color.Transparent, size)
+	bounds := t.Bounds()
+	img := image.NewRGBA(bounds)
+	draw.Draw(img, bounds, image.White, image.Point{}, draw.Src)
+	return t.Apply(img)
+}
+
+// writer 记录每个对象在文件中的位置，用于生成交叉引用表。
+// 第一次出错之后忽略所有的写入，错误在最后统一返回。
+type writer struct {
+	w       *bufio.Writer
+	offset  int64
+	offsets []int64
+	err     error
+}
+
+func (pw *writer) printf(format string, args ...interface{}) {
+	pw.write([]byte(fmt.Sprintf(format, args...)))
+}
+
+func (pw *writer) write(p []byte) {
+	if pw.err != nil {
+		return
+	}
+	n, err := pw.w.Write(p)
+	pw.offset += int64(n)
+	pw.err = err
+}
+
+// newID 分配一个对象编号，对象可以在之后的任意位置写入
+func (pw *writer) newID() int {
+	pw.offsets = append(pw.offsets, 0)
+	return len(pw.offsets)
+}
+
+func (pw *writer) object(id int, body string) {
+	pw.offsets[id-1] = pw.offset
+	pw.printf("%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+// stream 写入一个压缩的流对象，dict 是额外的字典项
+func (pw *writer) stream(data []byte, dict string) (int, error) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(data); err != nil {
+		return 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return 0, err
+	}
+	id := pw.newID()
+	pw.offsets[id-1] = pw.offset
+	pw.printf("%d 0 obj\n<< %s /Filter /FlateDecode /Length %d >>\nstream\n", id, dict, compressed.Len())
+	pw.write(compressed.Bytes())
+	pw.printf("\nendstream\nendobj\n")
+	return id, pw.err
+}
+
+// image 写入 RGB 图片对象，透明的部分叠加到白色背景上。
+// 每一行使用 PNG 的 Sub 预测，截图中大片相同的颜色可以压缩得更小。
+func (pw *writer) image(img image.Image) (int, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	data := make([]byte, 0, (width*3+1)*height)
+	row := make([]byte, width*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			// 预乘 alpha 的颜色叠加到白色上
+			white := 0xFFFF - a
+			ii := (x - bounds.Min.X) * 3
+			row[ii], row[ii+1], row[ii+2] = uint8((r+white)>>8), uint8((g+white)>>8), uint8((b+white)>>8)
+		}
+		data = append(data, 1) // Sub
+		for ii := range row {
+			if ii < 3 {
+				data = append(data, row[ii])
+			} else {
+				data = append(data, row[ii]-row[ii-3])
+			}
+		}
+	}
+	dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 "+
+		"/DecodeParms << /Predictor 15 /Colors 3 /BitsPerComponent 8 /Columns %d >>", width, height, width)
+	return pw.stream(data, dict)
+}
+
+// num 格式化 PDF 中的数字，PDF 不支持指数形式
+func num(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
+
+// textString 将字符串编码成 PDF 的文本字符串: ASCII 使用字面量，其它使用带 BOM 的 UTF-16BE
+func textString(s string) string {
+	ascii := true
+	for _, r := range s {
+		if r >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", `\r`, "\n", `\n`)
+		return "(" + r.Replace(s) + ")"
+	}
+	var sb strings.Builder
+	sb.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&sb, "%04X", u)
+	}
+	sb.WriteString(">")
+	return sb.String()
+}
+
+// dateString 格式化 PDF 的日期
+func dateString(t time.Time) string {
+	_, offset := t.Zone()
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	return fmt.Sprintf("D:%s%c%02d'%02d'", t.Format("20060102150405"), sign, offset/3600, offset%3600/60)
+}
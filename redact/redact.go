@@ -0,0 +1,203 @@
+// Package redact 根据识别出的文字自动查找需要遮挡的敏感信息，例如邮箱、IP 地址、API key 和客户编号。
+//
+// 规则保存为 JSON 文件(参考 Policy)，可以在团队中共享，保证所有人使用同样的遮挡策略。
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"gitee.com/andrewgithub/FireShotGo/ocr"
+	"image"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Style 遮挡的方式
+type Style string
+
+const (
+	// Block 使用遮挡块覆盖
+	Block Style = "block"
+	// Pixelate 打马赛克
+	Pixelate Style = "pixelate"
+)
+
+// Rule 一条遮挡规则: 和 Pattern 匹配的文字使用 Style 遮挡
+type Rule struct {
+	Name string `json:"name"`
+	// Pattern Go 的正则表达式(RE2 语法)，匹配的是一行中用空格连接的所有词
+	Pattern string `json:"pattern"`
+	Style   Style  `json:"style,omitempty"`
+	// Disabled 暂时不使用的规则
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// Policy 遮挡策略，也是规则文件的内容
+type Policy struct {
+	Rules []Rule `json:"rules"`
+
+	// Padding 遮挡区域在文字外额外扩大的像素
+	Padding int `json:"padding,omitempty"`
+	// BlockSize 马赛克的块大小
+	BlockSize int `json:"block_size,omitempty"`
+}
+
+// DefaultPolicy 默认的规则: 邮箱、IPv4/IPv6 地址、常见格式的 API key 以及客户编号的示例。
+// 客户编号的格式因公司而异，需要在规则文件中修改。
+func DefaultPolicy() Policy {
+	return Policy{
+		Rules: []Rule{
+			{Name: "邮箱", Pattern: `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`, Style: Block},
+			{Name: "IPv4", Pattern: `\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`, Style: Block},
+			{Name: "IPv6", Pattern: `\b(?:[0-9A-Fa-f]{1,4}:){7}[0-9A-Fa-f]{1,4}\b`, Style: Block},
+			{Name: "API key", Pattern: `\b(?:AKIA[0-9A-Z]{16}|(?:sk|pk|rk)_(?:live|test)_[0-9A-Za-z]{16,}|gh[pousr]_[0-9A-Za-z]{36}|AIza[0-9A-Za-z_-]{35})\b`, Style: Pixelate},
+			{Name: "Token", Pattern: `(?i)\b(?:api[_-]?key|token|secret|password)\s*[:=]\s*\S+`, Style: Pixelate},
+			{Name: "客户编号", Pattern: `\bCUST-\d{6,}\b`, Style: Block},
+		},
+		Padding:   2,
+		BlockSize: 8,
+	}
+}
+
+// Load 读取规则文件，并检查所有的规则都可以编译
+func Load(path string) (Policy, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Policy{}, err
+	}
+	var p Policy
+	if err := json.Unmarshal(content, &p); err != nil {
+		return Policy{}, fmt.Errorf("invalid redaction rules in %q: %w", path, err)
+	}
+	if _, err := p.Compile(); err != nil {
+		return Policy{}, fmt.Errorf("invalid redaction rules in %q: %w", path, err)
+	}
+	return p, nil
+}
+
+// Save 将规则写入文件，方便修改之后分享给其他人
+func (p Policy) Save(path string) error {
+	content, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
+
+// compiledRule 编译之后的规则
+type compiledRule struct {
+	Rule
+	re *regexp.Regexp
+}
+
+// Matcher 编译之后的策略
+type Matcher struct {
+	policy Policy
+	rules  []compiledRule
+}
+
+// Compile 编译所有启用的规则
+func (p Policy) Compile() (*Matcher, error) {
+	m := &Matcher{policy: p}
+	for _, rule := range p.Rules {
+		if rule.Disabled {
+			continue
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+		if rule.Style == "" {
+			rule.Style = Block
+		}
+		m.rules = append(m.rules, compiledRule{rule, re})
+	}
+	return m, nil
+}
+
+// Match 一处需要遮挡的文字
+type Match struct {
+	Rule Rule
+	Text string
+	// Box 需要遮挡的区域，已经加上了 Padding
+	Box image.Rectangle
+}
+
+// Find 在识别的结果中查找需要遮挡的文字。
+// 同一行的词用空格连接之后进行匹配，所以规则可以匹配跨越多个词的文字(例如 "token: xxx")，
+// 匹配结果的区域是所有和匹配部分重叠的词的区域的并集。
+func (m *Matcher) Find(result *ocr.Result) []Match {
+	var matches []Match
+	for _, line := range lines(result) {
+		for _, rule := range m.rules {
+			for _, loc := range rule.re.FindAllStringIndex(line.text, -1) {
+				if loc[0] == loc[1] {
+					continue
+				}
+				var box image.Rectangle
+				for ii, w := range line.words {
+					if line.offsets[ii] < loc[1] && line.offsets[ii]+len(w.Text) > loc[0] {
+						box = box.Union(w.Box)
+					}
+				}
+				if box.Empty() {
+					continue
+				}
+				pad := image.Point{X: m.policy.Padding, Y: m.policy.Padding}
+				matches = append(matches, Match{
+					Rule: rule.Rule,
+					Text: line.text[loc[0]:loc[1]],
+					Box:  image.Rectangle{Min: box.Min.Sub(pad), Max: box.Max.Add(pad)},
+				})
+			}
+		}
+	}
+	return matches
+}
+
+// BlockSize 马赛克的块大小
+func (m *Matcher) BlockSize() int {
+	if m.policy.BlockSize > 0 {
+		return m.policy.BlockSize
+	}
+	return 8
+}
+
+// line 一行文字，offsets 是每个词在 text 中的字节偏移
+type line struct {
+	text    string
+	words   []ocr.Word
+	offsets []int
+}
+
+func lines(result *ocr.Result) []line {
+	var out []line
+	var sb strings.Builder
+	var current *line
+	for _, w := range result.Words {
+		if w.Text == "" || !utf8.ValidString(w.Text) {
+			continue
+		}
+		if current == nil || w.Block != current.words[0].Block || w.Paragraph != current.words[0].Paragraph ||
+			w.Line != current.words[0].Line {
+			if current != nil {
+				current.text = sb.String()
+				out = append(out, *current)
+			}
+			current = &line{}
+			sb.Reset()
+		} else {
+			sb.WriteByte(' ')
+		}
+		current.offsets = append(current.offsets, sb.Len())
+		current.words = append(current.words, w)
+		sb.WriteString(w.Text)
+	}
+	if current != nil {
+		current.text = sb.String()
+		out = append(out, *current)
+	}
+	return out
+}
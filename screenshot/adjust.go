@@ -0,0 +1,114 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"gitee.com/andrewgithub/FireShotGo/filters"
+	"image"
+)
+
+// AdjustImage 选择一个区域(单击时使用整张截图)，调整亮度、对比度、伽马、饱和度、反色和锐化。
+// 调整是 Filters 中的一项，可以撤销，并且只影响之前添加的标注。
+func (gs *FireShotGO) AdjustImage() {
+	gs.viewPort.SetOp(SelectAdjust)
+}
+
+// adjustRegion 在 rect (原始截图的坐标，为空时使用整张截图)上添加调整，修改参数时实时预览
+func (gs *FireShotGO) adjustRegion(rect image.Rectangle) {
+	if !rect.Empty() {
+		rect = rect.Intersect(gs.CropRect)
+		if rect.Empty() {
+			gs.status.SetText("Nothing to adjust outside of the screenshot.")
+			return
+		}
+	}
+	adjust := filters.NewAdjust(rect, filters.Adjustments{})
+	gs.Filters = append(gs.Filters, adjust)
+
+	// slider 返回滑块以及显示当前数值的一行，format 是数值的格式
+	var labels []func()
+	slider := func(min, max, step float64, format string) (*widget.Slider, *fyne.Container) {
+		s := widget.NewSlider(min, max)
+		s.Step = step
+		label := widget.NewLabel("")
+		labels = append(labels, func() { label.SetText(fmt.Sprintf(format, s.Value)) })
+		return s, container.NewBorder(nil, nil, nil, label, s)
+	}
+	brightness, brightnessRow := slider(-100, 100, 1, "%+.0f%%")
+	contrast, contrastRow := slider(-100, 100, 1, "%+.0f%%")
+	gamma, gammaRow := slider(0.2, 3, 0.1, "%.1f")
+	saturation, saturationRow := slider(-100, 100, 1, "%+.0f%%")
+	sharpen, sharpenRow := slider(0, 200, 5, "%.0f%%")
+	invert := widget.NewCheck("反色", nil)
+
+	// update 按照界面上的参数更新调整并重新合成，只有调整的区域会重新绘制
+	update := func() {
+		for _, setLabel := range labels {
+			setLabel()
+		}
+		adjust.SetAdjustments(filters.Adjustments{
+			Brightness: brightness.Value / 100,
+			Contrast:   contrast.Value / 100,
+			Gamma:      gamma.Value,
+			Saturation: saturation.Value / 100,
+			Invert:     invert.Checked,
+			Sharpen:    sharpen.Value / 100,
+		})
+		gs.ApplyFilters(false)
+	}
+	// set 设置所有的参数，只在最后更新一次
+	updating := false
+	set := func(b, c, g, s, sh float64, inv bool) {
+		updating = true
+		brightness.SetValue(b)
+		contrast.SetValue(c)
+		gamma.SetValue(g)
+		saturation.SetValue(s)
+		sharpen.SetValue(sh)
+		invert.SetChecked(inv)
+		updating = false
+		update()
+	}
+	onChanged := func(float64) {
+		if !updating {
+			update()
+		}
+	}
+	brightness.OnChanged, contrast.OnChanged, gamma.OnChanged = onChanged, onChanged, onChanged
+	saturation.OnChanged, sharpen.OnChanged = onChanged, onChanged
+	invert.OnChanged = func(bool) { onChanged(0) }
+	set(0, 0, 1, 0, 0, false)
+
+	presets := container.NewHBox(
+		widget.NewButton("灰度", func() { set(0, 0, 1, -100, 0, false) }),
+		widget.NewButton("暗背景", func() { set(-40, -20, 1, -100, 0, false) }),
+		widget.NewButton("增强对比度", func() { set(0, 40, 1, 20, 50, false) }),
+		widget.NewButton("重置", func() { set(0, 0, 1, 0, 0, false) }),
+	)
+	scope := "整张截图"
+	if !rect.Empty() {
+		scope = fmt.Sprintf("{%d, %d} - {%d, %d}", rect.Min.X, rect.Min.Y, rect.Max.X, rect.Max.Y)
+	}
+	items := []*widget.FormItem{
+		widget.NewFormItem("区域", widget.NewLabel(scope)),
+		widget.NewFormItem("亮度", brightnessRow),
+		widget.NewFormItem("对比度", contrastRow),
+		widget.NewFormItem("伽马", gammaRow),
+		widget.NewFormItem("饱和度", saturationRow),
+		widget.NewFormItem("锐化", sharpenRow),
+		widget.NewFormItem("", invert),
+		widget.NewFormItem("预设", presets),
+	}
+	dialog.ShowForm("调整图像", "确认", "取消", items,
+		func(ok bool) {
+			if !ok || adjust.IsZero() {
+				gs.removeFilter(adjust)
+				gs.status.SetText("Adjustment cancelled.")
+				return
+			}
+			gs.status.SetText("Adjustment added, use Control+Z to undo.")
+		}, gs.Win)
+}
@@ -0,0 +1,92 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"gitee.com/andrewgithub/FireShotGo/barcode"
+	"gitee.com/andrewgithub/FireShotGo/clipboard"
+	"gitee.com/andrewgithub/FireShotGo/filters"
+	"github.com/golang/glog"
+	"image"
+	"image/color"
+	"image/draw"
+	"net/url"
+)
+
+// barcodeColor 标出识别到的二维码和条形码使用的颜色
+var barcodeColor = color.RGBA{R: 0x22, G: 0xB1, B: 0x4C, A: 0xFF}
+
+// DetectCodes 选择一个区域(单击时使用整张截图)，查找其中的二维码和条形码
+func (gs *FireShotGO) DetectCodes() {
+	gs.viewPort.SetOp(SelectCodes)
+}
+
+// detectCodes 在后台查找 rect 区域(原始截图的坐标，为空时使用整张截图)中的二维码和条形码，
+// 并显示解码的内容，可以选择用矩形标出找到的码
+func (gs *FireShotGO) detectCodes(rect image.Rectangle) {
+	if rect.Empty() {
+		rect = gs.CropRect
+	}
+	rect = rect.Intersect(gs.CropRect)
+	if rect.Empty() {
+		return
+	}
+	region := image.NewRGBA(rect)
+	draw.Draw(region, rect, gs.Screenshot, rect.Min.Sub(gs.CropRect.Min), draw.Src)
+	gs.status.SetText("Looking for QR codes and barcodes ...")
+	go func() {
+		codes := barcode.Detect(region)
+		if len(codes) == 0 {
+			gs.status.SetText("No QR code or barcode found.")
+			return
+		}
+		gs.status.SetText(fmt.Sprintf("Found %d codes.", len(codes)))
+		gs.showCodes(codes)
+	}()
+}
+
+// showCodes 显示解码的内容，可以复制，网址可以直接打开。
+// 标注只在对话框的回调(界面的 goroutine)中添加。
+func (gs *FireShotGO) showCodes(codes []barcode.Code) {
+	list := container.NewVBox()
+	for _, c := range codes {
+		c := c
+		text := widget.NewLabel(c.Text)
+		text.Wrapping = fyne.TextWrapBreak
+		buttons := container.NewHBox(widget.NewButton("复制", func() {
+			if err := clipboard.CopyText(c.Text); err != nil {
+				glog.Errorf("Failed to copy text to clipboard: %s", err)
+				gs.status.SetText(fmt.Sprintf("Failed to copy text to clipboard: %s", err))
+				return
+			}
+			gs.status.SetText(fmt.Sprintf("%s content copied to clipboard.", c.Format))
+		}))
+		if u, err := url.Parse(c.Text); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			buttons.Add(widget.NewButton("打开", func() {
+				if err := gs.App.OpenURL(u); err != nil {
+					glog.Errorf("Failed to open %q: %s", u, err)
+					gs.status.SetText(fmt.Sprintf("Failed to open %q: %s", u, err))
+				}
+			}))
+		}
+		list.Add(container.NewBorder(nil, nil, widget.NewLabel(c.Format), buttons, text))
+	}
+	scroll := container.NewVScroll(list)
+	scroll.SetMinSize(fyne.NewSize(500, 200))
+	dialog.ShowCustomConfirm("二维码 / 条形码", "标出位置", "关闭", scroll, func(outline bool) {
+		if !outline {
+			return
+		}
+		for _, c := range codes {
+			r := filters.NewRectangle(c.Box, barcodeColor, 3)
+			r.SetStyle(filters.ShapeStyle{StrokeOpacity: 1, FillOpacity: 1}, 0)
+			r.SetAntiAlias(gs.viewPort.AntiAlias)
+			gs.Filters = append(gs.Filters, r)
+		}
+		gs.ApplyFilters(true)
+		gs.status.SetText(fmt.Sprintf("%d codes outlined, use Control+Z to remove the outlines.", len(codes)))
+	}, gs.Win)
+}
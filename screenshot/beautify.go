@@ -0,0 +1,253 @@
+package screenshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/validation"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"gitee.com/andrewgithub/FireShotGo/beautify"
+	"gitee.com/andrewgithub/FireShotGo/resources"
+	"github.com/golang/glog"
+	"image"
+	"image/color"
+	"strconv"
+)
+
+const (
+	BeautifyPreference        = "Beautify"
+	BeautifyOptionsPreference = "BeautifyOptions"
+	BeautifyPresetsPreference = "BeautifyPresets"
+)
+
+// ExportImage 返回需要导出(保存、复制、上传)的图片，开启美化之后返回美化过的图片，
+// 编辑中的截图和标注不会受影响
+func (gs *FireShotGO) ExportImage() image.Image {
+	if !gs.App.Preferences().Bool(BeautifyPreference) {
+		return gs.Screenshot
+	}
+	return beautify.Apply(gs.Screenshot, gs.beautifyOptions())
+}
+
+// beautifyOptions 读取保存的美化参数，没有保存过就使用第一个内置预设
+func (gs *FireShotGO) beautifyOptions() beautify.Options {
+	opts := beautify.Presets[0].Options
+	if saved := gs.App.Preferences().String(BeautifyOptionsPreference); saved != "" {
+		if err := json.Unmarshal([]byte(saved), &opts); err != nil {
+			glog.Warningf("Ignoring invalid beautify options %q: %s", saved, err)
+			opts = beautify.Presets[0].Options
+		}
+	}
+	return opts
+}
+
+func (gs *FireShotGO) setBeautifyOptions(opts beautify.Options) {
+	content, err := json.Marshal(opts)
+	if err != nil {
+		glog.Errorf("Failed to save beautify options: %s", err)
+		return
+	}
+	gs.App.Preferences().SetString(BeautifyOptionsPreference, string(content))
+}
+
+// beautifyPresets 返回内置预设加上用户保存的预设
+func (gs *FireShotGO) beautifyPresets() []beautify.Preset {
+	presets := append([]beautify.Preset(nil), beautify.Presets...)
+	return append(presets, gs.userBeautifyPresets()...)
+}
+
+func (gs *FireShotGO) userBeautifyPresets() (presets []beautify.Preset) {
+	saved := gs.App.Preferences().String(BeautifyPresetsPreference)
+	if saved == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(saved), &presets); err != nil {
+		glog.Warningf("Ignoring invalid beautify presets %q: %s", saved, err)
+		return nil
+	}
+	return presets
+}
+
+// saveBeautifyPreset 保存用户预设，同名的预设会被覆盖
+func (gs *FireShotGO) saveBeautifyPreset(preset beautify.Preset) {
+	presets := gs.userBeautifyPresets()
+	replaced := false
+	for ii := range presets {
+		if presets[ii].Name == preset.Name {
+			presets[ii] = preset
+			replaced = true
+		}
+	}
+	if !replaced {
+		presets = append(presets, preset)
+	}
+	content, err := json.Marshal(presets)
+	if err != nil {
+		glog.Errorf("Failed to save beautify presets: %s", err)
+		return
+	}
+	gs.App.Preferences().SetString(BeautifyPresetsPreference, string(content))
+}
+
+// BeautifyForm 美化导出的设置界面
+func (gs *FireShotGO) BeautifyForm() {
+	opts := gs.beautifyOptions()
+
+	enabled := widget.NewCheck("导出时美化截图", nil)
+	enabled.SetChecked(gs.App.Preferences().Bool(BeautifyPreference))
+
+	intEntry := func(v int) *widget.Entry {
+		e := &widget.Entry{Validator: validation.NewRegexp(`^-?\d+$`, "Must be an integer")}
+		e.SetText(strconv.Itoa(v))
+		return e
+	}
+	floatEntry := func(v float64) *widget.Entry {
+		e := &widget.Entry{Validator: validation.NewRegexp(`^\d+(\.\d*)?$`, "Must contain a number")}
+		e.SetText(fmt.Sprintf("%g", v))
+		return e
+	}
+	paddingEntry := intEntry(opts.Padding)
+	radiusEntry := floatEntry(opts.CornerRadius)
+	blurEntry := floatEntry(opts.ShadowBlur)
+	offsetXEntry := intEntry(opts.ShadowOffset.X)
+	offsetYEntry := intEntry(opts.ShadowOffset.Y)
+	borderEntry := floatEntry(opts.BorderWidth)
+
+	// 颜色选择: 按钮 + 清除 + 示例
+	colorRow := func(c *color.RGBA, title string) *fyne.Container {
+		sample := canvas.NewRectangle(*c)
+		sample.SetMinSize(fyne.NewSize(80, 20))
+		set := func(nc color.Color) {
+			*c = color.RGBAModel.Convert(nc).(color.RGBA)
+			sample.FillColor = *c
+			sample.Refresh()
+		}
+		return container.NewHBox(
+			widget.NewButtonWithIcon("", resources.ColorWheel, func() {
+				dialog.NewColorPicker("Pick a Color", title, set, gs.Win).Show()
+			}),
+			widget.NewButtonWithIcon("", resources.Reset, func() { set(Transparent) }),
+			sample,
+		)
+	}
+	backgroundRow := colorRow(&opts.Background, "Select background color")
+	gradientRow := colorRow(&opts.GradientTo, "Select gradient end color")
+	shadowRow := colorRow(&opts.ShadowColor, "Select shadow color")
+	borderRow := colorRow(&opts.BorderColor, "Select border color")
+	setSample := func(row *fyne.Container, c color.Color) {
+		sample := row.Objects[2].(*canvas.Rectangle)
+		sample.FillColor = c
+		sample.Refresh()
+	}
+
+	// 从界面读取参数
+	readOptions := func() (beautify.Options, error) {
+		var err error
+		o := opts
+		if o.Padding, err = strconv.Atoi(paddingEntry.Text); err != nil {
+			return o, fmt.Errorf("can't parse padding %q: %w", paddingEntry.Text, err)
+		}
+		if o.CornerRadius, err = strconv.ParseFloat(radiusEntry.Text, 64); err != nil {
+			return o, fmt.Errorf("can't parse corner radius %q: %w", radiusEntry.Text, err)
+		}
+		if o.ShadowBlur, err = strconv.ParseFloat(blurEntry.Text, 64); err != nil {
+			return o, fmt.Errorf("can't parse shadow blur %q: %w", blurEntry.Text, err)
+		}
+		if o.ShadowOffset.X, err = strconv.Atoi(offsetXEntry.Text); err != nil {
+			return o, fmt.Errorf("can't parse shadow offset %q: %w", offsetXEntry.Text, err)
+		}
+		if o.ShadowOffset.Y, err = strconv.Atoi(offsetYEntry.Text); err != nil {
+			return o, fmt.Errorf("can't parse shadow offset %q: %w", offsetYEntry.Text, err)
+		}
+		if o.BorderWidth, err = strconv.ParseFloat(borderEntry.Text, 64); err != nil {
+			return o, fmt.Errorf("can't parse border width %q: %w", borderEntry.Text, err)
+		}
+		return o, nil
+	}
+
+	// 选择预设之后把参数填入界面
+	presets := gs.beautifyPresets()
+	presetNames := make([]string, 0, len(presets))
+	for _, p := range presets {
+		presetNames = append(presetNames, p.Name)
+	}
+	presetSelect := widget.NewSelect(presetNames, func(name string) {
+		for _, p := range presets {
+			if p.Name != name {
+				continue
+			}
+			opts = p.Options
+			paddingEntry.SetText(strconv.Itoa(opts.Padding))
+			radiusEntry.SetText(fmt.Sprintf("%g", opts.CornerRadius))
+			blurEntry.SetText(fmt.Sprintf("%g", opts.ShadowBlur))
+			offsetXEntry.SetText(strconv.Itoa(opts.ShadowOffset.X))
+			offsetYEntry.SetText(strconv.Itoa(opts.ShadowOffset.Y))
+			borderEntry.SetText(fmt.Sprintf("%g", opts.BorderWidth))
+			setSample(backgroundRow, opts.Background)
+			setSample(gradientRow, opts.GradientTo)
+			setSample(shadowRow, opts.ShadowColor)
+			setSample(borderRow, opts.BorderColor)
+		}
+	})
+	presetSelect.PlaceHolder = "选择预设"
+
+	// 保存为用户预设
+	presetName := widget.NewEntry()
+	presetName.SetPlaceHolder("预设名称")
+	savePreset := widget.NewButton("保存预设", func() {
+		if presetName.Text == "" {
+			gs.status.SetText("Enter a name for the preset.")
+			return
+		}
+		o, err := readOptions()
+		if err != nil {
+			gs.status.SetText(err.Error())
+			return
+		}
+		gs.saveBeautifyPreset(beautify.Preset{Name: presetName.Text, Options: o})
+		gs.status.SetText(fmt.Sprintf("Beautify preset %q saved.", presetName.Text))
+	})
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("", enabled),
+		widget.NewFormItem("预设", presetSelect),
+		widget.NewFormItem("留白 (px)", paddingEntry),
+		widget.NewFormItem("背景颜色", backgroundRow),
+		widget.NewFormItem("渐变颜色", gradientRow),
+		widget.NewFormItem("圆角半径", radiusEntry),
+		widget.NewFormItem("阴影模糊", blurEntry),
+		widget.NewFormItem("阴影偏移 X", offsetXEntry),
+		widget.NewFormItem("阴影偏移 Y", offsetYEntry),
+		widget.NewFormItem("阴影颜色", shadowRow),
+		widget.NewFormItem("边框宽度", borderEntry),
+		widget.NewFormItem("边框颜色", borderRow),
+		widget.NewFormItem("", container.NewBorder(nil, nil, nil, savePreset, presetName)),
+	}
+	form := dialog.NewForm("美化导出", "确认", "取消", items,
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			o, err := readOptions()
+			if err != nil {
+				glog.Errorf("Invalid beautify options: %s", err)
+				gs.status.SetText(err.Error())
+				return
+			}
+			gs.setBeautifyOptions(o)
+			gs.App.Preferences().SetBool(BeautifyPreference, enabled.Checked)
+			if enabled.Checked {
+				gs.status.SetText("Beautify enabled for save, copy and share.")
+			} else {
+				gs.status.SetText("Beautify disabled.")
+			}
+		}, gs.Win)
+	size := gs.Win.Canvas().Size()
+	size.Width *= 0.90
+	size.Height *= 0.90
+	form.Resize(size)
+	form.Show()
+}
@@ -0,0 +1,219 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"gitee.com/andrewgithub/FireShotGo/collage"
+	"gitee.com/andrewgithub/FireShotGo/filters"
+	"image"
+	"image/color"
+	"strconv"
+	"time"
+)
+
+const (
+	// CollageArrangementPreference 拼接截图的排列方式
+	CollageArrangementPreference = "CollageArrangement"
+	// CollageColumnsPreference 网格排列的列数，0 表示自动
+	CollageColumnsPreference = "CollageColumns"
+	// CollageSpacingPreference 截图之间的间隔
+	CollageSpacingPreference = "CollageSpacing"
+	// CollageBackgroundPreference 拼接截图的背景
+	CollageBackgroundPreference = "CollageBackground"
+	// CollageSameSizePreference 是否把截图缩放到同样的大小
+	CollageSameSizePreference = "CollageSameSize"
+)
+
+// 拼接截图的背景选项
+const (
+	collageBackgroundWhite       = "白色"
+	collageBackgroundPreference  = "背景颜色"
+	collageBackgroundTransparent = "透明"
+)
+
+var (
+	// collageArrangements 排列方式的名称，顺序和 collage.Arrangement 相同
+	collageArrangements = []string{"网格", "横排", "竖排"}
+	collageBackgrounds  = []string{collageBackgroundWhite, collageBackgroundPreference, collageBackgroundTransparent}
+)
+
+// collageSource 可以拼接的一张截图
+type collageSource struct {
+	name string
+	// img 裁剪之后的原始截图，annotated 包含标注的截图
+	img, annotated image.Image
+}
+
+// collageSources 当前截图以及本次运行中之前的截图，按照从旧到新排列
+func (gs *FireShotGO) collageSources() []collageSource {
+	var sources []collageSource
+	for _, c := range gs.previousCaptures {
+		sources = append(sources, collageSource{name: c.name, img: c.cropped(), annotated: c.annotated})
+	}
+	current := cropImage(gs.OriginalScreenshot, gs.CropRect)
+	return append(sources, collageSource{name: gs.DefaultName(), img: current, annotated: gs.ExportImage()})
+}
+
+// CollageForm 将几张截图排列成一张新的截图(网格、横排或者竖排)，每张截图下方可以加上标题。
+// 标题作为文本标注加入，拼接之后可以继续编辑和添加标注。
+func (gs *FireShotGO) CollageForm() {
+	sources := gs.collageSources()
+	if len(sources) < 2 {
+		gs.status.SetText("Take at least two screenshots to create a collage.")
+		return
+	}
+
+	// 每张截图一行: 是否包含、标题以及上移的按钮，顺序就是拼接的顺序
+	type collageRow struct {
+		source  collageSource
+		check   *widget.Check
+		caption *widget.Entry
+	}
+	rows := make([]*collageRow, len(sources))
+	for ii, source := range sources {
+		row := &collageRow{source: source, check: widget.NewCheck("", nil), caption: widget.NewEntry()}
+		row.check.SetChecked(true)
+		row.caption.SetText(source.name)
+		rows[ii] = row
+	}
+	rowsBox := container.NewVBox()
+	var layoutRows func()
+	layoutRows = func() {
+		rowsBox.Objects = nil
+		for ii, row := range rows {
+			ii := ii
+			up := widget.NewButtonWithIcon("", theme.MoveUpIcon(), func() {
+				rows[ii-1], rows[ii] = rows[ii], rows[ii-1]
+				layoutRows()
+			})
+			if ii == 0 {
+				up.Disable()
+			}
+			rowsBox.Add(container.NewBorder(nil, nil, row.check, up, row.caption))
+		}
+		rowsBox.Refresh()
+	}
+	layoutRows()
+
+	prefs := gs.App.Preferences()
+	annotatedCheck := widget.NewCheck("包含标注", nil)
+	annotatedCheck.SetChecked(true)
+	arrangementRadio := widget.NewRadioGroup(collageArrangements, nil)
+	arrangementRadio.Horizontal = true
+	if arrangement := prefs.IntWithFallback(CollageArrangementPreference, int(collage.Grid)); arrangement >= 0 && arrangement < len(collageArrangements) {
+		arrangementRadio.SetSelected(collageArrangements[arrangement])
+	} else {
+		arrangementRadio.SetSelected(collageArrangements[collage.Grid])
+	}
+	number := func(s string) error {
+		if v, err := strconv.Atoi(s); err != nil || v < 0 {
+			return fmt.Errorf("请输入不小于 0 的整数")
+		}
+		return nil
+	}
+	columnsEntry := &widget.Entry{Validator: number, PlaceHolder: "0 表示自动"}
+	columnsEntry.SetText(strconv.Itoa(prefs.IntWithFallback(CollageColumnsPreference, 0)))
+	spacingEntry := &widget.Entry{Validator: number}
+	spacingEntry.SetText(strconv.Itoa(prefs.IntWithFallback(CollageSpacingPreference, 16)))
+	backgroundRadio := widget.NewRadioGroup(collageBackgrounds, nil)
+	backgroundRadio.Horizontal = true
+	backgroundRadio.SetSelected(prefs.StringWithFallback(CollageBackgroundPreference, collageBackgroundWhite))
+	sameSizeCheck := widget.NewCheck("缩放到同样的大小", nil)
+	sameSizeCheck.SetChecked(prefs.BoolWithFallback(CollageSameSizePreference, false))
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("截图和标题", rowsBox),
+		widget.NewFormItem("", annotatedCheck),
+		widget.NewFormItem("排列", arrangementRadio),
+		widget.NewFormItem("列数", columnsEntry),
+		widget.NewFormItem("间隔", spacingEntry),
+		widget.NewFormItem("背景", backgroundRadio),
+		widget.NewFormItem("", sameSizeCheck),
+	}
+	dialog.ShowForm("拼接截图", "拼接", "取消", items,
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			var opts collage.Options
+			for ii, name := range collageArrangements {
+				if name == arrangementRadio.Selected {
+					opts.Arrangement = collage.Arrangement(ii)
+				}
+			}
+			opts.Columns, _ = strconv.Atoi(columnsEntry.Text)
+			opts.Spacing, _ = strconv.Atoi(spacingEntry.Text)
+			opts.SameSize = sameSizeCheck.Checked
+			switch backgroundRadio.Selected {
+			case collageBackgroundPreference:
+				opts.Background = gs.viewPort.BackgroundColor
+			case collageBackgroundTransparent:
+				opts.Background = Transparent
+			default:
+				opts.Background = color.White
+			}
+			prefs.SetInt(CollageArrangementPreference, int(opts.Arrangement))
+			prefs.SetInt(CollageColumnsPreference, opts.Columns)
+			prefs.SetInt(CollageSpacingPreference, opts.Spacing)
+			prefs.SetString(CollageBackgroundPreference, backgroundRadio.Selected)
+			prefs.SetBool(CollageSameSizePreference, opts.SameSize)
+
+			var images []image.Image
+			var captions []string
+			for _, row := range rows {
+				if !row.check.Checked {
+					continue
+				}
+				if annotatedCheck.Checked {
+					images = append(images, row.source.annotated)
+				} else {
+					images = append(images, row.source.img)
+				}
+				captions = append(captions, row.caption.Text)
+			}
+			if len(images) == 0 {
+				gs.status.SetText("Select at least one screenshot for the collage.")
+				return
+			}
+			gs.createCollage(images, captions, opts)
+		}, gs.Win)
+}
+
+// createCollage 拼接 images 替换当前的截图(之前的截图保留在 previousCaptures 中)，
+// 并且在每张截图的下方加上标题
+func (gs *FireShotGO) createCollage(images []image.Image, captions []string, opts collage.Options) {
+	vp := gs.viewPort
+	for _, caption := range captions {
+		if caption != "" {
+			opts.CaptionHeight = int(2 * vp.FontSize)
+			break
+		}
+	}
+	img, cells := collage.Compose(images, opts)
+
+	// 标题的颜色和背景形成对比，透明的背景按照白色处理
+	captionColor := color.Color(color.Black)
+	if _, _, _, a := opts.Background.RGBA(); a > 0 && luminance(opts.Background) < 128 {
+		captionColor = color.White
+	}
+	gs.setScreenshot(img, time.Now())
+	gs.Filters = nil
+	for ii, caption := range captions {
+		if caption == "" {
+			continue
+		}
+		rect := cells[ii].Caption
+		center := image.Point{X: (rect.Min.X + rect.Max.X) / 2, Y: (rect.Min.Y + rect.Max.Y) / 2}
+		text := filters.NewText(caption, center, captionColor, Transparent, vp.FontSize)
+		text.SetStyle(vp.TextStyle)
+		gs.Filters = append(gs.Filters, text)
+	}
+	gs.ApplyFilters(true)
+	vp.postCrop()
+	gs.miniMap.Refresh()
+	gs.status.SetText(fmt.Sprintf("Collage of %d screenshots created, %d x %d pixels.",
+		len(images), img.Rect.Dx(), img.Rect.Dy()))
+}
@@ -0,0 +1,429 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CropAspectPreference 拖动裁剪时锁定的宽高比，例如 "16:9"，为空时不锁定
+const CropAspectPreference = "CropAspect"
+
+// cropAspectFree 不锁定宽高比，cropAspectCustom 输入自定义的宽高比
+const (
+	cropAspectFree   = "自由"
+	cropAspectCustom = "自定义..."
+)
+
+// cropAspectPresets 内置的宽高比
+var cropAspectPresets = []string{cropAspectFree, "16:9", "4:3", "1:1"}
+
+// parseAspect 解析 "宽:高" 格式的宽高比，不锁定时返回 0
+func parseAspect(aspect string) (float64, error) {
+	if aspect == "" || aspect == cropAspectFree {
+		return 0, nil
+	}
+	parts := strings.Split(aspect, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("宽高比的格式是 宽:高，例如 3:2")
+	}
+	w, errW := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	h, errH := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if errW != nil || errH != nil || w <= 0 || h <= 0 {
+		return 0, fmt.Errorf("宽高比的格式是 宽:高，例如 3:2")
+	}
+	return w / h, nil
+}
+
+// cropEdge 拖动裁剪区域时移动的边，cropMove 表示移动整个区域
+type cropEdge int
+
+const (
+	cropEdgeLeft cropEdge = 1 << iota
+	cropEdgeRight
+	cropEdgeTop
+	cropEdgeBottom
+	cropMove
+)
+
+// cropHandleSize 裁剪区域的控制点的大小(视图像素)
+const cropHandleSize = 7
+
+var (
+	cropShadeColor  = color.NRGBA{A: 0x90}
+	cropBorderColor = color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+	cropHandleColor = color.NRGBA{R: 0x20, G: 0x20, B: 0x20, A: 0xFF}
+)
+
+// CropDrag 拖动选择裁剪的区域，之后可以拖动控制点调整，单击区域内或者按回车裁剪
+func (gs *FireShotGO) CropDrag() {
+	gs.viewPort.SetOp(CropDrag)
+}
+
+// cropAspect 当前锁定的宽高比，不锁定时返回 0
+func (vp *ViewPort) cropAspect() float64 {
+	ratio, err := parseAspect(vp.CropAspect)
+	if err != nil {
+		return 0
+	}
+	return ratio
+}
+
+// cropSelectionView 裁剪区域在视图中的位置
+func (vp *ViewPort) cropSelectionView() image.Rectangle {
+	r := vp.cropSelection.Sub(vp.fs.CropRect.Min)
+	return image.Rect(vp.cacheX(float64(r.Min.X)), vp.cacheY(float64(r.Min.Y)),
+		vp.cacheX(float64(r.Max.X)), vp.cacheY(float64(r.Max.Y)))
+}
+
+// cropHandles 控制点在视图中的位置，以及拖动时移动的边
+func (vp *ViewPort) cropHandles() map[cropEdge]image.Point {
+	r := vp.cropSelectionView()
+	cx, cy := (r.Min.X+r.Max.X)/2, (r.Min.Y+r.Max.Y)/2
+	return map[cropEdge]image.Point{
+		cropEdgeLeft | cropEdgeTop:     r.Min,
+		cropEdgeTop:                    {X: cx, Y: r.Min.Y},
+		cropEdgeRight | cropEdgeTop:    {X: r.Max.X, Y: r.Min.Y},
+		cropEdgeRight:                  {X: r.Max.X, Y: cy},
+		cropEdgeRight | cropEdgeBottom: r.Max,
+		cropEdgeBottom:                 {X: cx, Y: r.Max.Y},
+		cropEdgeLeft | cropEdgeBottom:  {X: r.Min.X, Y: r.Max.Y},
+		cropEdgeLeft:                   {X: r.Min.X, Y: cy},
+	}
+}
+
+// renderCropSelection 将裁剪区域以外的部分变暗，并画出边框和控制点
+func (vp *ViewPort) renderCropSelection() {
+	if vp.cropSelection.Empty() {
+		return
+	}
+	w, h := wh(vp.cache)
+	r := vp.cropSelectionView()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := image.Point{X: x, Y: y}
+			switch {
+			case !p.In(r.Inset(-1)):
+				blendPixel(vp.cache, x, y, cropShadeColor)
+			case !p.In(r):
+				blendPixel(vp.cache, x, y, cropBorderColor)
+			}
+		}
+	}
+	const half = cropHandleSize / 2
+	for _, c := range vp.cropHandles() {
+		for y := -half; y <= half; y++ {
+			for x := -half; x <= half; x++ {
+				handleColor := cropBorderColor
+				if abs(x) == half || abs(y) == half {
+					handleColor = cropHandleColor
+				}
+				blendPixel(vp.cache, c.X+x, c.Y+y, handleColor)
+			}
+		}
+	}
+}
+
+// startCropDrag 在控制点上开始拖动时调整对应的边，在裁剪区域内时移动区域，否则选择新的区域
+func (vp *ViewPort) startCropDrag(pos fyne.Position) {
+	vp.cropDragFrom = vp.annotationPoint(pos, nil)
+	vp.cropDragRect = vp.cropSelection
+	vp.cropDragEdges = 0
+	if !vp.cropSelection.Empty() {
+		px, py := vp.PosToPixel(pos)
+		for edges, c := range vp.cropHandles() {
+			if abs(c.X-px) <= cropHandleSize && abs(c.Y-py) <= cropHandleSize {
+				vp.cropDragEdges = edges
+				break
+			}
+		}
+		if vp.cropDragEdges == 0 && (image.Point{X: px, Y: py}).In(vp.cropSelectionView()) {
+			vp.cropDragEdges = cropMove
+		}
+	}
+	if vp.cropDragEdges == 0 {
+		// 新的区域: 固定开始的点，拖动右下角
+		vp.cropDragRect = image.Rectangle{Min: vp.cropDragFrom, Max: vp.cropDragFrom}
+		vp.cropDragEdges = cropEdgeRight | cropEdgeBottom
+	}
+}
+
+// dragCrop 按照拖动的控制点修改裁剪区域，锁定宽高比时调整另一个方向，区域不会超出当前的截图
+func (vp *ViewPort) dragCrop(pos fyne.Position) {
+	bounds := vp.fs.CropRect
+	p := vp.annotationPoint(pos, nil)
+	p.X = clampInt(p.X, bounds.Min.X, bounds.Max.X)
+	p.Y = clampInt(p.Y, bounds.Min.Y, bounds.Max.Y)
+	r := vp.cropDragRect
+	edges := vp.cropDragEdges
+
+	if edges == cropMove {
+		r = r.Add(p.Sub(vp.cropDragFrom))
+		// 整个区域移回截图以内
+		shift := image.Point{
+			X: maxInt(bounds.Min.X-r.Min.X, 0) + minInt(bounds.Max.X-r.Max.X, 0),
+			Y: maxInt(bounds.Min.Y-r.Min.Y, 0) + minInt(bounds.Max.Y-r.Max.Y, 0),
+		}
+		vp.setCropSelection(r.Add(shift))
+		return
+	}
+
+	if edges&cropEdgeLeft != 0 {
+		r.Min.X = p.X
+	}
+	if edges&cropEdgeRight != 0 {
+		r.Max.X = p.X
+	}
+	if edges&cropEdgeTop != 0 {
+		r.Min.Y = p.Y
+	}
+	if edges&cropEdgeBottom != 0 {
+		r.Max.Y = p.Y
+	}
+	if ratio := vp.cropAspect(); ratio > 0 {
+		r = constrainAspect(r, edges, ratio, bounds)
+	}
+	vp.setCropSelection(r.Canon())
+}
+
+// constrainAspect 调整 r 使宽高比为 ratio: 拖动角时缩小较长的一边，拖动边时以中线为准调整另一个方向。
+// r 还没有规范化，拖过对边时宽或高是负数。
+func constrainAspect(r image.Rectangle, edges cropEdge, ratio float64, bounds image.Rectangle) image.Rectangle {
+	sign := func(v int) int {
+		if v < 0 {
+			return -1
+		}
+		return 1
+	}
+	w, h := r.Dx(), r.Dy()
+	sw, sh := sign(w), sign(h)
+	aw, ah := float64(abs(w)), float64(abs(h))
+	horizontal := edges&(cropEdgeLeft|cropEdgeRight) != 0
+	vertical := edges&(cropEdgeTop|cropEdgeBottom) != 0
+
+	switch {
+	case horizontal && vertical:
+		if aw > ah*ratio {
+			aw = ah * ratio
+		} else {
+			ah = aw / ratio
+		}
+	case horizontal:
+		// 高度以中线为准，不能超出截图
+		cy := float64(r.Min.Y+r.Max.Y) / 2
+		ah = math.Min(aw/ratio, 2*math.Min(cy-float64(bounds.Min.Y), float64(bounds.Max.Y)-cy))
+		aw = ah * ratio
+		r.Min.Y, r.Max.Y = int(math.Round(cy-ah/2)), int(math.Round(cy+ah/2))
+	case vertical:
+		cx := float64(r.Min.X+r.Max.X) / 2
+		aw = math.Min(ah*ratio, 2*math.Min(cx-float64(bounds.Min.X), float64(bounds.Max.X)-cx))
+		ah = aw / ratio
+		r.Min.X, r.Max.X = int(math.Round(cx-aw/2)), int(math.Round(cx+aw/2))
+	}
+
+	// 只移动拖动的边，对边保持不动；只拖动一条边时另一个方向已经按照中线设置
+	if horizontal {
+		if edges&cropEdgeLeft != 0 {
+			r.Min.X = r.Max.X - sw*int(math.Round(aw))
+		} else {
+			r.Max.X = r.Min.X + sw*int(math.Round(aw))
+		}
+	}
+	if vertical {
+		if edges&cropEdgeTop != 0 {
+			r.Min.Y = r.Max.Y - sh*int(math.Round(ah))
+		} else {
+			r.Max.Y = r.Min.Y + sh*int(math.Round(ah))
+		}
+	}
+	return r
+}
+
+// setCropSelection 修改裁剪区域并在状态栏显示大小
+func (vp *ViewPort) setCropSelection(r image.Rectangle) {
+	vp.cropSelection = r
+	vp.Refresh()
+	vp.fs.status.SetText(fmt.Sprintf("Crop {%d, %d} - {%d, %d}, %d x %d pixels: click inside or press Enter to crop, Esc to cancel.",
+		r.Min.X, r.Min.Y, r.Max.X, r.Max.Y, r.Dx(), r.Dy()))
+}
+
+// applyCropSelection 裁剪到选择的区域
+func (vp *ViewPort) applyCropSelection() {
+	rect := vp.cropSelection
+	if rect.Empty() {
+		vp.fs.status.SetText("Drag to select the region to crop first.")
+		return
+	}
+	vp.cropTo(rect)
+}
+
+// cropTo 将裁剪区域设为 rect (原始截图的坐标)，并将视图移到左上角
+func (vp *ViewPort) cropTo(rect image.Rectangle) {
+	vp.SetOp(NoOp)
+	vp.fs.CropRect = rect.Intersect(vp.fs.OriginalScreenshot.Rect)
+	vp.fs.ApplyFilters(true)
+	vp.viewX, vp.viewY = 0, 0
+	vp.postCrop()
+}
+
+// setCropAspect 修改锁定的宽高比并保存，同时更新工具栏的选择
+func (gs *FireShotGO) setCropAspect(aspect string) {
+	if aspect == cropAspectFree {
+		aspect = ""
+	}
+	gs.viewPort.CropAspect = aspect
+	gs.App.Preferences().SetString(CropAspectPreference, aspect)
+	if gs.cropAspectSelect != nil {
+		gs.cropAspectSelect.Options = cropAspectOptions(aspect)
+		gs.cropAspectSelect.SetSelected(cropAspectName(aspect))
+	}
+}
+
+// cropAspectName 宽高比在选择框中显示的名称
+func cropAspectName(aspect string) string {
+	if aspect == "" {
+		return cropAspectFree
+	}
+	return aspect
+}
+
+// cropAspectOptions 选择框的选项: 内置的宽高比、当前自定义的宽高比以及输入自定义的宽高比
+func cropAspectOptions(aspect string) []string {
+	options := append([]string(nil), cropAspectPresets...)
+	name := cropAspectName(aspect)
+	found := false
+	for _, preset := range cropAspectPresets {
+		found = found || preset == name
+	}
+	if !found {
+		options = append(options, name)
+	}
+	return append(options, cropAspectCustom)
+}
+
+// makeCropAspectSelect 工具栏中选择拖动裁剪时锁定的宽高比
+func (gs *FireShotGO) makeCropAspectSelect() *widget.Select {
+	aspect := gs.viewPort.CropAspect
+	gs.cropAspectSelect = widget.NewSelect(cropAspectOptions(aspect), nil)
+	gs.cropAspectSelect.SetSelected(cropAspectName(aspect))
+	gs.cropAspectSelect.OnChanged = func(name string) {
+		switch name {
+		case cropAspectCustom:
+			gs.customCropAspectForm()
+		case cropAspectName(gs.viewPort.CropAspect):
+			// 没有变化
+		default:
+			gs.setCropAspect(name)
+		}
+	}
+	return gs.cropAspectSelect
+}
+
+// customCropAspectForm 输入自定义的宽高比
+func (gs *FireShotGO) customCropAspectForm() {
+	previous := gs.viewPort.CropAspect
+	entry := widget.NewEntry()
+	entry.SetPlaceHolder("3:2")
+	entry.Validator = func(s string) error {
+		_, err := parseAspect(s)
+		return err
+	}
+	items := []*widget.FormItem{widget.NewFormItem("宽高比", entry)}
+	dialog.ShowForm("自定义宽高比", "确认", "取消", items,
+		func(ok bool) {
+			if ok && entry.Validate() == nil && entry.Text != "" {
+				gs.setCropAspect(strings.ReplaceAll(entry.Text, " ", ""))
+			} else {
+				gs.setCropAspect(previous)
+			}
+		}, gs.Win)
+}
+
+// CropForm 输入裁剪区域的位置和大小(原始截图的坐标)，锁定宽高比时修改宽度会同时修改高度
+func (gs *FireShotGO) CropForm() {
+	vp := gs.viewPort
+	rect := gs.CropRect
+	if !vp.cropSelection.Empty() {
+		rect = vp.cropSelection
+	}
+	bounds := gs.OriginalScreenshot.Rect
+	number := func(min, max int) func(string) error {
+		return func(s string) error {
+			if v, err := strconv.Atoi(s); err != nil || v < min || v > max {
+				return fmt.Errorf("范围为 %d 到 %d", min, max)
+			}
+			return nil
+		}
+	}
+	newEntry := func(value, min, max int) *widget.Entry {
+		e := widget.NewEntry()
+		e.SetText(strconv.Itoa(value))
+		e.Validator = number(min, max)
+		return e
+	}
+	xEntry := newEntry(rect.Min.X, bounds.Min.X, bounds.Max.X-1)
+	yEntry := newEntry(rect.Min.Y, bounds.Min.Y, bounds.Max.Y-1)
+	widthEntry := newEntry(rect.Dx(), 1, bounds.Dx())
+	heightEntry := newEntry(rect.Dy(), 1, bounds.Dy())
+	// 宽高的范围和左上角有关: 裁剪区域不能超出截图
+	limit := func(start *widget.Entry, max int) func(string) error {
+		return func(s string) error {
+			from, err := strconv.Atoi(start.Text)
+			if err != nil {
+				return number(1, max-bounds.Min.X)(s)
+			}
+			return number(1, max-from)(s)
+		}
+	}
+	widthEntry.Validator = limit(xEntry, bounds.Max.X)
+	heightEntry.Validator = limit(yEntry, bounds.Max.Y)
+	xEntry.OnChanged = func(string) { widthEntry.Validate() }
+	yEntry.OnChanged = func(string) { heightEntry.Validate() }
+
+	// 表单中不能再打开自定义宽高比的对话框，所以去掉最后一个选项
+	options := cropAspectOptions(vp.CropAspect)
+	aspectSelect := widget.NewSelect(options[:len(options)-1], nil)
+	aspectSelect.SetSelected(cropAspectName(vp.CropAspect))
+	// 锁定宽高比时按照宽度计算高度
+	updateHeight := func() {
+		ratio, _ := parseAspect(aspectSelect.Selected)
+		if w, err := strconv.Atoi(widthEntry.Text); err == nil && ratio > 0 {
+			heightEntry.SetText(strconv.Itoa(int(math.Round(float64(w) / ratio))))
+		}
+	}
+	widthEntry.OnChanged = func(string) { updateHeight() }
+	aspectSelect.OnChanged = func(string) { updateHeight() }
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("截图大小", widget.NewLabel(fmt.Sprintf("%d x %d", bounds.Dx(), bounds.Dy()))),
+		widget.NewFormItem("X", xEntry),
+		widget.NewFormItem("Y", yEntry),
+		widget.NewFormItem("宽度(像素)", widthEntry),
+		widget.NewFormItem("高度(像素)", heightEntry),
+		widget.NewFormItem("宽高比", aspectSelect),
+	}
+	dialog.ShowForm("精确裁剪", "裁剪", "取消", items,
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			for _, e := range []*widget.Entry{xEntry, yEntry, widthEntry, heightEntry} {
+				if err := e.Validate(); err != nil {
+					gs.status.SetText(fmt.Sprintf("Invalid crop: %s", err))
+					return
+				}
+			}
+			x, _ := strconv.Atoi(xEntry.Text)
+			y, _ := strconv.Atoi(yEntry.Text)
+			w, _ := strconv.Atoi(widthEntry.Text)
+			h, _ := strconv.Atoi(heightEntry.Text)
+			gs.setCropAspect(aspectSelect.Selected)
+			vp.cropTo(image.Rect(x, y, x+w, y+h))
+		}, gs.Win)
+}
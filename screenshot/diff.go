@@ -0,0 +1,218 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+	"gitee.com/andrewgithub/FireShotGo/diff"
+	"gitee.com/andrewgithub/FireShotGo/filters"
+	"github.com/golang/glog"
+	"image"
+	"image/color"
+	"path"
+)
+
+const (
+	// DiffThresholdPreference 比较截图时颜色差异的容差 [0, 1]
+	DiffThresholdPreference = "DiffThreshold"
+	// DiffPerceptualPreference 比较截图时是否使用感知的颜色差异
+	DiffPerceptualPreference = "DiffPerceptual"
+	// DiffAlignPreference 比较截图之前是否自动对齐
+	DiffAlignPreference = "DiffAlign"
+)
+
+// diffColor 标出变化区域使用的颜色
+var diffColor = color.RGBA{R: 0xFF, G: 0x00, B: 0xFF, A: 0xFF}
+
+// diffOptions 按照保存的设置返回比较的参数
+func (gs *FireShotGO) diffOptions() diff.Options {
+	prefs := gs.App.Preferences()
+	opts := diff.DefaultOptions
+	opts.Threshold = prefs.FloatWithFallback(DiffThresholdPreference, opts.Threshold)
+	opts.Perceptual = prefs.BoolWithFallback(DiffPerceptualPreference, opts.Perceptual)
+	if !prefs.BoolWithFallback(DiffAlignPreference, true) {
+		opts.AlignRadius = 0
+	}
+	return opts
+}
+
+// CompareForm 将当前的截图(裁剪区域，不包括标注)和之前的截图或者图片文件比较，
+// 用矩形标出变化的区域，并显示差异、并排以及叠加的对比
+func (gs *FireShotGO) CompareForm() {
+	const fromFile = "打开图片文件..."
+	var options []string
+	for ii := len(gs.previousCaptures) - 1; ii >= 0; ii-- {
+		options = append(options, gs.previousCaptures[ii].name)
+	}
+	options = append(options, fromFile)
+	sourceSelect := widget.NewSelect(options, nil)
+	sourceSelect.SetSelected(options[0])
+
+	opts := gs.diffOptions()
+	thresholdLabel := widget.NewLabel("")
+	thresholdSlider := widget.NewSlider(0, 0.5)
+	thresholdSlider.Step = 0.01
+	thresholdSlider.OnChanged = func(v float64) { thresholdLabel.SetText(fmt.Sprintf("%.2f", v)) }
+	thresholdSlider.SetValue(opts.Threshold)
+	perceptualCheck := widget.NewCheck("使用感知的颜色差异(YIQ)", nil)
+	perceptualCheck.SetChecked(opts.Perceptual)
+	alignCheck := widget.NewCheck(fmt.Sprintf("自动对齐(最多平移 %d 像素)", diff.DefaultOptions.AlignRadius), nil)
+	alignCheck.SetChecked(opts.AlignRadius > 0)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("之前的截图", sourceSelect),
+		widget.NewFormItem("容差", container.NewBorder(nil, nil, nil, thresholdLabel, thresholdSlider)),
+		widget.NewFormItem("", perceptualCheck),
+		widget.NewFormItem("", alignCheck),
+	}
+	dialog.ShowForm("比较截图", "比较", "取消", items,
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			prefs := gs.App.Preferences()
+			prefs.SetFloat(DiffThresholdPreference, thresholdSlider.Value)
+			prefs.SetBool(DiffPerceptualPreference, perceptualCheck.Checked)
+			prefs.SetBool(DiffAlignPreference, alignCheck.Checked)
+			opts := gs.diffOptions()
+
+			if sourceSelect.Selected == fromFile {
+				gs.compareWithFile(opts)
+				return
+			}
+			for _, c := range gs.previousCaptures {
+				if c.name == sourceSelect.Selected {
+					gs.compareWith(c.cropped(), c.name, opts)
+					return
+				}
+			}
+		}, gs.Win)
+}
+
+// compareWithFile 选择图片文件作为之前的截图进行比较
+func (gs *FireShotGO) compareWithFile(opts diff.Options) {
+	fileOpen := dialog.NewFileOpen(
+		func(reader fyne.URIReadCloser, err error) {
+			if err != nil {
+				glog.Errorf("Failed to open image: %s", err)
+				gs.status.SetText(fmt.Sprintf("Failed to open image: %s", err))
+				return
+			}
+			if reader == nil {
+				gs.status.SetText("Compare cancelled.")
+				return
+			}
+			defer func() { _ = reader.Close() }()
+			gs.App.Preferences().SetString(DefaultPathPreference, path.Dir(reader.URI().Path()))
+
+			img, _, err := image.Decode(reader)
+			if err != nil {
+				glog.Errorf("Failed to decode image %q: %s", reader.URI(), err)
+				gs.status.SetText(fmt.Sprintf("Failed to decode image %q: %s", reader.URI(), err))
+				return
+			}
+			gs.compareWith(img, reader.URI().Name(), opts)
+		}, gs.Win)
+	fileOpen.SetFilter(storage.NewExtensionFileFilter([]string{".png", ".jpg", ".jpeg", ".gif"}))
+	if defaultPath := gs.App.Preferences().String(DefaultPathPreference); defaultPath != "" {
+		lister, err := storage.ListerForURI(storage.NewFileURI(defaultPath))
+		if err == nil {
+			fileOpen.SetLocation(lister)
+		}
+	}
+	size := gs.Win.Canvas().Size()
+	size.Width *= 0.90
+	size.Height *= 0.90
+	fileOpen.Resize(size)
+	fileOpen.Show()
+}
+
+// compareWith 在后台比较 before 和当前的截图(裁剪区域)，完成之后显示比较的结果
+func (gs *FireShotGO) compareWith(before image.Image, name string, opts diff.Options) {
+	crop := gs.CropRect
+	after := cropImage(gs.OriginalScreenshot, crop)
+	gs.status.SetText(fmt.Sprintf("Comparing with %q ...", name))
+	go func() {
+		res := diff.Compare(before, after, opts)
+		summary := fmt.Sprintf("%d changed regions, %.2f%% of pixels changed, offset (%d, %d)",
+			len(res.Regions), 100*res.Ratio(), res.Offset.X, res.Offset.Y)
+		if res.SizeChanged() {
+			summary += fmt.Sprintf(", size changed from %d x %d to %d x %d",
+				res.BeforeSize.X, res.BeforeSize.Y, res.AfterSize.X, res.AfterSize.Y)
+		}
+		gs.status.SetText(summary + ".")
+		gs.reviewDiff(before, after, res, crop, name, summary)
+	}()
+}
+
+// reviewDiff 显示比较的结果，可以选择用矩形标出变化的区域以及打开对比的窗口。
+// 标注和窗口只在对话框的回调(界面的 goroutine)中添加和打开。
+// crop 是比较时的裁剪区域，用于将区域换算为原始截图的坐标。
+func (gs *FireShotGO) reviewDiff(before, after image.Image, res *diff.Result, crop image.Rectangle, name, summary string) {
+	outlineCheck := widget.NewCheck("在截图上标出变化的区域", nil)
+	outlineCheck.SetChecked(len(res.Regions) > 0)
+	if len(res.Regions) == 0 {
+		outlineCheck.Disable()
+	}
+	content := container.NewVBox(widget.NewLabel(summary), outlineCheck)
+	dialog.ShowCustomConfirm("比较截图", "显示对比", "关闭", content, func(show bool) {
+		if outlineCheck.Checked {
+			for _, region := range res.Regions {
+				// 区域使用 before 的坐标，after 中的位置要减去平移
+				rect := region.Rect.Sub(res.Offset).Add(crop.Min).Inset(-2)
+				r := filters.NewRectangle(rect, diffColor, 2)
+				r.SetStyle(filters.ShapeStyle{StrokeOpacity: 1, FillOpacity: 1}, 0)
+				r.SetAntiAlias(gs.viewPort.AntiAlias)
+				gs.Filters = append(gs.Filters, r)
+			}
+			gs.ApplyFilters(true)
+			gs.status.SetText(summary + ", use Control+Z to remove the outlines.")
+		}
+		if show {
+			gs.showDiff(before, after, res, name, summary)
+		}
+	}, gs.Win)
+}
+
+// showDiff 在新窗口中显示差异图、并排对比，以及可以用滑块调整透明度的叠加对比
+func (gs *FireShotGO) showDiff(before, after image.Image, res *diff.Result, name, summary string) {
+	newImage := func(img image.Image) *canvas.Image {
+		c := canvas.NewImageFromImage(img)
+		c.FillMode = canvas.ImageFillContain
+		c.ScaleMode = canvas.ImageScalePixels
+		return c
+	}
+	a, b := res.Layout(before, after)
+	highlight := newImage(res.Highlight(before))
+
+	sideBySide := container.NewHSplit(
+		container.NewBorder(widget.NewLabel("之前: "+name), nil, nil, nil, newImage(a)),
+		container.NewBorder(widget.NewLabel("现在"), nil, nil, nil, newImage(b)))
+
+	// 叠加: 现在的截图在上面，滑块在最左边时完全透明，只显示之前的截图
+	onionBefore, onionAfter := newImage(a), newImage(b)
+	onionSlider := widget.NewSlider(0, 1)
+	onionSlider.Step = 0.01
+	onionSlider.OnChanged = func(v float64) {
+		onionAfter.Translucency = 1 - v
+		onionAfter.Refresh()
+	}
+	onionSlider.SetValue(0.5)
+	onion := container.NewBorder(nil,
+		container.NewBorder(nil, nil, widget.NewLabel("之前"), widget.NewLabel("现在"), onionSlider),
+		nil, nil, container.NewMax(onionBefore, onionAfter))
+
+	tabs := container.NewAppTabs(
+		container.NewTabItem("差异", highlight),
+		container.NewTabItem("并排", sideBySide),
+		container.NewTabItem("叠加", onion),
+	)
+	win := gs.App.NewWindow("比较截图")
+	win.SetContent(container.NewBorder(nil, widget.NewLabel(summary), nil, nil, tabs))
+	win.Resize(gs.Win.Canvas().Size())
+	win.Show()
+}
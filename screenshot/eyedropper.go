@@ -0,0 +1,268 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"gitee.com/andrewgithub/FireShotGo/clipboard"
+	"github.com/golang/glog"
+	"image"
+	"image/color"
+	"math"
+)
+
+// ColorFormatPreference 取色时复制到剪贴板的颜色格式
+const ColorFormatPreference = "ColorFormat"
+
+// 颜色的文本格式
+const (
+	ColorFormatHEX = "HEX"
+	ColorFormatRGB = "RGB"
+	ColorFormatHSL = "HSL"
+	ColorFormatHSV = "HSV"
+)
+
+// colorFormats 所有的颜色格式，按照显示的顺序
+var colorFormats = []string{ColorFormatHEX, ColorFormatRGB, ColorFormatHSL, ColorFormatHSV}
+
+// 放大镜: 显示鼠标周围 (2*loupeRadius+1) x (2*loupeRadius+1) 个像素，每个像素放大 loupeScale 倍
+const (
+	loupeRadius = 7
+	loupeScale  = 8
+)
+
+// loupeOffset 放大镜相对鼠标的位置，避免挡住鼠标所指的像素
+var loupeOffset = fyne.NewPos(16, 16)
+
+// formatColor 使用 format 格式表示颜色，不透明时不输出 alpha
+func formatColor(c color.Color, format string) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	opaque := n.A == 0xFF
+	alpha := float64(n.A) / 0xFF
+	switch format {
+	case ColorFormatRGB:
+		if opaque {
+			return fmt.Sprintf("rgb(%d, %d, %d)", n.R, n.G, n.B)
+		}
+		return fmt.Sprintf("rgba(%d, %d, %d, %.2f)", n.R, n.G, n.B, alpha)
+	case ColorFormatHSL:
+		h, s, l := rgbToHSL(n)
+		if opaque {
+			return fmt.Sprintf("hsl(%.0f, %.0f%%, %.0f%%)", h, s*100, l*100)
+		}
+		return fmt.Sprintf("hsla(%.0f, %.0f%%, %.0f%%, %.2f)", h, s*100, l*100, alpha)
+	case ColorFormatHSV:
+		h, s, v := rgbToHSV(n)
+		return fmt.Sprintf("hsv(%.0f, %.0f%%, %.0f%%)", h, s*100, v*100)
+	default:
+		if opaque {
+			return fmt.Sprintf("#%02X%02X%02X", n.R, n.G, n.B)
+		}
+		return fmt.Sprintf("#%02X%02X%02X%02X", n.R, n.G, n.B, n.A)
+	}
+}
+
+// rgbComponents 返回 [0, 1] 之间的 r, g, b 以及其中的最大值和最小值
+func rgbComponents(n color.NRGBA) (r, g, b, max, min float64) {
+	r, g, b = float64(n.R)/0xFF, float64(n.G)/0xFF, float64(n.B)/0xFF
+	return r, g, b, math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+}
+
+// hue 色相 [0, 360)
+func hue(r, g, b, max, min float64) float64 {
+	d := max - min
+	if d == 0 {
+		return 0
+	}
+	var h float64
+	switch max {
+	case r:
+		h = math.Mod((g-b)/d, 6)
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// rgbToHSL 色相 [0, 360)，饱和度和亮度 [0, 1]
+func rgbToHSL(n color.NRGBA) (h, s, l float64) {
+	r, g, b, max, min := rgbComponents(n)
+	l = (max + min) / 2
+	if d := max - min; d > 0 {
+		s = d / (1 - math.Abs(2*l-1))
+	}
+	return hue(r, g, b, max, min), s, l
+}
+
+// rgbToHSV 色相 [0, 360)，饱和度和明度 [0, 1]
+func rgbToHSV(n color.NRGBA) (h, s, v float64) {
+	r, g, b, max, min := rgbComponents(n)
+	if max > 0 {
+		s = (max - min) / max
+	}
+	return hue(r, g, b, max, min), s, max
+}
+
+// screenshotPixel 和 screenshotPos 的换算相同，返回的是鼠标所在的像素(向下取整)，
+// 而不是最接近的像素边界
+func (vp *ViewPort) screenshotPixel(pos fyne.Position) image.Point {
+	size := vp.Size()
+	x := float64(pos.X/size.Width*float32(vp.viewW)) + float64(vp.viewX)
+	y := float64(pos.Y/size.Height*float32(vp.viewH)) + float64(vp.viewY)
+	return image.Point{X: int(math.Floor(x)), Y: int(math.Floor(y))}
+}
+
+// pixelAt 截图(包含标注，坐标相对于裁剪区域)中 pt 的颜色，pt 不在截图中时返回 false
+func (vp *ViewPort) pixelAt(pt image.Point) (color.Color, bool) {
+	img := vp.fs.Screenshot
+	if img == nil || !pt.In(img.Bounds()) {
+		return nil, false
+	}
+	return img.At(pt.X, pt.Y), true
+}
+
+// updateLoupe 重新绘制 pt 周围像素的放大镜，并在状态栏显示 pt 的颜色
+func (vp *ViewPort) updateLoupe(pt image.Point) {
+	const cells = 2*loupeRadius + 1
+	const size = cells * loupeScale
+	img := image.NewRGBA(image.Rect(0, 0, size+1, size+1))
+	gridColor := color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xFF}
+	for cy := 0; cy < cells; cy++ {
+		for cx := 0; cx < cells; cx++ {
+			c, ok := vp.pixelAt(pt.Add(image.Point{X: cx - loupeRadius, Y: cy - loupeRadius}))
+			for y := 0; y < loupeScale; y++ {
+				for x := 0; x < loupeScale; x++ {
+					px, py := cx*loupeScale+x, cy*loupeScale+y
+					if x == 0 || y == 0 {
+						img.Set(px, py, gridColor)
+					} else if ok {
+						// 半透明的像素显示在背景图案上面
+						bg := bgPattern(px, py)
+						r, g, b, a := c.RGBA()
+						over := func(fg uint32, bg uint8) uint8 { return uint8((fg + uint32(bg)*0x101*(0xFFFF-a)/0xFFFF) >> 8) }
+						img.SetRGBA(px, py, color.RGBA{R: over(r, bg.R), G: over(g, bg.G), B: over(b, bg.B), A: 0xFF})
+					} else {
+						img.SetRGBA(px, py, color.RGBA{R: 0x30, G: 0x30, B: 0x30, A: 0xFF})
+					}
+				}
+			}
+		}
+	}
+	for ii := 0; ii <= size; ii++ {
+		img.SetRGBA(ii, size, gridColor)
+		img.SetRGBA(size, ii, gridColor)
+	}
+	// 标出中间的像素
+	center := loupeRadius * loupeScale
+	for ii := 0; ii <= loupeScale; ii++ {
+		for _, p := range []image.Point{{center + ii, center}, {center + ii, center + loupeScale},
+			{center, center + ii}, {center + loupeScale, center + ii}} {
+			img.SetRGBA(p.X, p.Y, Red)
+		}
+	}
+	vp.cursorEyedropper.Image = img
+	vp.cursorEyedropper.Refresh()
+
+	if c, ok := vp.pixelAt(pt); ok {
+		vp.fs.status.SetText(fmt.Sprintf("(%d, %d)  %s  %s  %s  %s", pt.X, pt.Y,
+			formatColor(c, ColorFormatHEX), formatColor(c, ColorFormatRGB),
+			formatColor(c, ColorFormatHSL), formatColor(c, ColorFormatHSV)))
+	} else {
+		vp.fs.status.SetText("Click on a pixel of the screenshot to pick its color.")
+	}
+}
+
+// newEyedropperCursor 放大镜使用的图片，内容在鼠标移动时更新
+func newEyedropperCursor() *canvas.Image {
+	const size = (2*loupeRadius+1)*loupeScale + 1
+	img := canvas.NewImageFromImage(image.NewRGBA(image.Rect(0, 0, size, size)))
+	img.ScaleMode = canvas.ImageScalePixels
+	img.SetMinSize(fyne.NewSize(size, size))
+	return img
+}
+
+// PickColor 显示放大镜，单击时复制像素的颜色，并可以用作绘图颜色或者背景颜色
+func (gs *FireShotGO) PickColor() {
+	gs.viewPort.SetOp(PickColor)
+}
+
+// colorFormat 复制到剪贴板时使用的颜色格式
+func (gs *FireShotGO) colorFormat() string {
+	return gs.App.Preferences().StringWithFallback(ColorFormatPreference, ColorFormatHEX)
+}
+
+// copyColor 将颜色使用 format 格式复制到剪贴板
+func (gs *FireShotGO) copyColor(c color.Color, format string) {
+	text := formatColor(c, format)
+	if err := clipboard.CopyText(text); err != nil {
+		glog.Errorf("Failed to copy color to clipboard: %s", err)
+		gs.status.SetText(fmt.Sprintf("Failed to copy color to clipboard: %s", err))
+		return
+	}
+	gs.status.SetText(fmt.Sprintf("Color %s copied to clipboard.", text))
+}
+
+// pickColorAt 复制截图中 pt (相对于裁剪区域)的颜色，并显示所有格式的值
+func (vp *ViewPort) pickColorAt(pt image.Point) {
+	c, ok := vp.pixelAt(pt)
+	if !ok {
+		vp.fs.status.SetText("Nothing to pick outside of the screenshot.")
+		return
+	}
+	vp.fs.copyColor(c, vp.fs.colorFormat())
+	vp.fs.showColor(c, pt)
+}
+
+// showColor 显示颜色的所有格式，可以复制任意一种格式、修改默认复制的格式，
+// 或者将颜色设为绘图颜色、背景颜色
+func (gs *FireShotGO) showColor(c color.Color, pt image.Point) {
+	sample := canvas.NewRectangle(c)
+	sample.SetMinSize(fyne.NewSize(48, 48))
+	sample.StrokeColor = Yellow
+	sample.StrokeWidth = 1
+
+	rows := container.NewVBox()
+	for _, format := range colorFormats {
+		format := format
+		rows.Add(container.NewBorder(nil, nil, widget.NewLabel(format),
+			widget.NewButton("复制", func() { gs.copyColor(c, format) }),
+			widget.NewLabel(formatColor(c, format))))
+	}
+	formatRadio := widget.NewRadioGroup(colorFormats, func(format string) {
+		if format != "" {
+			gs.App.Preferences().SetString(ColorFormatPreference, format)
+		}
+	})
+	formatRadio.Horizontal = true
+	formatRadio.SetSelected(gs.colorFormat())
+
+	setDrawing := widget.NewButton("设为绘图颜色", func() {
+		gs.viewPort.DrawingColor = c
+		gs.SetColorPreference(DrawingColorPreference, c)
+		gs.colorSample.FillColor = c
+		gs.colorSample.Refresh()
+		gs.status.SetText(fmt.Sprintf("Drawing color set to %s.", formatColor(c, ColorFormatHEX)))
+	})
+	setBackground := widget.NewButton("设为背景颜色", func() {
+		gs.viewPort.BackgroundColor = c
+		gs.SetColorPreference(BackgroundColorPreference, c)
+		gs.status.SetText(fmt.Sprintf("Background color set to %s.", formatColor(c, ColorFormatHEX)))
+	})
+
+	content := container.NewVBox(
+		container.NewHBox(sample, widget.NewLabel(fmt.Sprintf("像素 (%d, %d)", pt.X, pt.Y))),
+		rows,
+		container.NewHBox(widget.NewLabel("单击时复制:"), formatRadio),
+		container.NewHBox(setDrawing, setBackground),
+	)
+	dialog.ShowCustom("取色", "关闭", content, gs.Win)
+}
@@ -0,0 +1,199 @@
+package screenshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/validation"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"gitee.com/andrewgithub/FireShotGo/encoder"
+	"github.com/golang/glog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const EncoderOptionsPreference = "EncoderOptions"
+
+// encoderOptions 读取保存的图片格式和编码参数，保存、复制和上传都使用同样的参数
+func (gs *FireShotGO) encoderOptions() encoder.Options {
+	opts := encoder.DefaultOptions()
+	if saved := gs.App.Preferences().String(EncoderOptionsPreference); saved != "" {
+		if err := json.Unmarshal([]byte(saved), &opts); err != nil {
+			glog.Warningf("Ignoring invalid encoder options %q: %s", saved, err)
+			opts = encoder.DefaultOptions()
+		}
+	}
+	return opts
+}
+
+func (gs *FireShotGO) setEncoderOptions(opts encoder.Options) {
+	content, err := json.Marshal(opts)
+	if err != nil {
+		glog.Errorf("Failed to save encoder options: %s", err)
+		return
+	}
+	gs.App.Preferences().SetString(EncoderOptionsPreference, string(content))
+}
+
+// reportSizes 返回编码优化前后的大小，例如 " (2.31 MB → 640.2 KB, -73%)"，没有优化时返回空字符串
+func reportSizes(report encoder.Report) string {
+	if report.After >= report.Before {
+		return ""
+	}
+	return fmt.Sprintf(" (%s → %s, -%.0f%%)", formatSize(report.Before), formatSize(report.After), report.Saved()*100)
+}
+
+// formatSize 将字节数格式化成便于阅读的大小
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.2f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
+
+// ImageFormatForm 设置保存、复制和上传使用的图片格式以及编码参数，并预览编码之后的文件大小
+func (gs *FireShotGO) ImageFormatForm() {
+	opts := gs.encoderOptions()
+	img := gs.ExportImage()
+
+	formatNames := make([]string, len(encoder.Formats))
+	for ii, f := range encoder.Formats {
+		formatNames[ii] = string(f)
+	}
+	formatSelect := widget.NewSelect(formatNames, nil)
+	formatSelect.SetSelected(string(opts.Format))
+
+	compressionSelect := widget.NewSelect(encoder.PNGCompressionNames, nil)
+	compressionSelect.SetSelected(opts.PNGCompression.String())
+
+	optimizeCheck := widget.NewCheck("无损优化: 颜色少时转换成调色板，并选择最好的压缩", nil)
+	optimizeCheck.SetChecked(opts.PNGOptimize)
+	quantizeCheck := widget.NewCheck("有损量化", nil)
+	quantizeCheck.SetChecked(opts.PNGQuantize)
+	quantizeColorsEntry := &widget.Entry{Validator: validation.NewRegexp(`^\d+$`, "Must be a number")}
+	quantizeColorsEntry.SetText(strconv.Itoa(opts.PNGQuantizeColors))
+	ditherCheck := widget.NewCheck("抖动", nil)
+	ditherCheck.SetChecked(opts.PNGDither)
+
+	qualityLabel := widget.NewLabel(strconv.Itoa(opts.JPEGQuality))
+	qualitySlider := widget.NewSlider(1, 100)
+	qualitySlider.SetValue(float64(opts.JPEGQuality))
+
+	colorsEntry := &widget.Entry{Validator: validation.NewRegexp(`^\d+$`, "Must be a number")}
+	colorsEntry.SetText(strconv.Itoa(opts.GIFColors))
+
+	deflateCheck := widget.NewCheck("Deflate 压缩", nil)
+	deflateCheck.SetChecked(opts.TIFFDeflate)
+
+	// readOptions 从界面读取参数
+	readOptions := func() (encoder.Options, error) {
+		o := opts
+		o.Format = encoder.Format(formatSelect.Selected)
+		o.PNGCompression = encoder.ParsePNGCompression(compressionSelect.Selected)
+		o.PNGOptimize = optimizeCheck.Checked
+		o.PNGQuantize = quantizeCheck.Checked
+		o.PNGDither = ditherCheck.Checked
+		quantizeColors, err := strconv.Atoi(quantizeColorsEntry.Text)
+		if err != nil || quantizeColors < 2 || quantizeColors > 256 {
+			return o, fmt.Errorf("quantization colors must be between 2 and 256, got %q", quantizeColorsEntry.Text)
+		}
+		o.PNGQuantizeColors = quantizeColors
+		o.JPEGQuality = int(qualitySlider.Value)
+		colors, err := strconv.Atoi(colorsEntry.Text)
+		if err != nil || colors < 2 || colors > 256 {
+			return o, fmt.Errorf("GIF colors must be between 2 and 256, got %q", colorsEntry.Text)
+		}
+		o.GIFColors = colors
+		o.TIFFDeflate = deflateCheck.Checked
+		return o, nil
+	}
+
+	// 文件大小预览: 编码可能比较慢，在后台进行，参数连续变化时只保留最后一次的结果
+	sizeLabel := widget.NewLabel("")
+	var (
+		previewMu         sync.Mutex
+		previewGeneration int
+	)
+	updatePreview := func() {
+		o, err := readOptions()
+		if err != nil {
+			sizeLabel.SetText(err.Error())
+			return
+		}
+		previewMu.Lock()
+		previewGeneration++
+		generation := previewGeneration
+		previewMu.Unlock()
+		sizeLabel.SetText("计算中 ...")
+		stale := func() bool {
+			previewMu.Lock()
+			defer previewMu.Unlock()
+			return generation != previewGeneration
+		}
+		go func() {
+			// 等参数稳定之后再编码，拖动滑块时不会同时进行很多次编码
+			time.Sleep(300 * time.Millisecond)
+			if stale() {
+				return
+			}
+			start := time.Now()
+			report, err := encoder.EncodedSize(img, o)
+			if stale() {
+				return
+			}
+			if err != nil {
+				sizeLabel.SetText(fmt.Sprintf("Failed to encode: %s", err))
+				return
+			}
+			sizeLabel.SetText(fmt.Sprintf("%s%s (%dx%d, 编码用时 %s)", formatSize(report.After), reportSizes(report),
+				img.Bounds().Dx(), img.Bounds().Dy(), time.Since(start).Round(time.Millisecond)))
+		}()
+	}
+	formatSelect.OnChanged = func(string) { updatePreview() }
+	compressionSelect.OnChanged = func(string) { updatePreview() }
+	optimizeCheck.OnChanged = func(bool) { updatePreview() }
+	quantizeCheck.OnChanged = func(bool) { updatePreview() }
+	quantizeColorsEntry.OnChanged = func(string) { updatePreview() }
+	ditherCheck.OnChanged = func(bool) { updatePreview() }
+	qualitySlider.OnChanged = func(v float64) {
+		qualityLabel.SetText(strconv.Itoa(int(v)))
+		updatePreview()
+	}
+	colorsEntry.OnChanged = func(string) { updatePreview() }
+	deflateCheck.OnChanged = func(bool) { updatePreview() }
+	updatePreview()
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("格式", formatSelect),
+		widget.NewFormItem("PNG 压缩", compressionSelect),
+		widget.NewFormItem("PNG 优化", optimizeCheck),
+		widget.NewFormItem("", container.NewBorder(nil, nil, container.NewHBox(quantizeCheck, widget.NewLabel("颜色数")), ditherCheck, quantizeColorsEntry)),
+		widget.NewFormItem("JPEG 质量", container.NewBorder(nil, nil, nil, qualityLabel, qualitySlider)),
+		widget.NewFormItem("GIF 颜色数", colorsEntry),
+		widget.NewFormItem("TIFF", deflateCheck),
+		widget.NewFormItem("文件大小", sizeLabel),
+	}
+	dialog.ShowForm("图片格式", "确认", "取消", items,
+		func(ok bool) {
+			previewMu.Lock()
+			previewGeneration++ // 忽略还没有完成的预览
+			previewMu.Unlock()
+			if !ok {
+				return
+			}
+			o, err := readOptions()
+			if err != nil {
+				glog.Errorf("Invalid encoder options: %s", err)
+				gs.status.SetText(err.Error())
+				return
+			}
+			gs.setEncoderOptions(o)
+			gs.status.SetText(fmt.Sprintf("Saving, copying and sharing as %s.", o.Format))
+		}, gs.Win)
+}
@@ -0,0 +1,298 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+)
+
+const (
+	// ShowGridPreference 是否显示网格
+	ShowGridPreference = "ShowGrid"
+	// GridSpacingPreference 主网格线的间隔(像素)，也是对齐网格的间隔
+	GridSpacingPreference = "GridSpacing"
+	// ShowGuidesPreference 是否显示标尺和参考线
+	ShowGuidesPreference = "ShowGuides"
+)
+
+// Guide 参考线，Pos 是原始截图的坐标: 垂直的参考线是 X，水平的参考线是 Y
+type Guide struct {
+	Vertical bool
+	Pos      int
+}
+
+const (
+	// pixelGridLog2Zoom 放大到 2^pixelGridLog2Zoom 倍以上时显示像素网格
+	pixelGridLog2Zoom = 3
+	// minGridSpacing 主网格线在视图中的最小间隔，更密的时候不显示
+	minGridSpacing = 8
+	// rulerSize 标尺的宽度(视图像素)
+	rulerSize = 18
+	// guideHitDistance 鼠标离参考线这个距离(视图像素)以内时可以拖动参考线
+	guideHitDistance = 4
+)
+
+var (
+	pixelGridColor  = color.NRGBA{R: 0x80, G: 0x80, B: 0x80, A: 0x60}
+	gridColor       = color.NRGBA{R: 0x40, G: 0x80, B: 0xFF, A: 0x80}
+	guideColor      = color.NRGBA{R: 0x00, G: 0xBC, B: 0xD4, A: 0xFF}
+	rulerBackground = color.NRGBA{R: 0xF0, G: 0xF0, B: 0xF0, A: 0xFF}
+	rulerTickColor  = color.NRGBA{R: 0x40, G: 0x40, B: 0x40, A: 0xFF}
+)
+
+// blendPixel 将 c 按照它的 alpha 叠加到 img 的 (x, y) 上，超出 img 的部分忽略
+func blendPixel(img *image.RGBA, x, y int, c color.NRGBA) {
+	if !(image.Point{X: x, Y: y}).In(img.Rect) {
+		return
+	}
+	pos := img.PixOffset(x, y)
+	a := uint32(c.A)
+	for ii, v := range []uint8{c.R, c.G, c.B} {
+		img.Pix[pos+ii] = uint8((uint32(v)*a + uint32(img.Pix[pos+ii])*(0xFF-a)) / 0xFF)
+	}
+	img.Pix[pos+3] = 0xFF
+}
+
+// cacheX, cacheY 截图坐标(相对于裁剪区域) v 在视图中的位置
+func (vp *ViewPort) cacheX(v float64) int {
+	return int(math.Round((v - float64(vp.viewX)) / vp.zoom()))
+}
+
+func (vp *ViewPort) cacheY(v float64) int {
+	return int(math.Round((v - float64(vp.viewY)) / vp.zoom()))
+}
+
+// renderOverlays 在视图上绘制网格、参考线和标尺，它们不属于截图，不会被保存
+func (vp *ViewPort) renderOverlays() {
+	if vp.ShowGrid {
+		vp.renderGrid()
+	}
+	if vp.ShowGuides {
+		vp.renderGuides()
+	}
+	vp.renderCropSelection()
+	if vp.ShowGuides {
+		vp.renderRulers()
+	}
+}
+
+// renderGrid 放大时在像素之间画出像素网格，主网格线画在坐标为 GridSpacing 倍数的位置(和对齐的位置相同)
+func (vp *ViewPort) renderGrid() {
+	zoom := vp.zoom()
+	imgW, imgH := vp.fs.CropRect.Dx(), vp.fs.CropRect.Dy()
+	// 截图在视图中的范围
+	x0, x1 := vp.cacheX(0), vp.cacheX(float64(imgW))
+	y0, y1 := vp.cacheY(0), vp.cacheY(float64(imgH))
+	vLine := func(x int, c color.NRGBA) {
+		for y := y0; y < y1; y++ {
+			blendPixel(vp.cache, x, y, c)
+		}
+	}
+	hLine := func(y int, c color.NRGBA) {
+		for x := x0; x < x1; x++ {
+			blendPixel(vp.cache, x, y, c)
+		}
+	}
+	firstX, lastX := maxInt(vp.viewX, 0), minInt(vp.viewX+vp.viewW+1, imgW)
+	firstY, lastY := maxInt(vp.viewY, 0), minInt(vp.viewY+vp.viewH+1, imgH)
+
+	if vp.Log2Zoom >= pixelGridLog2Zoom {
+		// 像素 i 显示在视图中 round(x*zoom) == i 的位置，所以像素的边界是 i-0.5
+		for i := firstX; i <= lastX; i++ {
+			vLine(int(math.Ceil((float64(i)-0.5-float64(vp.viewX))/zoom)), pixelGridColor)
+		}
+		for i := firstY; i <= lastY; i++ {
+			hLine(int(math.Ceil((float64(i)-0.5-float64(vp.viewY))/zoom)), pixelGridColor)
+		}
+	}
+	spacing := vp.GridSpacing
+	if spacing > 1 && float64(spacing)/zoom >= minGridSpacing {
+		for i := (firstX + spacing - 1) / spacing * spacing; i <= lastX; i += spacing {
+			vLine(vp.cacheX(float64(i)), gridColor)
+		}
+		for i := (firstY + spacing - 1) / spacing * spacing; i <= lastY; i += spacing {
+			hLine(vp.cacheY(float64(i)), gridColor)
+		}
+	}
+}
+
+// renderGuides 画出所有的参考线，包括正在拖动的参考线
+func (vp *ViewPort) renderGuides() {
+	w, h := wh(vp.cache)
+	for _, g := range vp.Guides {
+		if g.Vertical {
+			x := vp.cacheX(float64(g.Pos - vp.fs.CropRect.Min.X))
+			for y := 0; y < h; y++ {
+				blendPixel(vp.cache, x, y, guideColor)
+			}
+		} else {
+			y := vp.cacheY(float64(g.Pos - vp.fs.CropRect.Min.Y))
+			for x := 0; x < w; x++ {
+				blendPixel(vp.cache, x, y, guideColor)
+			}
+		}
+	}
+}
+
+// niceStep 返回不小于 min 的 1、2、5 乘以 10 的幂
+func niceStep(min float64) int {
+	for step := 1; ; step *= 10 {
+		for _, m := range []int{1, 2, 5} {
+			if float64(step*m) >= min {
+				return step * m
+			}
+		}
+	}
+}
+
+// renderRulers 在视图的上方和左侧画出标尺，刻度是相对于裁剪区域的坐标
+func (vp *ViewPort) renderRulers() {
+	w, h := wh(vp.cache)
+	zoom := vp.zoom()
+	for y := 0; y < h; y++ {
+		width := rulerSize
+		if y < rulerSize {
+			width = w
+		}
+		for x := 0; x < width; x++ {
+			blendPixel(vp.cache, x, y, rulerBackground)
+		}
+	}
+	for ii := 0; ii < w || ii < h; ii++ {
+		blendPixel(vp.cache, ii, rulerSize-1, rulerTickColor)
+		blendPixel(vp.cache, rulerSize-1, ii, rulerTickColor)
+	}
+
+	drawer := &font.Drawer{Dst: vp.cache, Src: image.NewUniform(rulerTickColor), Face: basicfont.Face7x13}
+	const charHeight = 13
+
+	// 上方的标尺
+	minor, major := niceStep(6*zoom), niceStep(60*zoom)
+	first := int(math.Floor(float64(vp.viewX)/float64(minor))) * minor
+	for v := first; v <= vp.viewX+vp.viewW; v += minor {
+		x := vp.cacheX(float64(v))
+		if x < rulerSize {
+			continue
+		}
+		length := rulerSize / 3
+		if v%major == 0 {
+			length = rulerSize
+			drawer.Dot = fixed.P(x+2, 11)
+			drawer.DrawString(strconv.Itoa(v))
+		}
+		for y := rulerSize - length; y < rulerSize; y++ {
+			blendPixel(vp.cache, x, y, rulerTickColor)
+		}
+	}
+
+	// 左侧的标尺，数字竖着排列
+	minor, major = niceStep(6*zoom), niceStep(80*zoom)
+	first = int(math.Floor(float64(vp.viewY)/float64(minor))) * minor
+	for v := first; v <= vp.viewY+vp.viewH; v += minor {
+		y := vp.cacheY(float64(v))
+		if y < rulerSize {
+			continue
+		}
+		length := rulerSize / 3
+		if v%major == 0 {
+			length = rulerSize
+			for ii, ch := range strconv.Itoa(v) {
+				drawer.Dot = fixed.P(4, y+charHeight*(ii+1))
+				drawer.DrawString(string(ch))
+			}
+		}
+		for x := rulerSize - length; x < rulerSize; x++ {
+			blendPixel(vp.cache, x, y, rulerTickColor)
+		}
+	}
+}
+
+// startGuideDrag 在标尺上开始拖动时创建新的参考线(上方的标尺是水平的参考线，左侧是垂直的参考线)，
+// 没有选择工具时也可以拖动已有的参考线。返回 true 表示拖动的是参考线。
+func (vp *ViewPort) startGuideDrag(pos fyne.Position) bool {
+	if !vp.ShowGuides {
+		return false
+	}
+	px, py := vp.PosToPixel(pos)
+	switch {
+	case px < rulerSize && py < rulerSize:
+		return false
+	case py < rulerSize:
+		vp.Guides = append(vp.Guides, Guide{Vertical: false})
+		vp.movingGuide = &vp.Guides[len(vp.Guides)-1]
+	case px < rulerSize:
+		vp.Guides = append(vp.Guides, Guide{Vertical: true})
+		vp.movingGuide = &vp.Guides[len(vp.Guides)-1]
+	case vp.currentOperation == NoOp:
+		for ii := range vp.Guides {
+			g := &vp.Guides[ii]
+			if g.Vertical && abs(vp.cacheX(float64(g.Pos-vp.fs.CropRect.Min.X))-px) <= guideHitDistance ||
+				!g.Vertical && abs(vp.cacheY(float64(g.Pos-vp.fs.CropRect.Min.Y))-py) <= guideHitDistance {
+				vp.movingGuide = g
+				break
+			}
+		}
+	}
+	if vp.movingGuide == nil {
+		return false
+	}
+	vp.dragGuide(pos)
+	return true
+}
+
+// dragGuide 将正在拖动的参考线移动到鼠标的位置
+func (vp *ViewPort) dragGuide(pos fyne.Position) {
+	g := vp.movingGuide
+	x, y := vp.screenshotPos(pos)
+	px, py := vp.PosToPixel(pos)
+	if g.Vertical {
+		g.Pos = x + vp.fs.CropRect.Min.X
+		vp.movingGuideOnRuler = px < rulerSize
+		vp.fs.status.SetText(fmt.Sprintf("Vertical guide at x=%d, drag it back to the ruler to remove it.", x))
+	} else {
+		g.Pos = y + vp.fs.CropRect.Min.Y
+		vp.movingGuideOnRuler = py < rulerSize
+		vp.fs.status.SetText(fmt.Sprintf("Horizontal guide at y=%d, drag it back to the ruler to remove it.", y))
+	}
+	vp.Refresh()
+}
+
+// endGuideDrag 结束拖动参考线，在标尺上放开时删除参考线
+func (vp *ViewPort) endGuideDrag() {
+	if vp.movingGuideOnRuler {
+		for ii := range vp.Guides {
+			if &vp.Guides[ii] == vp.movingGuide {
+				vp.Guides = append(vp.Guides[:ii], vp.Guides[ii+1:]...)
+				break
+			}
+		}
+		vp.fs.status.SetText("Guide removed.")
+	}
+	vp.movingGuide = nil
+	vp.movingGuideOnRuler = false
+	vp.Refresh()
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func clampInt(v, min, max int) int {
+	return minInt(maxInt(v, min), max)
+}
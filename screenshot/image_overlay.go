@@ -0,0 +1,304 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/validation"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+	"gitee.com/andrewgithub/FireShotGo/clipboard"
+	"gitee.com/andrewgithub/FireShotGo/filters"
+	"gitee.com/andrewgithub/FireShotGo/resources"
+	"github.com/golang/glog"
+	"github.com/kbinani/screenshot"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"math"
+	"path"
+	"strconv"
+	"time"
+)
+
+// InsertImageFromFile 从文件中选择图片插入到截图中
+func (gs *FireShotGO) InsertImageFromFile() {
+	fileOpen := dialog.NewFileOpen(
+		func(reader fyne.URIReadCloser, err error) {
+			if err != nil {
+				glog.Errorf("Failed to open image: %s", err)
+				gs.status.SetText(fmt.Sprintf("Failed to open image: %s", err))
+				return
+			}
+			if reader == nil {
+				gs.status.SetText("Insert image cancelled.")
+				return
+			}
+			defer func() { _ = reader.Close() }()
+			gs.App.Preferences().SetString(DefaultPathPreference, path.Dir(reader.URI().Path()))
+
+			img, _, err := image.Decode(reader)
+			if err != nil {
+				glog.Errorf("Failed to decode image %q: %s", reader.URI(), err)
+				gs.status.SetText(fmt.Sprintf("Failed to decode image %q: %s", reader.URI(), err))
+				return
+			}
+			gs.viewPort.insertImageOverlay(img)
+		}, gs.Win)
+	fileOpen.SetFilter(storage.NewExtensionFileFilter([]string{".png", ".jpg", ".jpeg", ".gif"}))
+	if defaultPath := gs.App.Preferences().String(DefaultPathPreference); defaultPath != "" {
+		lister, err := storage.ListerForURI(storage.NewFileURI(defaultPath))
+		if err == nil {
+			fileOpen.SetLocation(lister)
+		}
+	}
+	size := gs.Win.Canvas().Size()
+	size.Width *= 0.90
+	size.Height *= 0.90
+	fileOpen.Resize(size)
+	fileOpen.Show()
+}
+
+// InsertImageFromClipboard 将剪贴板中的图片插入到截图中
+func (gs *FireShotGO) InsertImageFromClipboard() {
+	img, err := clipboard.PasteImage()
+	if err != nil {
+		glog.Errorf("Failed to paste image from clipboard: %s", err)
+		gs.status.SetText(fmt.Sprintf("Failed to paste image from clipboard: %s", err))
+		return
+	}
+	gs.viewPort.insertImageOverlay(img)
+}
+
+// InsertCaptureForm 插入另一张截图: 可以是本次运行中之前的截图，也可以重新截取一个屏幕
+func (gs *FireShotGO) InsertCaptureForm() {
+	const newCapture = "新截图"
+	options := []string{newCapture}
+	for ii := len(gs.previousCaptures) - 1; ii >= 0; ii-- {
+		options = append(options, gs.previousCaptures[ii].name)
+	}
+	sourceSelect := widget.NewSelect(options, nil)
+	sourceSelect.SetSelected(newCapture)
+
+	screenEntry := &widget.Entry{Validator: validation.NewRegexp(`^\d+$`, "Must be a number")}
+	screenEntry.SetText(strconv.Itoa(gs.displayIndex + 1))
+	delayEntry := &widget.Entry{Validator: validation.NewRegexp(`^\d+$`, "Must be a number")}
+	delayEntry.SetText("3")
+
+	dialog.ShowForm("插入截图", "确认", "取消",
+		[]*widget.FormItem{
+			widget.NewFormItem("来源", sourceSelect),
+			widget.NewFormItem("屏幕序号", screenEntry),
+			widget.NewFormItem("截屏延时 (s)", delayEntry),
+		},
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			if sourceSelect.Selected != newCapture {
+				for _, c := range gs.previousCaptures {
+					if c.name == sourceSelect.Selected {
+						gs.viewPort.insertImageOverlay(c.img)
+						return
+					}
+				}
+				return
+			}
+			screenNo, err := strconv.Atoi(screenEntry.Text)
+			if err != nil || screenNo < 1 || screenNo > screenshot.NumActiveDisplays() {
+				gs.status.SetText(fmt.Sprintf("Invalid screen number %q", screenEntry.Text))
+				return
+			}
+			secs, err := strconv.Atoi(delayEntry.Text)
+			if err != nil {
+				gs.status.SetText(fmt.Sprintf("Can't parse seconds in delay from %q: %s", delayEntry.Text, err))
+				return
+			}
+			gs.captureForOverlay(screenNo-1, secs)
+		}, gs.Win)
+}
+
+// captureForOverlay 隐藏编辑窗口，延时截取屏幕之后把截图插入到当前截图中
+func (gs *FireShotGO) captureForOverlay(displayIndex, seconds int) {
+	gs.Win.Hide()
+	go func() {
+		// 至少等待一会儿，让窗口有时间隐藏
+		time.Sleep(time.Duration(seconds)*time.Second + 300*time.Millisecond)
+		img, err := screenshot.CaptureRect(screenshot.GetDisplayBounds(displayIndex))
+		gs.Win.Show()
+		if err != nil {
+			glog.Errorf("Failed to capture screen %d: %s", displayIndex+1, err)
+			gs.status.SetText(fmt.Sprintf("Failed to capture screen %d: %s", displayIndex+1, err))
+			return
+		}
+		gs.viewPort.insertImageOverlay(img)
+	}()
+}
+
+// insertImageOverlay 将图片插入到当前可见区域的中心，太大的图片缩小到可见区域的一半以内
+func (vp *ViewPort) insertImageOverlay(img image.Image) {
+	size := img.Bounds().Size()
+	if size.X == 0 || size.Y == 0 {
+		vp.fs.status.SetText("Image is empty.")
+		return
+	}
+	scale := math.Min(1, math.Min(float64(vp.viewW)/2/float64(size.X), float64(vp.viewH)/2/float64(size.Y)))
+	w, h := int(float64(size.X)*scale+0.5), int(float64(size.Y)*scale+0.5)
+	center := image.Point{X: vp.viewX + vp.viewW/2, Y: vp.viewY + vp.viewH/2}.Add(vp.fs.CropRect.Min)
+	rect := image.Rect(center.X-w/2, center.Y-h/2, center.X-w/2+w, center.Y-h/2+h)
+
+	overlay := filters.NewImageOverlay(img, rect)
+	vp.fs.Filters = append(vp.fs.Filters, overlay)
+	vp.fs.ApplyFilters(true)
+	vp.editImageOverlay(overlay, true)
+}
+
+// overlayAt 返回位于 p 的最上层的插入图片
+func (vp *ViewPort) overlayAt(p image.Point) *filters.ImageOverlay {
+	for ii := len(vp.fs.Filters) - 1; ii >= 0; ii-- {
+		if overlay, ok := vp.fs.Filters[ii].(*filters.ImageOverlay); ok && p.In(overlay.Rect) {
+			return overlay
+		}
+	}
+	return nil
+}
+
+// overlayMove 拖动插入的图片之前的位置，用于撤销。
+// filters 是拖动时标注的个数，之后新加的标注先撤销。
+type overlayMove struct {
+	overlay *filters.ImageOverlay
+	from    image.Point
+	filters int
+}
+
+// undoOverlayMove 最后一次修改是拖动插入的图片时将图片移回原来的位置，否则返回 false
+func (gs *FireShotGO) undoOverlayMove() bool {
+	for len(gs.overlayMoves) > 0 {
+		move := gs.overlayMoves[len(gs.overlayMoves)-1]
+		if move.filters < len(gs.Filters) {
+			// 拖动之后又添加了标注
+			return false
+		}
+		gs.overlayMoves = gs.overlayMoves[:len(gs.overlayMoves)-1]
+		for _, filter := range gs.Filters {
+			if filter == ImageFilter(move.overlay) {
+				move.overlay.MoveTo(move.from)
+				gs.ApplyFilters(true)
+				gs.status.SetText("Image moved back.")
+				return true
+			}
+		}
+		// 图片已经被删除，继续查找之前的拖动
+	}
+	return false
+}
+
+// dragOverlay 拖动插入的图片
+func (vp *ViewPort) dragOverlay(toPos fyne.Position) {
+	startX, startY := vp.screenshotPos(vp.dragStart)
+	toX, toY := vp.screenshotPos(toPos)
+	vp.movingOverlay.MoveTo(vp.movingOverlayFrom.Add(image.Point{X: toX - startX, Y: toY - startY}))
+	vp.fs.ApplyFilters(false)
+	vp.renderCache()
+	vp.Refresh()
+}
+
+// editImageOverlay 编辑插入图片的位置、缩放、边框以及阴影，修改会实时显示。
+// 确认之后保留修改，取消之后恢复原来的设置(新插入的图片会被删除)。
+func (vp *ViewPort) editImageOverlay(overlay *filters.ImageOverlay, isNew bool) {
+	original := *overlay
+	sourceSize := overlay.Source.Bounds().Size()
+
+	intEntry := func(v int) *widget.Entry {
+		e := &widget.Entry{Validator: validation.NewRegexp(`^-?\d+$`, "Must be an integer")}
+		e.SetText(strconv.Itoa(v))
+		return e
+	}
+	xEntry := intEntry(overlay.Rect.Min.X)
+	yEntry := intEntry(overlay.Rect.Min.Y)
+	scaleEntry := &widget.Entry{Validator: validation.NewRegexp(`^\d+(\.\d*)?$`, "Must contain a number")}
+	scaleEntry.SetText(fmt.Sprintf("%.4g", 100*float64(overlay.Rect.Dx())/float64(sourceSize.X)))
+	borderEntry := intEntry(overlay.BorderWidth)
+	blurEntry := intEntry(overlay.ShadowBlur)
+	offsetXEntry := intEntry(overlay.ShadowOffset.X)
+	offsetYEntry := intEntry(overlay.ShadowOffset.Y)
+	borderColor, shadowColor := overlay.BorderColor, overlay.ShadowColor
+
+	preview := func() {
+		x, errX := strconv.Atoi(xEntry.Text)
+		y, errY := strconv.Atoi(yEntry.Text)
+		scale, errS := strconv.ParseFloat(scaleEntry.Text, 64)
+		border, errB := strconv.Atoi(borderEntry.Text)
+		blur, errBl := strconv.Atoi(blurEntry.Text)
+		dx, errDx := strconv.Atoi(offsetXEntry.Text)
+		dy, errDy := strconv.Atoi(offsetYEntry.Text)
+		for _, err := range []error{errX, errY, errS, errB, errBl, errDx, errDy} {
+			if err != nil {
+				return
+			}
+		}
+		w := int(float64(sourceSize.X)*scale/100 + 0.5)
+		h := int(float64(sourceSize.Y)*scale/100 + 0.5)
+		if w < 1 || h < 1 {
+			return
+		}
+		overlay.SetRect(image.Rect(x, y, x+w, y+h))
+		overlay.SetDecoration(border, borderColor, blur, image.Point{X: dx, Y: dy}, shadowColor)
+		vp.fs.ApplyFilters(true)
+	}
+	for _, e := range []*widget.Entry{xEntry, yEntry, scaleEntry, borderEntry, blurEntry, offsetXEntry, offsetYEntry} {
+		e.OnChanged = func(string) { preview() }
+	}
+
+	colorRow := func(c *color.Color, title string) *fyne.Container {
+		sample := canvas.NewRectangle(*c)
+		sample.SetMinSize(fyne.NewSize(100, 20))
+		set := func(nc color.Color) {
+			*c = nc
+			sample.FillColor = nc
+			sample.Refresh()
+			preview()
+		}
+		return container.NewHBox(
+			widget.NewButtonWithIcon("", resources.ColorWheel, func() {
+				dialog.NewColorPicker("Pick a Color", title, set, vp.fs.Win).Show()
+			}),
+			widget.NewButtonWithIcon("", resources.Reset, func() { set(Transparent) }),
+			sample,
+		)
+	}
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("位置", container.NewGridWithColumns(2, xEntry, yEntry)),
+		widget.NewFormItem("缩放 (%)", scaleEntry),
+		widget.NewFormItem("边框宽度", borderEntry),
+		widget.NewFormItem("边框颜色", colorRow(&borderColor, "Select border color")),
+		widget.NewFormItem("阴影模糊", blurEntry),
+		widget.NewFormItem("阴影偏移", container.NewGridWithColumns(2, offsetXEntry, offsetYEntry)),
+		widget.NewFormItem("阴影颜色", colorRow(&shadowColor, "Select shadow color")),
+	}
+	title := "编辑图片"
+	if isNew {
+		title = "插入图片"
+	}
+	form := dialog.NewForm(title, "确认", "取消", items,
+		func(ok bool) {
+			if !ok {
+				if isNew {
+					vp.removeFilter(overlay)
+				} else {
+					*overlay = original
+				}
+				vp.fs.ApplyFilters(true)
+				vp.fs.status.SetText("Image edition cancelled.")
+				return
+			}
+			preview()
+			vp.fs.status.SetText("Image inserted, drag to move, double-click to edit, use Control+Z to undo.")
+		}, vp.fs.Win)
+	form.Resize(fyne.NewSize(400, 300))
+	form.Show()
+}
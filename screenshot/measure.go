@@ -0,0 +1,161 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"gitee.com/andrewgithub/FireShotGo/filters"
+	"github.com/golang/glog"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+)
+
+// SnapToEdgesPreference 测量时是否将两端吸附到附近的边缘
+const SnapToEdgesPreference = "SnapToEdges"
+
+// SnapRadiusPreference 吸附边缘时查找的范围(像素)
+const SnapRadiusPreference = "SnapRadius"
+
+// minEdgeContrast 相邻像素的亮度差超过这个值才认为是边缘，亮度范围 [0, 255]
+const minEdgeContrast = 24
+
+// Measure 测量: 拖动时显示两点之间的宽、高、距离和角度，结束之后不保留标注
+func (gs *FireShotGO) Measure() {
+	gs.viewPort.SetOp(Measure)
+}
+
+// DrawDimension 拖动绘制尺寸标注线，中间标出长度
+func (gs *FireShotGO) DrawDimension() {
+	gs.viewPort.SetOp(DrawDimension)
+}
+
+func (gs *FireShotGO) snapRadius() int {
+	if !gs.App.Preferences().BoolWithFallback(SnapToEdgesPreference, true) {
+		return 0
+	}
+	return gs.App.Preferences().IntWithFallback(SnapRadiusPreference, 6)
+}
+
+// luminance 像素的亮度 [0, 255]
+func luminance(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 0x101
+}
+
+// snapToEdge 分别在水平和垂直方向查找 p 附近 radius 以内对比最强的像素边界，
+// 找到的话将 p 移动到边界上。p 是像素的边界(左上角)，使用原始截图的坐标。
+func snapToEdge(img image.Image, p image.Point, radius int) image.Point {
+	if radius <= 0 {
+		return p
+	}
+	bounds := img.Bounds()
+	// edge 返回 pt 和 pt-step 两个像素之间的亮度差
+	edge := func(pt, step image.Point) float64 {
+		prev := pt.Sub(step)
+		if !pt.In(bounds) || !prev.In(bounds) {
+			return 0
+		}
+		return math.Abs(luminance(img.At(pt.X, pt.Y)) - luminance(img.At(prev.X, prev.Y)))
+	}
+	snap := func(step image.Point) int {
+		best, bestContrast := 0, float64(minEdgeContrast)
+		for d := 0; d <= radius; d++ {
+			for _, offset := range []int{d, -d} {
+				// 边界在鼠标所在的像素上下各取一行，减少噪声
+				pt := p.Add(step.Mul(offset))
+				across := image.Point{X: step.Y, Y: step.X}
+				contrast := math.Max(edge(pt, step), math.Max(edge(pt.Add(across), step), edge(pt.Sub(across), step)))
+				if contrast > bestContrast {
+					best, bestContrast = offset, contrast
+				}
+			}
+		}
+		return best
+	}
+	return image.Point{X: p.X + snap(image.Point{X: 1}), Y: p.Y + snap(image.Point{Y: 1})}
+}
+
+// measurePoint 鼠标位置对应的原始截图坐标，按照设置吸附到边缘。
+// 打开对齐时和其他图形一样先对齐到参考线、网格和其他标注。
+func (vp *ViewPort) measurePoint(pos fyne.Position) image.Point {
+	x, y := vp.screenshotPos(pos)
+	p := image.Point{X: x, Y: y}.Add(vp.fs.CropRect.Min)
+	if vp.Snap {
+		return vp.snapPoint(p, vp.currentDimension)
+	}
+	return snapToEdge(vp.fs.OriginalScreenshot, p, vp.fs.snapRadius())
+}
+
+// startDimension 开始测量或者绘制尺寸标注线
+func (vp *ViewPort) startDimension(pos fyne.Position) {
+	from := vp.measurePoint(pos)
+	vp.currentDimension = filters.NewDimension(from, from, vp.DrawingColor, vp.Thickness, vp.FontSize)
+	vp.currentDimension.SetAntiAlias(vp.AntiAlias)
+	vp.fs.Filters = append(vp.fs.Filters, vp.currentDimension)
+	vp.fs.ApplyFilters(false)
+}
+
+// dragDimension 更新终点，并在状态栏显示测量结果
+func (vp *ViewPort) dragDimension(toPos fyne.Position) {
+	if vp.currentDimension == nil {
+		glog.Errorf("dragDimension(): drag event, but no measurement has been started yet!?")
+		return
+	}
+	from := vp.currentDimension.From
+	to := vp.measurePoint(toPos)
+	vp.currentDimension.SetPoints(from, to)
+	vp.fs.status.SetText(filters.Measure(from, to).String())
+	vp.fs.ApplyFilters(false)
+	vp.renderCache()
+	vp.Refresh()
+}
+
+// endDimension 结束拖动: 测量时移除临时的标注线，只在状态栏保留测量结果
+func (vp *ViewPort) endDimension() {
+	d := vp.currentDimension
+	vp.currentDimension = nil
+	if d == nil {
+		return
+	}
+	m := filters.Measure(d.From, d.To)
+	if vp.currentOperation == Measure {
+		vp.fs.removeFilter(d)
+		vp.fs.status.SetText(m.String())
+		return
+	}
+	vp.fs.ApplyFilters(true)
+	vp.fs.status.SetText(fmt.Sprintf("%s. Dimension added, use Control+Z to undo.", m))
+}
+
+// MeasureForm 测量设置: 是否吸附到边缘以及查找边缘的范围
+func (gs *FireShotGO) MeasureForm() {
+	snapCheck := widget.NewCheck("两端吸附到附近的边缘", nil)
+	snapCheck.SetChecked(gs.App.Preferences().BoolWithFallback(SnapToEdgesPreference, true))
+	radiusEntry := widget.NewEntry()
+	radiusEntry.SetText(strconv.Itoa(gs.App.Preferences().IntWithFallback(SnapRadiusPreference, 6)))
+	radiusEntry.Validator = func(s string) error {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 1 || v > 50 {
+			return fmt.Errorf("范围为 1 到 50 像素")
+		}
+		return nil
+	}
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("吸附", snapCheck),
+		widget.NewFormItem("范围(像素)", radiusEntry),
+	}
+	dialog.ShowForm("测量设置", "确认", "取消", items,
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			gs.App.Preferences().SetBool(SnapToEdgesPreference, snapCheck.Checked)
+			if v, err := strconv.Atoi(radiusEntry.Text); err == nil {
+				gs.App.Preferences().SetInt(SnapRadiusPreference, v)
+			}
+		}, gs.Win)
+}
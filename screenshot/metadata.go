@@ -0,0 +1,276 @@
+package screenshot
+
+import (
+	"bytes"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+	"gitee.com/andrewgithub/FireShotGo/encoder"
+	"gitee.com/andrewgithub/FireShotGo/filters"
+	"github.com/golang/glog"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"path"
+	"strconv"
+	"time"
+)
+
+// Version 写入 PNG "Software" 字段的版本号
+const Version = "v1.0.12"
+
+// EmbedOriginalPreference 保存 PNG 时是否同时保存没有标注的截图，重新打开时标注可以继续编辑。
+// 默认关闭: 没有标注的截图中包含被标注遮住的内容。
+const EmbedOriginalPreference = "EmbedOriginal"
+
+// 保存在 PNG 中的文本字段，前几个是 PNG 规范中的标准字段
+const (
+	metadataCreationTime = "Creation Time"
+	metadataSoftware     = "Software"
+	metadataTitle        = "Title"
+	metadataDisplay      = "FireShotGo:Display"
+	metadataGeometry     = "FireShotGo:Geometry"
+	metadataCrop         = "FireShotGo:Crop"
+	metadataAnnotations  = "FireShotGo:Annotations"
+)
+
+// originalChunkType 保存没有标注的截图(裁剪之后)的私有数据块，内容是 PNG。
+// 小写的第一个字母表示可以忽略，小写的第二个字母表示私有数据块。
+const originalChunkType = "fsOR"
+
+// formatGeometry 使用 X11 的格式 "宽x高+X+Y" 表示矩形
+func formatGeometry(r image.Rectangle) string {
+	return fmt.Sprintf("%dx%d%+d%+d", r.Dx(), r.Dy(), r.Min.X, r.Min.Y)
+}
+
+func parseGeometry(s string) (image.Rectangle, error) {
+	var w, h, x, y int
+	if _, err := fmt.Sscanf(s, "%dx%d%d%d", &w, &h, &x, &y); err != nil {
+		return image.Rectangle{}, fmt.Errorf("invalid geometry %q: %w", s, err)
+	}
+	return image.Rect(x, y, x+w, y+h), nil
+}
+
+// hasRedaction 是否有遮挡块或者马赛克(包括自动打码)
+func (gs *FireShotGO) hasRedaction() bool {
+	for _, filter := range gs.Filters {
+		switch filter.(type) {
+		case *filters.ShieldBlock, *filters.Pixelate:
+			return true
+		}
+	}
+	return false
+}
+
+// metadataChunks 保存 PNG 时写入的附加数据: 截图时间、屏幕、裁剪区域、版本、标题以及标注。
+// 标注无法序列化时只保存截图信息。
+// 有遮挡块或者马赛克时不保存标注和没有标注的截图，否则可以从文件中恢复被遮住的内容，这时 redacted 为 true。
+func (gs *FireShotGO) metadataChunks() (chunks []encoder.Chunk, redacted bool) {
+	chunks = []encoder.Chunk{
+		encoder.TextChunk(metadataCreationTime, gs.ScreenshotTime.Format(time.RFC3339)),
+		encoder.TextChunk(metadataSoftware, "FireShotGo "+Version),
+		encoder.TextChunk(metadataCrop, formatGeometry(gs.CropRect)),
+	}
+	if gs.captureTitle != "" {
+		chunks = append(chunks, encoder.TextChunk(metadataTitle, gs.captureTitle))
+	}
+	if gs.captureDisplay >= 0 && !gs.displayBounds.Empty() {
+		chunks = append(chunks,
+			encoder.TextChunk(metadataDisplay, strconv.Itoa(gs.captureDisplay+1)),
+			encoder.TextChunk(metadataGeometry, formatGeometry(gs.displayBounds)))
+	}
+
+	if gs.hasRedaction() {
+		glog.Infof("Annotations and original screenshot not saved in the image: it has redactions")
+		return chunks, true
+	}
+	layers := make([]filters.Filter, len(gs.Filters))
+	for ii, filter := range gs.Filters {
+		layers[ii] = filter
+	}
+	annotations, err := filters.MarshalFilters(layers)
+	if err != nil {
+		glog.Warningf("Annotations not saved in the image: %s", err)
+		return chunks, false
+	}
+	chunks = append(chunks, encoder.TextChunk(metadataAnnotations, string(annotations)))
+
+	if gs.App.Preferences().BoolWithFallback(EmbedOriginalPreference, false) {
+		var original bytes.Buffer
+		crop := gs.OriginalScreenshot.SubImage(gs.CropRect)
+		if err := encoder.Encode(&original, crop, encoder.DefaultOptions()); err != nil {
+			glog.Warningf("Original screenshot not saved in the image: %s", err)
+			return chunks, false
+		}
+		chunks = append(chunks, encoder.Chunk{Type: originalChunkType, Data: original.Bytes()})
+	}
+	return chunks, false
+}
+
+// loadImage 使用 content 替换当前的截图。FireShotGo 保存的 PNG 中有没有标注的截图和标注时，
+// 恢复截图、裁剪区域和标注，标注可以继续编辑，否则直接打开图片。
+func (gs *FireShotGO) loadImage(content []byte) (editable bool, err error) {
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return false, err
+	}
+	chunks, err := encoder.ReadChunks(content)
+	if err != nil {
+		glog.Warningf("Ignoring broken PNG metadata: %s", err)
+	}
+	texts := encoder.TextChunks(chunks)
+
+	when := time.Now()
+	if t, err := time.Parse(time.RFC3339, texts[metadataCreationTime]); err == nil {
+		when = t
+	}
+
+	// 尝试恢复标注
+	var (
+		restored *image.RGBA
+		crop     image.Rectangle
+		list     []ImageFilter
+	)
+	for _, c := range chunks {
+		if c.Type != originalChunkType {
+			continue
+		}
+		annotations, found := texts[metadataAnnotations]
+		if !found {
+			break
+		}
+		crop, err = parseGeometry(texts[metadataCrop])
+		if err != nil || crop.Min.X < 0 || crop.Min.Y < 0 {
+			glog.Warningf("Ignoring annotations, invalid crop: %v", err)
+			break
+		}
+		original, err := png.Decode(bytes.NewReader(c.Data))
+		if err != nil || original.Bounds().Size() != crop.Size() {
+			glog.Warningf("Ignoring annotations, invalid original screenshot: %v", err)
+			break
+		}
+		layers, err := filters.UnmarshalFilters([]byte(annotations))
+		if err != nil {
+			glog.Warningf("Ignoring annotations: %s", err)
+			break
+		}
+		// 截图放回裁剪之前的位置，标注使用的是原始截图的坐标
+		restored = image.NewRGBA(image.Rect(0, 0, crop.Max.X, crop.Max.Y))
+		draw.Draw(restored, crop, original, original.Bounds().Min, draw.Src)
+		for _, layer := range layers {
+			if p, ok := layer.(*filters.Pixelate); ok {
+				p.SetSource(restored)
+			}
+			list = append(list, layer.(ImageFilter))
+		}
+		break
+	}
+
+	if restored == nil {
+		bounds := img.Bounds()
+		rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(rgba, rgba.Rect, img, bounds.Min, draw.Src)
+		gs.setScreenshot(rgba, when)
+		gs.Filters = nil
+	} else {
+		gs.setScreenshot(restored, when)
+		gs.CropRect = crop
+		gs.Filters = list
+	}
+	gs.captureTitle = texts[metadataTitle]
+	gs.captureDisplay, gs.displayBounds = -1, image.Rectangle{}
+	if display, err := strconv.Atoi(texts[metadataDisplay]); err == nil {
+		if bounds, err := parseGeometry(texts[metadataGeometry]); err == nil {
+			gs.captureDisplay, gs.displayBounds = display-1, bounds
+		}
+	}
+	return restored != nil, nil
+}
+
+// OpenImage 打开图片替换当前的截图，FireShotGo 保存的 PNG 可以继续编辑其中的标注
+func (gs *FireShotGO) OpenImage() {
+	glog.V(2).Info("FireShotGO.OpenImage")
+	fileOpen := dialog.NewFileOpen(
+		func(reader fyne.URIReadCloser, err error) {
+			if err != nil {
+				glog.Errorf("Failed to open image: %s", err)
+				gs.status.SetText(fmt.Sprintf("Failed to open image: %s", err))
+				return
+			}
+			if reader == nil {
+				gs.status.SetText("Open file cancelled.")
+				return
+			}
+			glog.V(2).Infof("OpenImage(): URI=%s", reader.URI())
+			defer func() { _ = reader.Close() }()
+			gs.App.Preferences().SetString(DefaultPathPreference, path.Dir(reader.URI().Path()))
+
+			content, err := io.ReadAll(reader)
+			var editable bool
+			if err == nil {
+				editable, err = gs.loadImage(content)
+			}
+			if err != nil {
+				glog.Errorf("Failed to open image %q: %s", reader.URI(), err)
+				gs.status.SetText(fmt.Sprintf("Failed to open image %q: %s", reader.URI(), err))
+				return
+			}
+			gs.ApplyFilters(true)
+			gs.miniMap.updateViewPortRect()
+			gs.viewPort.Refresh()
+			gs.miniMap.Refresh()
+			if editable {
+				gs.status.SetText(fmt.Sprintf("Opened %q with %d editable annotations", reader.URI(), len(gs.Filters)))
+			} else {
+				gs.status.SetText(fmt.Sprintf("Opened %q", reader.URI()))
+			}
+		}, gs.Win)
+	fileOpen.SetFilter(storage.NewExtensionFileFilter([]string{".png", ".jpg", ".jpeg", ".gif", ".bmp", ".tiff", ".tif"}))
+	if defaultPath := gs.App.Preferences().String(DefaultPathPreference); defaultPath != "" {
+		lister, err := storage.ListerForURI(storage.NewFileURI(defaultPath))
+		if err == nil {
+			fileOpen.SetLocation(lister)
+		} else {
+			glog.Warningf("Cannot create a ListableURI for %q", defaultPath)
+		}
+	}
+	size := gs.Win.Canvas().Size()
+	size.Width *= 0.90
+	size.Height *= 0.90
+	fileOpen.Resize(size)
+	fileOpen.Show()
+}
+
+// MetadataForm 设置保存在 PNG 中的信息: 截图标题、是否保存没有标注的截图，以及是否去掉所有的附加信息
+func (gs *FireShotGO) MetadataForm() {
+	opts := gs.encoderOptions()
+
+	titleEntry := widget.NewEntry()
+	titleEntry.SetText(gs.captureTitle)
+	titleEntry.SetPlaceHolder("例如截图的窗口标题")
+	embedCheck := widget.NewCheck("保存没有标注的截图，重新打开时可以编辑标注", nil)
+	embedCheck.SetChecked(gs.App.Preferences().BoolWithFallback(EmbedOriginalPreference, false))
+	stripCheck := widget.NewCheck("不保存任何信息(截图时间、屏幕、标注等)", nil)
+	stripCheck.SetChecked(opts.StripMetadata)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("标题", titleEntry),
+		widget.NewFormItem("标注", embedCheck),
+		widget.NewFormItem("隐私", stripCheck),
+		widget.NewFormItem("", widget.NewLabel("有遮挡块或者马赛克时不会保存标注和没有标注的截图")),
+		widget.NewFormItem("", widget.NewLabel("只有 PNG 会保存这些信息，复制和上传的图片不包含这些信息")),
+	}
+	dialog.ShowForm("图片信息", "确认", "取消", items,
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			gs.captureTitle = titleEntry.Text
+			gs.App.Preferences().SetBool(EmbedOriginalPreference, embedCheck.Checked)
+			opts.StripMetadata = stripCheck.Checked
+			gs.setEncoderOptions(opts)
+		}, gs.Win)
+}
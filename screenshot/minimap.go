@@ -5,6 +5,7 @@ import (
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"gitee.com/andrewgithub/FireShotGo/filters"
 	"github.com/golang/glog"
 	"image"
 	"image/color"
@@ -310,26 +311,29 @@ func (mm *MiniMap) renderCache() {
 	imgW, imgH := wh(img)
 
 	const bytesPerPixel = 4 // RGBA.
-	var c color.RGBA
 
 	glog.V(2).Infof("renderCache(): cache=(w=%d, h=%d, bytes=%d), zoom=%gx",
 		w, h, len(mm.cache.Pix), mm.zoom)
 
-	for y := 0; y < h; y++ {
-		for x := 0; x < w; x++ {
-			pos := (y*w + x) * bytesPerPixel
-			imgX := int(math.Round(float64(x-mm.thumbX)*mm.zoom + 0.5))
-			imgY := int(math.Round(float64(y-mm.thumbY)*mm.zoom + 0.5))
-			if imgX < 0 || imgX >= imgW || imgY < 0 || imgY >= imgH {
-				// Background image.
-				c = bgPattern(x, y)
-			} else {
-				c = img.RGBAAt(imgX, imgY)
+	// 按照分块并行渲染
+	filters.Parallel(filters.Tiles(mm.cache.Rect), func(tile image.Rectangle) {
+		var c color.RGBA
+		for y := tile.Min.Y; y < tile.Max.Y; y++ {
+			for x := tile.Min.X; x < tile.Max.X; x++ {
+				pos := (y*w + x) * bytesPerPixel
+				imgX := int(math.Round(float64(x-mm.thumbX)*mm.zoom + 0.5))
+				imgY := int(math.Round(float64(y-mm.thumbY)*mm.zoom + 0.5))
+				if imgX < 0 || imgX >= imgW || imgY < 0 || imgY >= imgH {
+					// Background image.
+					c = bgPattern(x, y)
+				} else {
+					c = img.RGBAAt(imgX, imgY)
+				}
+				mm.cache.Pix[pos] = c.R
+				mm.cache.Pix[pos+1] = c.G
+				mm.cache.Pix[pos+2] = c.B
+				mm.cache.Pix[pos+3] = c.A
 			}
-			mm.cache.Pix[pos] = c.R
-			mm.cache.Pix[pos+1] = c.G
-			mm.cache.Pix[pos+2] = c.B
-			mm.cache.Pix[pos+3] = c.A
 		}
-	}
+	})
 }
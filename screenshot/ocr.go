@@ -0,0 +1,158 @@
+package screenshot
+
+import (
+	"context"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"gitee.com/andrewgithub/FireShotGo/clipboard"
+	"gitee.com/andrewgithub/FireShotGo/filters"
+	"gitee.com/andrewgithub/FireShotGo/ocr"
+	"github.com/golang/glog"
+	"image"
+	"image/color"
+	"image/draw"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	OCRLanguagePreference   = "OCRLanguage"
+	TesseractPathPreference = "TesseractPath"
+)
+
+// ocrTimeout 一次识别最长的时间
+const ocrTimeout = time.Minute
+
+// selectionColor 选择区域时显示的虚线框的颜色
+var selectionColor = color.RGBA{R: 0x1e, G: 0x90, B: 0xff, A: 0xff}
+
+// ocrEngine 返回文字识别引擎: 没有设置 gs.ocr 时使用本机安装的 tesseract
+func (gs *FireShotGO) ocrEngine() ocr.Engine {
+	if gs.ocr != nil {
+		return gs.ocr
+	}
+	return ocr.NewTesseract(gs.App.Preferences().String(TesseractPathPreference))
+}
+
+// ocrLanguage 识别使用的语言，默认是英文
+func (gs *FireShotGO) ocrLanguage() string {
+	return gs.App.Preferences().StringWithFallback(OCRLanguagePreference, "eng")
+}
+
+// ocrRegion 复制截图中 rect 区域(原始截图的坐标)用于识别，rect 为空时使用整张截图。
+// 返回的图片使用原始截图的坐标，识别的结果也使用原始截图的坐标。
+// 需要在启动后台识别之前调用: 识别在后台进行时截图可能被修改。
+func (gs *FireShotGO) ocrRegion(rect image.Rectangle) *image.RGBA {
+	if rect.Empty() {
+		rect = gs.CropRect
+	}
+	rect = rect.Intersect(gs.CropRect)
+	region := image.NewRGBA(rect)
+	draw.Draw(region, rect, gs.Screenshot, rect.Min.Sub(gs.CropRect.Min), draw.Src)
+	return region
+}
+
+// CopyTextFromSelection 选择一个区域，识别其中的文字并复制到剪贴板
+func (gs *FireShotGO) CopyTextFromSelection() {
+	gs.viewPort.SetOp(SelectText)
+}
+
+// copyText 在后台识别 rect 中的文字并复制到剪贴板
+func (gs *FireShotGO) copyText(rect image.Rectangle) {
+	region := gs.ocrRegion(rect)
+	engine, lang := gs.ocrEngine(), gs.ocrLanguage()
+	gs.status.SetText("Recognizing text ...")
+	ctx, cancel := context.WithTimeout(context.Background(), ocrTimeout)
+	go func() {
+		defer cancel()
+		result, err := engine.Recognize(ctx, region, lang)
+		if err != nil {
+			glog.Errorf("Failed to recognize text: %s", err)
+			gs.status.SetText(fmt.Sprintf("Failed to recognize text: %s", err))
+			return
+		}
+		text := result.Text()
+		if text == "" {
+			gs.status.SetText("No text recognized.")
+			return
+		}
+		if err := clipboard.CopyText(text); err != nil {
+			glog.Errorf("Failed to copy text to clipboard: %s", err)
+			gs.status.SetText(fmt.Sprintf("Failed to copy text to clipboard: %s", err))
+			return
+		}
+		gs.status.SetText(fmt.Sprintf("Copied %d characters (%d words) to clipboard.",
+			utf8.RuneCountInString(text), len(result.Words)))
+	}()
+}
+
+// startSelection 开始拖动选择区域，选择框作为临时的滤镜显示，结束时通过 endSelection 移除
+func (vp *ViewPort) startSelection(start image.Point) {
+	vp.currentSelection = filters.NewRectangle(image.Rectangle{Min: start, Max: start.Add(image.Point{X: 1, Y: 1})},
+		selectionColor, 2)
+	vp.currentSelection.SetStyle(filters.ShapeStyle{StrokeOpacity: 1, FillOpacity: 1, Dash: filters.DashDashed}, 0)
+	vp.fs.Filters = append(vp.fs.Filters, vp.currentSelection)
+	vp.fs.ApplyFilters(false)
+}
+
+// dragSelection 拖动时更新选择框
+func (vp *ViewPort) dragSelection(toPos fyne.Position) {
+	if vp.currentSelection == nil {
+		glog.Errorf("dragSelection(): dragSelection event, but none has been started yet!?")
+		return
+	}
+	startX, startY := vp.screenshotPos(vp.dragStart)
+	toX, toY := vp.screenshotPos(toPos)
+	rect := image.Rect(startX, startY, toX, toY).Add(vp.fs.CropRect.Min) // image.Rect 保证 Min < Max
+	vp.currentSelection.SetRect(rect)
+	vp.fs.ApplyFilters(false)
+	vp.renderCache()
+	vp.Refresh()
+}
+
+// endSelection 移除选择框，返回选择的区域(原始截图的坐标)
+func (vp *ViewPort) endSelection() image.Rectangle {
+	if vp.currentSelection == nil {
+		return image.Rectangle{}
+	}
+	rect := vp.currentSelection.Rect
+	vp.fs.removeFilter(vp.currentSelection)
+	vp.currentSelection = nil
+	return rect
+}
+
+// OCRForm 设置文字识别使用的 tesseract 以及语言
+func (gs *FireShotGO) OCRForm() {
+	pathEntry := widget.NewEntry()
+	pathEntry.SetText(gs.App.Preferences().String(TesseractPathPreference))
+	pathEntry.SetPlaceHolder("tesseract")
+
+	// 可以选择已经安装的语言，也可以输入多种语言，例如 "chi_sim+eng"
+	langEntry := widget.NewSelectEntry(nil)
+	langEntry.SetText(gs.ocrLanguage())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	langs, err := gs.ocrEngine().Languages(ctx)
+	cancel()
+	status := widget.NewLabel(fmt.Sprintf("已安装的语言: %d", len(langs)))
+	if err != nil {
+		glog.Warningf("Failed to list OCR languages: %s", err)
+		status.SetText(fmt.Sprintf("Failed to list languages: %s", err))
+	}
+	langEntry.SetOptions(langs)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Tesseract", pathEntry),
+		widget.NewFormItem("语言", langEntry),
+		widget.NewFormItem("", status),
+	}
+	dialog.ShowForm("文字识别", "确认", "取消", items,
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			gs.App.Preferences().SetString(TesseractPathPreference, pathEntry.Text)
+			gs.App.Preferences().SetString(OCRLanguagePreference, langEntry.Text)
+		}, gs.Win)
+}
@@ -0,0 +1,137 @@
+package screenshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/validation"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"gitee.com/andrewgithub/FireShotGo/pdf"
+	"github.com/golang/glog"
+	"io"
+	"strconv"
+)
+
+const (
+	PDFOptionsPreference = "PDFOptions"
+	PDFTitlePreference   = "PDFTitle"
+)
+
+// pointsPerMM 页边距在界面上使用毫米，PDF 中使用 pt
+const pointsPerMM = 72 / 25.4
+
+// pdfOptions 读取保存的 PDF 导出参数，没有保存过就使用默认参数
+func (gs *FireShotGO) pdfOptions() pdf.Options {
+	opts := pdf.DefaultOptions()
+	if saved := gs.App.Preferences().String(PDFOptionsPreference); saved != "" {
+		if err := json.Unmarshal([]byte(saved), &opts); err != nil {
+			glog.Warningf("Ignoring invalid PDF options %q: %s", saved, err)
+			opts = pdf.DefaultOptions()
+		}
+	}
+	return opts
+}
+
+func (gs *FireShotGO) setPDFOptions(opts pdf.Options) {
+	content, err := json.Marshal(opts)
+	if err != nil {
+		glog.Errorf("Failed to save PDF options: %s", err)
+		return
+	}
+	gs.App.Preferences().SetString(PDFOptionsPreference, string(content))
+}
+
+// pdfTitle 页眉中的标题，没有设置时使用截图的默认名称
+func (gs *FireShotGO) pdfTitle(name string) string {
+	if title := gs.App.Preferences().String(PDFTitlePreference); title != "" {
+		return title
+	}
+	return name
+}
+
+// currentPDFPage 当前编辑中的截图(包含标注和美化)
+func (gs *FireShotGO) currentPDFPage() pdf.Page {
+	return pdf.Page{Image: gs.ExportImage(), Title: gs.pdfTitle(gs.DefaultName()), Time: gs.ScreenshotTime}
+}
+
+// ExportPDFForm 导出 PDF: 选择需要导出的截图(当前截图以及本次运行中之前的截图，每张一页)和页面设置
+func (gs *FireShotGO) ExportPDFForm() {
+	opts := gs.pdfOptions()
+
+	// 需要导出的截图，之前的截图按照从新到旧排列
+	current := widget.NewCheck("当前截图", nil)
+	current.SetChecked(true)
+	captureChecks := container.NewVBox(current)
+	previous := make(map[*widget.Check]capture)
+	for ii := len(gs.previousCaptures) - 1; ii >= 0; ii-- {
+		c := gs.previousCaptures[ii]
+		check := widget.NewCheck(c.name, nil)
+		previous[check] = c
+		captureChecks.Add(check)
+	}
+
+	pageSizeNames := make([]string, len(pdf.PageSizes))
+	for ii, ps := range pdf.PageSizes {
+		pageSizeNames[ii] = ps.Name
+	}
+	pageSizeSelect := widget.NewSelect(pageSizeNames, nil)
+	pageSizeSelect.SetSelected(opts.PageSize.Name)
+	orientationSelect := widget.NewSelect(pdf.OrientationNames, nil)
+	orientationSelect.SetSelected(opts.Orientation.String())
+
+	marginEntry := &widget.Entry{Validator: validation.NewRegexp(`^\d+(\.\d*)?$`, "Must contain a number")}
+	marginEntry.SetText(fmt.Sprintf("%g", float64(int(opts.Margin/pointsPerMM*10+0.5))/10))
+
+	header := widget.NewCheck("打印标题和截图时间", nil)
+	header.SetChecked(opts.Header)
+	titleEntry := widget.NewEntry()
+	titleEntry.SetText(gs.App.Preferences().String(PDFTitlePreference))
+	titleEntry.SetPlaceHolder("默认使用截图名称")
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("截图", captureChecks),
+		widget.NewFormItem("纸张", pageSizeSelect),
+		widget.NewFormItem("方向", orientationSelect),
+		widget.NewFormItem("页边距 (mm)", marginEntry),
+		widget.NewFormItem("", header),
+		widget.NewFormItem("标题", titleEntry),
+	}
+	dialog.ShowForm("导出 PDF", "确认", "取消", items,
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			margin, err := strconv.ParseFloat(marginEntry.Text, 64)
+			if err != nil {
+				gs.status.SetText(fmt.Sprintf("Can't parse margin %q: %s", marginEntry.Text, err))
+				return
+			}
+			opts.PageSize = pdf.ParsePageSize(pageSizeSelect.Selected)
+			opts.Orientation = pdf.ParseOrientation(orientationSelect.Selected)
+			opts.Margin = margin * pointsPerMM
+			opts.Header = header.Checked
+			gs.setPDFOptions(opts)
+			gs.App.Preferences().SetString(PDFTitlePreference, titleEntry.Text)
+
+			var pages []pdf.Page
+			if current.Checked {
+				pages = append(pages, gs.currentPDFPage())
+			}
+			for _, obj := range captureChecks.Objects[1:] {
+				check := obj.(*widget.Check)
+				if !check.Checked {
+					continue
+				}
+				c := previous[check]
+				pages = append(pages, pdf.Page{Image: c.annotated, Title: gs.pdfTitle(c.name), Time: c.time})
+			}
+			if len(pages) == 0 {
+				gs.status.SetText("Select at least one screenshot to export.")
+				return
+			}
+			gs.saveImageAs(gs.DefaultName()+".pdf", func(w io.Writer, _ string) (string, error) {
+				return "", pdf.Write(w, pages, opts)
+			})
+		}, gs.Win)
+}
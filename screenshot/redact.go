@@ -0,0 +1,149 @@
+package screenshot
+
+import (
+	"context"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"gitee.com/andrewgithub/FireShotGo/filters"
+	"gitee.com/andrewgithub/FireShotGo/redact"
+	"github.com/golang/glog"
+	"image/color"
+	"os"
+)
+
+// RedactionRulesPreference 遮挡规则文件的路径，为空时使用默认规则
+const RedactionRulesPreference = "RedactionRules"
+
+// redactionColor 遮挡块的颜色
+var redactionColor = color.RGBA{A: 0xFF}
+
+// redactionPolicy 读取遮挡规则
+func (gs *FireShotGO) redactionPolicy() (redact.Policy, error) {
+	path := gs.App.Preferences().String(RedactionRulesPreference)
+	if path == "" {
+		return redact.DefaultPolicy(), nil
+	}
+	return redact.Load(path)
+}
+
+// AutoRedact 识别截图中的文字，按照遮挡规则查找需要遮挡的文字，
+// 之后列出所有匹配的文字，确认之后在选择的文字上添加遮挡块或者马赛克。
+// 识别和匹配在后台进行，标注只在对话框的回调(界面的 goroutine)中修改。
+func (gs *FireShotGO) AutoRedact() {
+	policy, err := gs.redactionPolicy()
+	var matcher *redact.Matcher
+	if err == nil {
+		matcher, err = policy.Compile()
+	}
+	if err != nil {
+		glog.Errorf("Failed to load redaction rules: %s", err)
+		gs.status.SetText(fmt.Sprintf("Failed to load redaction rules: %s", err))
+		return
+	}
+
+	region := gs.ocrRegion(gs.CropRect)
+	engine, lang := gs.ocrEngine(), gs.ocrLanguage()
+	gs.status.SetText("Recognizing text ...")
+	ctx, cancel := context.WithTimeout(context.Background(), ocrTimeout)
+	go func() {
+		defer cancel()
+		result, err := engine.Recognize(ctx, region, lang)
+		if err != nil {
+			glog.Errorf("Failed to recognize text: %s", err)
+			gs.status.SetText(fmt.Sprintf("Failed to recognize text: %s", err))
+			return
+		}
+		matches := matcher.Find(result)
+		if len(matches) == 0 {
+			gs.status.SetText(fmt.Sprintf("Nothing to redact in %d recognized words.", len(result.Words)))
+			return
+		}
+		gs.status.SetText(fmt.Sprintf("Found %d items to redact, please review them.", len(matches)))
+		gs.reviewRedactions(matches, matcher.BlockSize())
+	}()
+}
+
+// reviewRedactions 列出匹配的文字，确认之后遮挡选择的文字，取消选择的不会被遮挡
+func (gs *FireShotGO) reviewRedactions(matches []redact.Match, blockSize int) {
+	list := container.NewVBox()
+	checks := make([]*widget.Check, len(matches))
+	for ii, m := range matches {
+		checks[ii] = widget.NewCheck(fmt.Sprintf("[%s] %s", m.Rule.Name, m.Text), nil)
+		checks[ii].SetChecked(true)
+		list.Add(checks[ii])
+	}
+	scroll := container.NewVScroll(list)
+	scroll.SetMinSize(fyne.NewSize(400, 300))
+	content := container.NewBorder(widget.NewLabel("取消选择不需要遮挡的内容，之后也可以使用 Control+Z 撤销"), nil, nil, nil, scroll)
+	dialog.ShowCustomConfirm("自动遮挡", "遮挡", "取消", content, func(ok bool) {
+		if !ok {
+			gs.status.SetText("Redaction cancelled.")
+			return
+		}
+		added := 0
+		for ii, m := range matches {
+			if !checks[ii].Checked {
+				continue
+			}
+			if m.Rule.Style == redact.Pixelate {
+				gs.Filters = append(gs.Filters, filters.NewPixelate(gs.OriginalScreenshot, m.Box, blockSize))
+			} else {
+				gs.Filters = append(gs.Filters, filters.NewShieldBlock(m.Box, redactionColor))
+			}
+			added++
+		}
+		gs.ApplyFilters(true)
+		gs.status.SetText(fmt.Sprintf("%d redactions added, use Control+Z to remove them.", added))
+	}, gs.Win)
+}
+
+// RedactionRulesForm 设置遮挡规则文件。规则文件不存在时写入默认规则，修改之后可以分享给其他人使用。
+func (gs *FireShotGO) RedactionRulesForm() {
+	pathEntry := widget.NewEntry()
+	pathEntry.SetText(gs.App.Preferences().String(RedactionRulesPreference))
+	pathEntry.SetPlaceHolder("为空时使用默认规则")
+
+	summary := widget.NewLabel("")
+	if policy, err := gs.redactionPolicy(); err != nil {
+		summary.SetText(err.Error())
+	} else {
+		names := ""
+		for _, rule := range policy.Rules {
+			if !rule.Disabled {
+				names += " " + rule.Name
+			}
+		}
+		summary.SetText(fmt.Sprintf("%d 条规则:%s", len(policy.Rules), names))
+	}
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("规则文件", pathEntry),
+		widget.NewFormItem("", summary),
+		widget.NewFormItem("", widget.NewLabel("文件不存在时会写入默认规则，可以修改之后分享给团队")),
+	}
+	dialog.ShowForm("遮挡规则", "确认", "取消", items,
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			path := pathEntry.Text
+			if path != "" {
+				if _, err := os.Stat(path); os.IsNotExist(err) {
+					if err := redact.DefaultPolicy().Save(path); err != nil {
+						glog.Errorf("Failed to write redaction rules to %q: %s", path, err)
+						gs.status.SetText(fmt.Sprintf("Failed to write redaction rules to %q: %s", path, err))
+						return
+					}
+				} else if _, err := redact.Load(path); err != nil {
+					glog.Errorf("Failed to load redaction rules: %s", err)
+					gs.status.SetText(err.Error())
+					return
+				}
+			}
+			gs.App.Preferences().SetString(RedactionRulesPreference, path)
+			gs.status.SetText("Redaction rules updated.")
+		}, gs.Win)
+}
@@ -20,15 +20,20 @@ import (
 	"fyne.io/fyne/v2/widget"
 	"gitee.com/andrewgithub/FireShotGo/clipboard"
 	"gitee.com/andrewgithub/FireShotGo/cloud"
+	"gitee.com/andrewgithub/FireShotGo/encoder"
+	"gitee.com/andrewgithub/FireShotGo/filters"
+	"gitee.com/andrewgithub/FireShotGo/ocr"
+	"gitee.com/andrewgithub/FireShotGo/pdf"
 	"gitee.com/andrewgithub/FireShotGo/resources"
 	"github.com/golang/glog"
 	"github.com/kbinani/screenshot"
 	"image"
 	"image/color"
 	"image/draw"
-	"image/png"
+	"io"
 	"path"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -44,18 +49,28 @@ type FireShotGO struct {
 	OriginalScreenshot *image.RGBA
 	// 截图时间记录
 	ScreenshotTime time.Time
+	// previousCaptures 本次运行中之前的截图(最多 maxPreviousCaptures 张)，可以插入到当前的截图中
+	previousCaptures []capture
 
 	// 编辑之后的截图信息，每添加一个fileter这里都进行叠加一次
 	Screenshot *image.RGBA // The edited/composed screenshot
 	CropRect   image.Rectangle
 	// 所有的fileter都添加到这里
 	Filters []ImageFilter // Configured filters: each filter is one edition to the image.
+	// compositor 缓存每个滤镜的图层，合成 Filters 时只重新计算变化的区域
+	compositor *filters.Compositor
+	// geometryEdits 旋转、翻转和修改画布大小之前的状态，Filters 为空时 Control+Z 恢复最后一个
+	geometryEdits []geometryEdit
+	// overlayMoves 拖动插入的图片之前的位置，Control+Z 按照顺序和标注一起撤销
+	overlayMoves []overlayMove
 
 	// UI 元素
 	// zoomEntry 缩放窗口控件 thicknessEntry 设置线条粗细的控件
 	zoomEntry, thicknessEntry *widget.Entry
 	// colorSample 颜色示例窗口
 	colorSample *canvas.Rectangle
+	// cropAspectSelect 拖动裁剪时锁定的宽高比
+	cropAspectSelect *widget.Select
 	// 工具左下角显示当前工作状态
 	status *widget.Label
 	// 预览窗口
@@ -83,9 +98,33 @@ type FireShotGO struct {
 
 	// 记录当前需要截取那个屏幕,默认情况下是0
 	displayIndex int
+	// captureDisplay, displayBounds 当前截图所在的屏幕序号(没有时为 -1)以及屏幕的位置和大小，
+	// captureTitle 用户为截图设置的标题，都会保存在 PNG 中
+	captureDisplay int
+	displayBounds  image.Rectangle
+	captureTitle   string
 
 	// 当前系统字体大小
 	fireShotGoFont FireShotFont
+
+	// ocr 文字识别引擎，为空时使用本机安装的 tesseract，参考 ocrEngine
+	ocr ocr.Engine
+}
+
+// maxPreviousCaptures previousCaptures 最多保留的截图数，更早的截图会被丢弃，
+// 否则长时间运行时每次截图都会增加占用的内存
+const maxPreviousCaptures = 20
+
+// capture 一次截图以及它的名称
+type capture struct {
+	name string
+	img  *image.RGBA
+	// crop 替换截图时的裁剪区域，img 是没有裁剪的原始截图
+	crop image.Rectangle
+
+	// annotated 替换截图时导出的图片(包含标注)，用于导出多页 PDF
+	annotated image.Image
+	time      time.Time
 }
 
 type ImageFilter interface {
@@ -96,16 +135,18 @@ type ImageFilter interface {
 
 // ApplyFilters will apply `Filters` to the `CropRect` of the original image
 // and regenerate Screenshot.
-// If full == true, regenerates full Screenshot. If false, regenerates only
-// visible area.
+// 每个滤镜缓存在自己的图层中，只有修改过的滤镜会重新绘制，并且只有变化的区域会重新合成。
+// If full == true, copies the full CropRect into Screenshot. If false, copies
+// only the tiles that changed. 分块之间并行处理。
 // 绘制预览图
 func (fs *FireShotGO) ApplyFilters(full bool) {
 	glog.V(2).Infof("ApplyFilters: %d filters", len(fs.Filters))
-	// 图像叠加
-	filteredImage := image.Image(fs.OriginalScreenshot)
-	for _, filter := range fs.Filters {
-		filteredImage = filter.Apply(filteredImage)
+	layers := make([]filters.Filter, len(fs.Filters))
+	for ii, filter := range fs.Filters {
+		layers[ii] = filter
 	}
+	// 图像叠加
+	dirty := fs.compositor.Compose(fs.OriginalScreenshot, layers)
 
 	if fs.Screenshot == fs.OriginalScreenshot || fs.Screenshot.Rect.Dx() != fs.CropRect.Dx() || fs.Screenshot.Rect.Dy() != fs.CropRect.Dy() {
 		// Recreate image buffer.
@@ -114,14 +155,15 @@ func (fs *FireShotGO) ApplyFilters(full bool) {
 		full = true // Regenerate the full buffer.
 	}
 	if full {
-		draw.Src.Draw(fs.Screenshot, fs.Screenshot.Rect, filteredImage, fs.CropRect.Min)
-	} else {
-		var tgtRect image.Rectangle
-		tgtRect.Min = image.Point{X: fs.viewPort.viewX, Y: fs.viewPort.viewY}
-		tgtRect.Max = tgtRect.Min.Add(image.Point{X: fs.viewPort.viewW, Y: fs.viewPort.viewH})
-		srcPoint := fs.CropRect.Min.Add(tgtRect.Min)
-		draw.Src.Draw(fs.Screenshot, tgtRect, filteredImage, srcPoint)
+		dirty = filters.Tiles(fs.CropRect)
 	}
+	composed := fs.compositor.Image()
+	filters.Parallel(dirty, func(tile image.Rectangle) {
+		tile = tile.Intersect(fs.CropRect)
+		if !tile.Empty() {
+			draw.Src.Draw(fs.Screenshot, tile.Sub(fs.CropRect.Min), composed, tile.Min)
+		}
+	})
 
 	if fs.viewPort != nil {
 		fs.viewPort.renderCache()
@@ -141,6 +183,8 @@ func Run() {
 		// 使用给后期需要 独立配置参数的 Fyne需要使用  NewWithID 没有要求的可以使用app.New()
 		// 使用带有ID的new方便后期绑定应用全局数据
 		App: app.NewWithID("FireShotGo"),
+		// 所有的截图共用一个合成器，截图改变时合成器会丢弃缓存
+		compositor: filters.NewCompositor(),
 	}
 	// 开始截屏 --
 	err := fireShotGo.MakeScreenshot()
@@ -174,28 +218,90 @@ func (gs *FireShotGO) MakeScreenshot() error {
 		bounds.Min.X, bounds.Min.Y,
 		bounds.Max.X, bounds.Max.Y)
 
-	var err error
 	// 根据指定的bounds信息截取屏幕
-	gs.Screenshot, err = screenshot.CaptureRect(bounds)
+	img, err := screenshot.CaptureRect(bounds)
 	if err != nil {
 		glog.Errorf("CaptureRect failed.")
 		return err
 	}
-	// 将刚截好图的信息被分到原始截图信息上，以便后期使用
-	gs.OriginalScreenshot = gs.Screenshot
-	gs.ScreenshotTime = time.Now()
-	gs.CropRect = gs.Screenshot.Bounds()
+	gs.setScreenshot(img, time.Now())
+	gs.captureDisplay, gs.displayBounds = gs.displayIndex, bounds
 
 	glog.V(2).Infof("截屏边界: %+v\n", bounds)
 	return nil
 }
 
+// cropped 裁剪之后的截图(不包括标注)，坐标从 (0, 0) 开始
+func (c capture) cropped() *image.RGBA {
+	return cropImage(c.img, c.crop)
+}
+
+// cropImage 将 img 中 rect 的部分复制到新的图片，坐标从 (0, 0) 开始
+func cropImage(img image.Image, rect image.Rectangle) *image.RGBA {
+	out := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(out, out.Rect, img, rect.Min, draw.Src)
+	return out
+}
+
+// setScreenshot 替换当前编辑的截图，之前的截图保留在 previousCaptures 中
+func (gs *FireShotGO) setScreenshot(img *image.RGBA, when time.Time) {
+	if gs.OriginalScreenshot != nil {
+		// 保留之前的截图，之后可以作为图片插入
+		gs.previousCaptures = append(gs.previousCaptures, capture{
+			name:      gs.DefaultName(),
+			img:       gs.OriginalScreenshot,
+			crop:      gs.CropRect,
+			annotated: gs.ExportImage(),
+			time:      gs.ScreenshotTime,
+		})
+		if n := len(gs.previousCaptures); n > maxPreviousCaptures {
+			// 复制到新的数组，丢弃的截图才能被回收
+			gs.previousCaptures = append([]capture(nil), gs.previousCaptures[n-maxPreviousCaptures:]...)
+		}
+	}
+	gs.Screenshot = img
+	// 将刚截好图的信息被分到原始截图信息上，以便后期使用
+	gs.OriginalScreenshot = gs.Screenshot
+	gs.ScreenshotTime = when
+	gs.CropRect = gs.Screenshot.Bounds()
+	gs.geometryEdits = nil
+	gs.overlayMoves = nil
+	gs.captureTitle = ""
+}
+
 // UndoLastFilter cancels the last filter applied, and regenerates everything.
+// 没有标注时撤销最后一次旋转、翻转或者修改画布大小。
 func (gs *FireShotGO) UndoLastFilter() {
+	if gs.undoOverlayMove() {
+		return
+	}
 	if len(gs.Filters) > 0 {
 		gs.Filters = gs.Filters[:len(gs.Filters)-1]
 		gs.ApplyFilters(true)
+		return
+	}
+	gs.undoGeometryEdit()
+}
+
+// removeFilter 删除 filter 并重新合成，filter 不在 Filters 中时返回 false
+func (gs *FireShotGO) removeFilter(filter ImageFilter) bool {
+	for ii, f := range gs.Filters {
+		if f == filter {
+			gs.Filters = append(gs.Filters[:ii], gs.Filters[ii+1:]...)
+			gs.ApplyFilters(true)
+			return true
+		}
 	}
+	return false
+}
+
+// writeSVG 将裁剪之后的截图和所有标注导出为 SVG，美化导出的效果不会包含在 SVG 中
+func (gs *FireShotGO) writeSVG(w io.Writer) error {
+	layers := make([]filters.Filter, len(gs.Filters))
+	for ii, filter := range gs.Filters {
+		layers[ii] = filter
+	}
+	return filters.WriteSVG(w, gs.OriginalScreenshot, gs.CropRect, layers)
 }
 
 // DefaultName returns a default name to the screenshot, based on date/time it was made.
@@ -237,6 +343,43 @@ const DefaultPathPreference = "DefaultPath"
 
 // SaveImage opens a file save dialog box to save the currently edited screenshot.
 func (gs *FireShotGO) SaveImage() {
+	gs.saveImageAs(gs.DefaultName()+gs.encoderOptions().Format.Extension(), gs.encodeImage)
+}
+
+// ExportSVG 导出为 SVG，标注保持为矢量
+func (gs *FireShotGO) ExportSVG() {
+	gs.saveImageAs(gs.DefaultName()+".svg", gs.encodeImage)
+}
+
+// encodeImage 按照扩展名 ext 选择保存的格式: .svg, .pdf 或者 encoder 支持的图片格式，
+// 不认识的扩展名使用"图片格式"中选择的格式。PNG 文件中会保存截图信息和标注，参考 metadataChunks。
+// 返回显示在状态栏中的优化前后的大小。
+func (gs *FireShotGO) encodeImage(w io.Writer, ext string) (string, error) {
+	switch ext {
+	case ".svg":
+		return "", gs.writeSVG(w)
+	case ".pdf":
+		return "", pdf.Write(w, []pdf.Page{gs.currentPDFPage()}, gs.pdfOptions())
+	}
+	opts := gs.encoderOptions()
+	if format, found := encoder.FromExtension(ext); found {
+		opts = opts.WithFormat(format)
+	}
+	var redacted bool
+	if opts.Format == encoder.PNG && !opts.StripMetadata {
+		opts.Chunks, redacted = gs.metadataChunks()
+	}
+	report, err := encoder.EncodeReport(w, gs.ExportImage(), opts)
+	detail := reportSizes(report)
+	if redacted {
+		detail += ", annotations not embedded because of redactions"
+	}
+	return detail, err
+}
+
+// saveImageAs 打开保存对话框，默认的文件名是 fileName。
+// encode 负责写入文件内容，ext 是用户选择的文件名的扩展名(小写)，返回的 detail 会显示在状态栏中。
+func (gs *FireShotGO) saveImageAs(fileName string, encode func(w io.Writer, ext string) (detail string, err error)) {
 	glog.V(2).Info("FireShotGO.SaveImage")
 	var fileSave *dialog.FileDialog
 	fileSave = dialog.NewFileSave(
@@ -258,17 +401,18 @@ func (gs *FireShotGO) SaveImage() {
 			gs.App.Preferences().SetString(DefaultPathPreference, defaultPath)
 
 			var contentBuffer bytes.Buffer
-			_ = png.Encode(&contentBuffer, gs.Screenshot)
-			content := contentBuffer.Bytes()
-			_, err = writer.Write(content)
+			detail, err := encode(&contentBuffer, strings.ToLower(writer.URI().Extension()))
+			if err == nil {
+				_, err = writer.Write(contentBuffer.Bytes())
+			}
 			if err != nil {
 				glog.Errorf("Failed to save image to %q: %s", writer.URI(), fileSave)
 				gs.status.SetText(fmt.Sprintf("Failed to save image to %q: %s", writer.URI(), err))
 				return
 			}
-			gs.status.SetText(fmt.Sprintf("Saved image to %q", writer.URI()))
+			gs.status.SetText(fmt.Sprintf("Saved image to %q%s", writer.URI(), detail))
 		}, gs.Win)
-	fileSave.SetFileName(gs.DefaultName() + ".png")
+	fileSave.SetFileName(fileName)
 	if defaultPath := gs.App.Preferences().String(DefaultPathPreference); defaultPath != "" {
 		lister, err := storage.ListerForURI(storage.NewFileURI(defaultPath))
 		if err == nil {
@@ -284,59 +428,15 @@ func (gs *FireShotGO) SaveImage() {
 	fileSave.Show()
 }
 
-// OpenImage opens a file save dialog box to save the currently edited screenshot.
-func (gs *FireShotGO) OpenImage() {
-	glog.V(2).Info("FireShotGO.OpenImage")
-	var fileOpen *dialog.FileDialog
-	fileOpen = dialog.NewFileOpen(
-		func(reader fyne.URIReadCloser, err error) {
-			if err != nil {
-				glog.Errorf("Failed to save image: %s", err)
-				gs.status.SetText(fmt.Sprintf("Failed to save image: %s", err))
-				return
-			}
-			if reader == nil {
-				gs.status.SetText("Save file cancelled.")
-				return
-			}
-			glog.V(2).Infof("SaveImage(): URI=%s", reader.URI())
-			defer func() { _ = reader.Close() }()
-
-			// Always default to previous path used:
-			defaultPath := path.Dir(reader.URI().Path())
-			gs.App.Preferences().SetString(DefaultPathPreference, defaultPath)
-
-			var contentBuffer bytes.Buffer
-			_ = png.Encode(&contentBuffer, gs.Screenshot)
-			content := contentBuffer.Bytes()
-			_, err = reader.Read(content)
-			if err != nil {
-				glog.Errorf("Failed to save image to %q: %s", reader.URI(), fileOpen)
-				gs.status.SetText(fmt.Sprintf("Failed to save image to %q: %s", reader.URI(), err))
-				return
-			}
-			gs.status.SetText(fmt.Sprintf("Saved image to %q", reader.URI()))
-		}, gs.Win)
-
-	fileOpen.SetFileName(gs.DefaultName() + ".png")
-	if defaultPath := gs.App.Preferences().String(DefaultPathPreference); defaultPath != "" {
-		lister, err := storage.ListerForURI(storage.NewFileURI(defaultPath))
-		if err == nil {
-			fileOpen.SetLocation(lister)
-		} else {
-			glog.Warningf("Cannot create a ListableURI for %q", defaultPath)
-		}
-	}
-	size := gs.Win.Canvas().Size()
-	size.Width *= 0.90
-	size.Height *= 0.90
-	fileOpen.Resize(size)
-	fileOpen.Show()
-}
-
 func (gs *FireShotGO) CopyImageToClipboard() {
 	glog.V(2).Info("FireShotGO.CopyImageToClipboard")
-	err := clipboard.CopyImage(gs.Screenshot)
+	img := gs.ExportImage()
+	opts := gs.encoderOptions()
+	var contentBuffer bytes.Buffer
+	err := encoder.Encode(&contentBuffer, img, opts)
+	if err == nil {
+		err = clipboard.CopyEncodedImage(img, contentBuffer.Bytes(), opts.Format.MimeType())
+	}
 	if err != nil {
 		glog.Errorf("Failed to copy to clipboard: %s", err)
 		gs.status.SetText(fmt.Sprintf("Failed to copy to clipboard: %s", err))
@@ -479,12 +579,13 @@ func (gs *FireShotGO) ShareWithQiNiuDrive() {
 					fileName := gs.FireShotNameByTime()
 					gs.qDriveNumShared++
 					// 每次图片的名称要递增
-					fileName = fmt.Sprintf("%s_%d.png", fileName, gs.qDriveNumShared)
-					err := gs.qDrive.QiNiuShareImage(fileName, gs.Screenshot)
+					opts := gs.encoderOptions()
+					fileName = fmt.Sprintf("%s_%d%s", fileName, gs.qDriveNumShared, opts.Format.Extension())
+					report, err := gs.qDrive.QiNiuShareImage(fileName, gs.ExportImage(), opts)
 					if err != nil {
 						gs.status.SetText(err.Error())
 					} else {
-						gs.status.SetText("图片上传成功 ...")
+						gs.status.SetText("图片上传成功 ..." + reportSizes(report))
 					}
 
 				}
@@ -526,7 +627,7 @@ func (gs *FireShotGO) ShareWithGoogleDrive() {
 		// Sharing the image must happen in a separate goroutine because the UI must
 		// remain interactive, also in order to capture the authorization input
 		// from the user.
-		url, err := gs.gDrive.ShareImage(ctx, fileName, gs.Screenshot)
+		url, err := gs.gDrive.ShareImage(ctx, fileName, gs.ExportImage(), gs.encoderOptions())
 		if err != nil {
 			glog.Errorf("Failed to share image in Google Drive: %s", err)
 			gs.status.SetText(fmt.Sprintf("GoogleDrive failed: %v", err))
@@ -580,14 +681,20 @@ func (gs *FireShotGO) RegisterShortcuts() {
 		func(_ fyne.Shortcut) { gs.viewPort.SetOp(CropTopLeft) })
 	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyK, Modifier: desktop.AltModifier},
 		func(_ fyne.Shortcut) { gs.viewPort.SetOp(CropBottomRight) })
+	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyX, Modifier: desktop.AltModifier},
+		func(_ fyne.Shortcut) { gs.viewPort.SetOp(CropDrag) })
 	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyC, Modifier: desktop.AltModifier},
 		func(_ fyne.Shortcut) { gs.viewPort.SetOp(DrawCircle) })
 	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyT, Modifier: desktop.AltModifier},
 		func(_ fyne.Shortcut) { gs.viewPort.SetOp(DrawText) })
 	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyA, Modifier: desktop.AltModifier},
 		func(_ fyne.Shortcut) { gs.viewPort.SetOp(DrawArrow) })
+	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyS, Modifier: desktop.AltModifier},
+		func(_ fyne.Shortcut) { gs.viewPort.SetOp(DrawStamp) })
 	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyZ, Modifier: desktop.ControlModifier},
 		func(_ fyne.Shortcut) { gs.UndoLastFilter() })
+	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyV, Modifier: desktop.ControlModifier | desktop.ShiftModifier},
+		func(_ fyne.Shortcut) { gs.InsertImageFromClipboard() })
 	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyS, Modifier: desktop.ControlModifier},
 		func(_ fyne.Shortcut) { gs.SaveImage() })
 	gs.Win.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyG, Modifier: desktop.ControlModifier},
@@ -606,6 +713,8 @@ func (gs *FireShotGO) RegisterShortcuts() {
 			if gs.shortcutsDialog != nil {
 				gs.shortcutsDialog.Hide()
 			}
+		} else if (ev.Name == fyne.KeyReturn || ev.Name == fyne.KeyEnter) && gs.viewPort.currentOperation == CropDrag {
+			gs.viewPort.applyCropSelection()
 		} else {
 			glog.V(2).Infof("KeyTyped: %+v", ev)
 		}
@@ -635,11 +744,15 @@ func (gs *FireShotGO) ShowShortcutsPage() {
 				container.NewGridWithColumns(2,
 					descFn("Crop Top-Left"), shortcutFn("Alt+J"),
 					descFn("Crop Bottom-Right"), shortcutFn("Alt+K"),
+					descFn("Crop By Dragging"), shortcutFn("Alt+X"),
+					descFn("Apply Crop"), shortcutFn("Enter"),
 					descFn("Draw Circle"), shortcutFn("Alt+C"),
 					descFn("Draw Arrow"), shortcutFn("Alt+A"),
 					descFn("Draw Text"), shortcutFn("Alt+T"),
+					descFn("Place Stamp"), shortcutFn("Alt+S"),
 					descFn("Cancel Operation"), shortcutFn("Esc"),
 					descFn("Undo Last Drawing"), shortcutFn("Control+Z"),
+					descFn("Paste Image"), shortcutFn("Control+Shift+V"),
 				),
 				titleFn("Sharing Image"),
 				container.NewGridWithColumns(2,
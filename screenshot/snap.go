@@ -0,0 +1,166 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"gitee.com/andrewgithub/FireShotGo/filters"
+	"image"
+	"math"
+	"strconv"
+)
+
+// SnapPreference 图形的端点和裁剪边缘是否对齐到参考线、网格和其他标注
+const SnapPreference = "Snap"
+
+// snapTolerance 对齐到参考线、网格和其他标注的距离，单位是视图中的像素，和缩放无关
+const snapTolerance = 6
+
+// filterEdges 标注的边缘: 图形使用定义的矩形或者端点，而不是包含线宽的 Bounds()
+func filterEdges(filter ImageFilter) (xs, ys []int) {
+	rect := func(r image.Rectangle) ([]int, []int) {
+		return []int{r.Min.X, r.Max.X}, []int{r.Min.Y, r.Max.Y}
+	}
+	line := func(from, to image.Point) ([]int, []int) {
+		return []int{from.X, to.X}, []int{from.Y, to.Y}
+	}
+	switch f := filter.(type) {
+	case *filters.Rectangle:
+		return rect(f.Rect)
+	case *filters.Circle:
+		return rect(f.Dim)
+	case *filters.ShieldBlock:
+		return rect(f.Rect)
+	case *filters.Pixelate:
+		return rect(f.Rect)
+	case *filters.Stamp:
+		return rect(f.Rect)
+	case *filters.ImageOverlay:
+		return rect(f.Rect)
+	case *filters.Arrow:
+		return line(f.From, f.To)
+	case *filters.StraightLine:
+		return line(f.From, f.To)
+	case *filters.DottedLine:
+		return line(f.From, f.To)
+	case *filters.Dimension:
+		return line(f.From, f.To)
+	case filters.Layer:
+		return rect(f.Bounds())
+	}
+	return nil, nil
+}
+
+// nearest 返回 targets 中离 v 最近并且距离不超过 tolerance 的值
+func nearest(v int, targets []int, tolerance int) (int, bool) {
+	best, found := v, false
+	for _, t := range targets {
+		if d := abs(t - v); d <= tolerance && (!found || d < abs(best-v)) {
+			best, found = t, true
+		}
+	}
+	return best, found
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// snapPoint 将 p (原始截图的坐标)分别在水平和垂直方向对齐到最近的参考线、网格线、截图边缘或者其他标注的边缘，
+// 附近都没有时再对齐到截图中检测到的边缘。exclude 是正在绘制的标注，不对齐到它自己。
+func (vp *ViewPort) snapPoint(p image.Point, exclude ImageFilter) image.Point {
+	if !vp.Snap {
+		return p
+	}
+	tolerance := int(math.Max(1, math.Round(snapTolerance*vp.zoom())))
+	crop := vp.fs.CropRect
+	xs, ys := []int{crop.Min.X, crop.Max.X}, []int{crop.Min.Y, crop.Max.Y}
+	if vp.ShowGuides {
+		for _, g := range vp.Guides {
+			if g.Vertical {
+				xs = append(xs, g.Pos)
+			} else {
+				ys = append(ys, g.Pos)
+			}
+		}
+	}
+	if vp.ShowGrid && vp.GridSpacing > 1 {
+		// 网格从裁剪区域的左上角开始
+		grid := func(v, origin int) int {
+			return origin + int(math.Round(float64(v-origin)/float64(vp.GridSpacing)))*vp.GridSpacing
+		}
+		xs = append(xs, grid(p.X, crop.Min.X))
+		ys = append(ys, grid(p.Y, crop.Min.Y))
+	}
+	for _, filter := range vp.fs.Filters {
+		if filter == exclude || filter == ImageFilter(vp.currentSelection) {
+			continue
+		}
+		fx, fy := filterEdges(filter)
+		xs, ys = append(xs, fx...), append(ys, fy...)
+	}
+
+	x, snappedX := nearest(p.X, xs, tolerance)
+	y, snappedY := nearest(p.Y, ys, tolerance)
+	if !snappedX || !snappedY {
+		edge := snapToEdge(vp.fs.OriginalScreenshot, p, vp.fs.snapRadius())
+		if !snappedX {
+			x = edge.X
+		}
+		if !snappedY {
+			y = edge.Y
+		}
+	}
+	return image.Point{X: x, Y: y}
+}
+
+// annotationPoint 鼠标位置对应的原始截图坐标，按照设置对齐，用于图形的端点
+func (vp *ViewPort) annotationPoint(pos fyne.Position, exclude ImageFilter) image.Point {
+	x, y := vp.screenshotPos(pos)
+	return vp.snapPoint(image.Point{X: x, Y: y}.Add(vp.fs.CropRect.Min), exclude)
+}
+
+// GridForm 设置网格、标尺和参考线以及对齐的方式
+func (gs *FireShotGO) GridForm() {
+	vp := gs.viewPort
+	gridCheck := widget.NewCheck("显示网格(放大 8 倍以上时显示像素网格)", nil)
+	gridCheck.SetChecked(vp.ShowGrid)
+	spacingEntry := widget.NewEntry()
+	spacingEntry.SetText(strconv.Itoa(vp.GridSpacing))
+	spacingEntry.Validator = func(s string) error {
+		if v, err := strconv.Atoi(s); err != nil || v < 1 {
+			return fmt.Errorf("网格间隔必须是正整数")
+		}
+		return nil
+	}
+	guidesCheck := widget.NewCheck("显示标尺和参考线(从标尺拖出参考线，拖回标尺删除)", nil)
+	guidesCheck.SetChecked(vp.ShowGuides)
+	snapCheck := widget.NewCheck("对齐到参考线、网格和其他标注", nil)
+	snapCheck.SetChecked(vp.Snap)
+	items := []*widget.FormItem{
+		widget.NewFormItem("网格", gridCheck),
+		widget.NewFormItem("网格间隔(像素)", spacingEntry),
+		widget.NewFormItem("参考线", guidesCheck),
+		widget.NewFormItem("对齐", snapCheck),
+	}
+	dialog.ShowForm("网格与对齐", "确认", "取消", items,
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			prefs := gs.App.Preferences()
+			vp.ShowGrid, vp.ShowGuides, vp.Snap = gridCheck.Checked, guidesCheck.Checked, snapCheck.Checked
+			prefs.SetBool(ShowGridPreference, vp.ShowGrid)
+			prefs.SetBool(ShowGuidesPreference, vp.ShowGuides)
+			prefs.SetBool(SnapPreference, vp.Snap)
+			if v, err := strconv.Atoi(spacingEntry.Text); err == nil {
+				vp.GridSpacing = v
+				prefs.SetInt(GridSpacingPreference, v)
+			}
+			vp.Refresh()
+		}, gs.Win)
+}
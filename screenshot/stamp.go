@@ -0,0 +1,96 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"gitee.com/andrewgithub/FireShotGo/filters"
+	"gitee.com/andrewgithub/FireShotGo/stamps"
+	"github.com/golang/glog"
+	"image"
+	"math"
+)
+
+const (
+	// StampPreference 上次使用的图章名称
+	StampPreference = "Stamp"
+	// StampsFolderPreference 用户图章所在的目录
+	StampsFolderPreference = "StampsFolder"
+
+	// stampDefaultSize 单击放置图章时图章的高度(截图像素)
+	stampDefaultSize = 64
+)
+
+// loadStamps 加载内置图章以及用户目录中的图章，并选中上次使用的图章
+func (vp *ViewPort) loadStamps() {
+	vp.Stamps = stamps.Builtin()
+	if dir := vp.fs.App.Preferences().String(StampsFolderPreference); dir != "" {
+		userStamps, err := stamps.LoadFolder(dir)
+		if err != nil {
+			glog.Errorf("Failed to load stamps from %q: %s", dir, err)
+		} else {
+			vp.Stamps = append(vp.Stamps, userStamps...)
+		}
+	}
+	if !vp.selectStamp(vp.fs.App.Preferences().String(StampPreference)) {
+		vp.selectStamp(vp.Stamps[0].Name)
+	}
+}
+
+// StampNames 返回所有图章的名称
+func (vp *ViewPort) StampNames() []string {
+	names := make([]string, 0, len(vp.Stamps))
+	for _, s := range vp.Stamps {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// selectStamp 按照名称选择之后放置的图章，没有找到返回 false
+func (vp *ViewPort) selectStamp(name string) bool {
+	for _, s := range vp.Stamps {
+		if s.Name == name {
+			vp.Stamp = s
+			vp.cursorDrawStamp = canvas.NewImageFromImage(s.Render(64, 64))
+			vp.fs.App.Preferences().SetString(StampPreference, name)
+			return true
+		}
+	}
+	return false
+}
+
+// placeStamp 单击时以 center 为中心放置默认大小的图章
+func (vp *ViewPort) placeStamp(center image.Point) {
+	h := stampDefaultSize
+	w := int(math.Round(float64(h) * vp.Stamp.AspectRatio()))
+	rect := image.Rect(center.X-w/2, center.Y-h/2, center.X-w/2+w, center.Y-h/2+h)
+	vp.fs.Filters = append(vp.fs.Filters, filters.NewStamp(vp.Stamp, rect))
+	vp.fs.ApplyFilters(true)
+	vp.fs.status.SetText(fmt.Sprintf("Stamp %q placed, use Control+Z to undo.", vp.Stamp.Name))
+}
+
+// dragStamp 拖动时从起点开始按照图章的宽高比缩放
+func (vp *ViewPort) dragStamp(toPos fyne.Position) {
+	if vp.currentStamp == nil {
+		glog.Errorf("dragStamp(): dragStamp event, but none has been started yet!?")
+		return
+	}
+	start := vp.annotationPoint(vp.dragStart, vp.currentStamp)
+	to := vp.annotationPoint(toPos, vp.currentStamp)
+	startX, startY, toX, toY := start.X, start.Y, to.X, to.Y
+
+	// 宽高取较大的一边，另一边按照宽高比计算
+	w, h := float64(toX-startX), float64(toY-startY)
+	aspect := vp.Stamp.AspectRatio()
+	if math.Abs(w) > math.Abs(h)*aspect {
+		h = math.Copysign(math.Abs(w)/aspect, h)
+	} else {
+		w = math.Copysign(math.Abs(h)*aspect, w)
+	}
+	end := image.Point{X: startX + int(math.Round(w)), Y: startY + int(math.Round(h))}
+	vp.currentStamp.SetRect(image.Rectangle{Min: image.Point{X: startX, Y: startY}, Max: end})
+	glog.V(2).Infof("dragStamp(): draw a stamp in %v", vp.currentStamp.Rect)
+	vp.fs.ApplyFilters(false)
+	vp.renderCache()
+	vp.Refresh()
+}
@@ -0,0 +1,236 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/validation"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"gitee.com/andrewgithub/FireShotGo/filters"
+	"gitee.com/andrewgithub/FireShotGo/resources"
+	"github.com/golang/glog"
+	"image"
+	"image/color"
+	"strconv"
+)
+
+// DoubleTapped implements fyne.DoubleTappable: 双击已有的文本或者插入的图片重新编辑
+func (vp *ViewPort) DoubleTapped(ev *fyne.PointEvent) {
+	screenshotX, screenshotY := vp.screenshotPos(ev.Position)
+	absolutePoint := image.Point{X: screenshotX, Y: screenshotY}.Add(vp.fs.CropRect.Min)
+	glog.V(2).Infof("DoubleTapped(pos=%+v): screenshot point %v", ev.Position, absolutePoint)
+
+	// 从最上层的标注开始查找
+	for ii := len(vp.fs.Filters) - 1; ii >= 0; ii-- {
+		switch filter := vp.fs.Filters[ii].(type) {
+		case *filters.Text:
+			if absolutePoint.In(filter.Bounds()) {
+				vp.SetOp(NoOp)
+				vp.editTextFilter(filter, false)
+				return
+			}
+		case *filters.ImageOverlay:
+			if absolutePoint.In(filter.Rect) {
+				vp.SetOp(NoOp)
+				vp.editImageOverlay(filter, false)
+				return
+			}
+		}
+	}
+}
+
+// createTextFilter 在 center 处插入新的文本
+func (vp *ViewPort) createTextFilter(center image.Point) {
+	text := filters.NewText("", center, vp.DrawingColor, vp.BackgroundColor, vp.FontSize)
+	text.SetStyle(vp.TextStyle)
+	vp.fs.Filters = append(vp.fs.Filters, text)
+	vp.editTextFilter(text, true)
+}
+
+// removeFilter 从标注列表中删除 filter
+func (vp *ViewPort) removeFilter(filter ImageFilter) {
+	for ii, f := range vp.fs.Filters {
+		if f == filter {
+			vp.fs.Filters = append(vp.fs.Filters[:ii], vp.fs.Filters[ii+1:]...)
+			return
+		}
+	}
+}
+
+// editTextFilter 编辑文本标注的内容、字号、颜色、样式以及位置。
+// 修改会实时显示在截图上，确认之后保留修改，取消之后恢复原来的文本(新建的文本会被删除)。
+func (vp *ViewPort) editTextFilter(text *filters.Text, isNew bool) {
+	original := *text
+	var form dialog.Dialog
+
+	textEntry := widget.NewMultiLineEntry()
+	textEntry.SetText(text.Text)
+	textEntry.Resize(fyne.NewSize(400, 80))
+	fontSize := widget.NewEntry()
+	fontSize.SetText(fmt.Sprintf("%g", text.Size))
+	fontSize.Validator = validation.NewRegexp(`\d`, "Must contain a number")
+	centerX := widget.NewEntry()
+	centerX.SetText(strconv.Itoa(text.Center.X))
+	centerX.Validator = validation.NewRegexp(`^-?\d+$`, "Must be an integer")
+	centerY := widget.NewEntry()
+	centerY.SetText(strconv.Itoa(text.Center.Y))
+	centerY.Validator = validation.NewRegexp(`^-?\d+$`, "Must be an integer")
+
+	// 编辑中的值，每次修改之后都会重新渲染文本
+	textColor, background, style := text.Color, text.Background, text.Style
+	outlineColor := style.OutlineColor
+	if outlineColor == nil {
+		outlineColor = Transparent
+	}
+	lineSpacing := widget.NewEntry()
+	lineSpacing.SetText(fmt.Sprintf("%g", style.LineSpacing))
+	lineSpacing.Validator = validation.NewRegexp(`\d`, "Must contain a number")
+	maxWidth := widget.NewEntry()
+	maxWidth.SetText(strconv.Itoa(style.MaxWidth))
+	maxWidth.Validator = validation.NewRegexp(`^\d+$`, "Must be a number, 0 disables wrapping")
+	outlineWidth := widget.NewEntry()
+	outlineWidth.SetText(fmt.Sprintf("%g", style.OutlineWidth))
+	outlineWidth.Validator = validation.NewRegexp(`\d`, "Must contain a number")
+
+	preview := func() {
+		fSize, err := strconv.ParseFloat(fontSize.Text, 64)
+		if err != nil || fSize <= 0 {
+			return
+		}
+		x, errX := strconv.Atoi(centerX.Text)
+		y, errY := strconv.Atoi(centerY.Text)
+		if errX != nil || errY != nil {
+			return
+		}
+		if v, err := strconv.ParseFloat(lineSpacing.Text, 64); err == nil {
+			style.LineSpacing = v
+		}
+		if v, err := strconv.Atoi(maxWidth.Text); err == nil {
+			style.MaxWidth = v
+		}
+		if v, err := strconv.ParseFloat(outlineWidth.Text, 64); err == nil {
+			style.OutlineWidth = v
+		}
+		style.OutlineColor = outlineColor
+		text.Text = textEntry.Text
+		text.Size = fSize
+		text.Color, text.Background = textColor, background
+		text.Center = image.Point{X: x, Y: y}
+		text.SetStyle(style)
+		vp.fs.ApplyFilters(true)
+	}
+
+	// 颜色选择: 按钮 + 清除 + 示例
+	colorRow := func(c *color.Color, title string) *fyne.Container {
+		sample := canvas.NewRectangle(*c)
+		sample.SetMinSize(fyne.NewSize(100, 20))
+		set := func(nc color.Color) {
+			*c = nc
+			sample.FillColor = nc
+			sample.Refresh()
+			preview()
+		}
+		return container.NewHBox(
+			widget.NewButtonWithIcon("", resources.ColorWheel, func() {
+				dialog.NewColorPicker("Pick a Color", title, set, vp.fs.Win).Show()
+			}),
+			widget.NewButtonWithIcon("", resources.Reset, func() { set(Transparent) }),
+			sample,
+		)
+	}
+	colorEntry := colorRow(&textColor, "Select color for text")
+	backgroundEntry := colorRow(&background, "Select background color for text")
+	outlineEntry := container.NewHBox(colorRow(&outlineColor, "Select outline color for text"), outlineWidth)
+
+	// 字体、粗斜体和对齐方式
+	fontSelect := widget.NewSelect(filters.FontNames(), nil)
+	if style.Font == "" {
+		fontSelect.SetSelected(filters.DefaultFontName)
+	} else {
+		fontSelect.SetSelected(style.Font)
+	}
+	fontSelect.OnChanged = func(name string) { style.Font = name; preview() }
+	boldCheck := widget.NewCheck("粗体", nil)
+	boldCheck.SetChecked(style.Bold)
+	boldCheck.OnChanged = func(on bool) { style.Bold = on; preview() }
+	italicCheck := widget.NewCheck("斜体", nil)
+	italicCheck.SetChecked(style.Italic)
+	italicCheck.OnChanged = func(on bool) { style.Italic = on; preview() }
+	alignRadio := widget.NewRadioGroup(filters.TextAlignNames, nil)
+	alignRadio.Horizontal = true
+	alignRadio.SetSelected(style.Align.String())
+	alignRadio.OnChanged = func(name string) { style.Align = filters.ParseTextAlign(name); preview() }
+
+	for _, e := range []*widget.Entry{textEntry, fontSize, centerX, centerY, lineSpacing, maxWidth, outlineWidth} {
+		e.OnChanged = func(string) { preview() }
+	}
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Text", textEntry),
+		widget.NewFormItem("Font size", fontSize),
+		widget.NewFormItem("Font", container.NewHBox(fontSelect, boldCheck, italicCheck)),
+		widget.NewFormItem("Align", alignRadio),
+		widget.NewFormItem("Line spacing", lineSpacing),
+		widget.NewFormItem("Max width", maxWidth),
+		widget.NewFormItem("Color", colorEntry),
+		widget.NewFormItem("Outline", outlineEntry),
+		widget.NewFormItem("Background", backgroundEntry),
+		widget.NewFormItem("Center", container.NewGridWithColumns(2, centerX, centerY)),
+	}
+	title := "Edit text"
+	if isNew {
+		title = "Insert text"
+	}
+	form = dialog.NewForm(title, "Ok", "Cancel", items,
+		func(confirm bool) {
+			if !confirm {
+				// 恢复修改之前的文本
+				if isNew {
+					vp.removeFilter(text)
+				} else {
+					*text = original
+				}
+				vp.fs.ApplyFilters(true)
+				vp.fs.status.SetText("Text edition cancelled.")
+				return
+			}
+			preview()
+			if isNew && text.Text == "" {
+				vp.removeFilter(text)
+				vp.fs.ApplyFilters(true)
+				return
+			}
+			// 记录本次使用的设置，作为下一次插入文本的默认值
+			vp.FontSize = text.Size
+			vp.fs.App.Preferences().SetFloat(FontSizePreference, text.Size)
+			vp.BackgroundColor = text.Background
+			vp.fs.SetColorPreference(BackgroundColorPreference, text.Background)
+			vp.setTextStyle(text.Style)
+			if isNew {
+				vp.fs.status.SetText("Text drawn, use Control+Z to undo, double-click to edit.")
+			} else {
+				vp.fs.status.SetText("Text updated.")
+			}
+		}, vp.fs.Win)
+	form.Resize(fyne.NewSize(500, 300))
+	form.Show()
+	vp.fs.Win.Canvas().Focus(textEntry)
+}
+
+// setTextStyle 记录文本样式，下次插入文本时作为默认值
+func (vp *ViewPort) setTextStyle(style filters.TextStyle) {
+	vp.TextStyle = style
+	prefs := vp.fs.App.Preferences()
+	prefs.SetString(TextFontPreference, style.Font)
+	prefs.SetBool(TextBoldPreference, style.Bold)
+	prefs.SetBool(TextItalicPreference, style.Italic)
+	prefs.SetInt(TextAlignPreference, int(style.Align))
+	prefs.SetFloat(TextLineSpacingPreference, style.LineSpacing)
+	prefs.SetInt(TextMaxWidthPreference, style.MaxWidth)
+	prefs.SetFloat(TextOutlineWidthPreference, style.OutlineWidth)
+	if style.OutlineColor != nil {
+		vp.fs.SetColorPreference(TextOutlineColorPreference, style.OutlineColor)
+	}
+}
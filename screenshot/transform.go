@@ -0,0 +1,278 @@
+package screenshot
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+)
+
+// orientation 旋转或者翻转整张截图
+type orientation int
+
+const (
+	rotateClockwise orientation = iota
+	rotateCounterClockwise
+	flipHorizontal
+	flipVertical
+)
+
+// String 状态栏中显示的名称
+func (o orientation) String() string {
+	switch o {
+	case rotateClockwise:
+		return "Rotated 90° clockwise"
+	case rotateCounterClockwise:
+		return "Rotated 90° counter-clockwise"
+	case flipHorizontal:
+		return "Flipped horizontally"
+	default:
+		return "Flipped vertically"
+	}
+}
+
+// size 变换之后的宽和高
+func (o orientation) size(w, h int) (int, int) {
+	if o == rotateClockwise || o == rotateCounterClockwise {
+		return h, w
+	}
+	return w, h
+}
+
+// point 像素 (x, y) 变换之后的位置，w 和 h 是变换之前的大小，坐标从 0 开始
+func (o orientation) point(x, y, w, h int) image.Point {
+	switch o {
+	case rotateClockwise:
+		return image.Point{X: h - 1 - y, Y: x}
+	case rotateCounterClockwise:
+		return image.Point{X: y, Y: w - 1 - x}
+	case flipHorizontal:
+		return image.Point{X: w - 1 - x, Y: y}
+	default:
+		return image.Point{X: x, Y: h - 1 - y}
+	}
+}
+
+// rect 区域 r 变换之后的位置
+func (o orientation) rect(r image.Rectangle, w, h int) image.Rectangle {
+	if r.Empty() {
+		return image.Rectangle{}
+	}
+	a := o.point(r.Min.X, r.Min.Y, w, h)
+	b := o.point(r.Max.X-1, r.Max.Y-1, w, h)
+	r = image.Rectangle{Min: a, Max: b}.Canon()
+	r.Max = r.Max.Add(image.Point{X: 1, Y: 1})
+	return r
+}
+
+// guide 参考线变换之后的方向和位置，参考线在像素的边界上
+func (o orientation) guide(g Guide, w, h int) Guide {
+	switch {
+	case o == rotateClockwise && g.Vertical:
+		return Guide{Vertical: false, Pos: g.Pos}
+	case o == rotateClockwise:
+		return Guide{Vertical: true, Pos: h - g.Pos}
+	case o == rotateCounterClockwise && g.Vertical:
+		return Guide{Vertical: false, Pos: w - g.Pos}
+	case o == rotateCounterClockwise:
+		return Guide{Vertical: true, Pos: g.Pos}
+	case o == flipHorizontal && g.Vertical:
+		return Guide{Vertical: true, Pos: w - g.Pos}
+	case o == flipVertical && !g.Vertical:
+		return Guide{Vertical: false, Pos: h - g.Pos}
+	}
+	return g
+}
+
+// transformImage 返回 img 旋转或者翻转之后的图片，坐标从 0 开始
+func transformImage(img *image.RGBA, o orientation) *image.RGBA {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	tw, th := o.size(w, h)
+	out := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			src := img.PixOffset(x+img.Rect.Min.X, y+img.Rect.Min.Y)
+			p := o.point(x, y, w, h)
+			dst := out.PixOffset(p.X, p.Y)
+			copy(out.Pix[dst:dst+4], img.Pix[src:src+4])
+		}
+	}
+	return out
+}
+
+// geometryEdit 旋转、翻转或者修改画布大小之前的状态，用于撤销
+type geometryEdit struct {
+	original *image.RGBA
+	crop     image.Rectangle
+	filters  []ImageFilter
+	guides   []Guide
+}
+
+// pushGeometryEdit 保存当前的状态，之后可以使用 Control+Z 撤销
+func (gs *FireShotGO) pushGeometryEdit() {
+	gs.geometryEdits = append(gs.geometryEdits, geometryEdit{
+		original: gs.OriginalScreenshot,
+		crop:     gs.CropRect,
+		filters:  gs.Filters,
+		guides:   append([]Guide(nil), gs.viewPort.Guides...),
+	})
+}
+
+// undoGeometryEdit 恢复最后一次旋转、翻转或者修改画布大小之前的截图和标注，没有时返回 false
+func (gs *FireShotGO) undoGeometryEdit() bool {
+	if len(gs.geometryEdits) == 0 {
+		return false
+	}
+	edit := gs.geometryEdits[len(gs.geometryEdits)-1]
+	gs.geometryEdits = gs.geometryEdits[:len(gs.geometryEdits)-1]
+	gs.replaceOriginal(edit.original, edit.crop, edit.filters, edit.guides)
+	gs.status.SetText("Undone the last rotation, flip or canvas resize.")
+	return true
+}
+
+// flattened 返回合成了所有标注的完整截图(不只是裁剪区域)的副本
+func (gs *FireShotGO) flattened() *image.RGBA {
+	gs.ApplyFilters(false)
+	composed := gs.compositor.Image()
+	out := image.NewRGBA(composed.Rect)
+	copy(out.Pix, composed.Pix)
+	return out
+}
+
+// replaceOriginal 替换原始截图、裁剪区域、标注以及参考线，并刷新视图和缩略图
+func (gs *FireShotGO) replaceOriginal(original *image.RGBA, crop image.Rectangle, list []ImageFilter, guides []Guide) {
+	vp := gs.viewPort
+	gs.OriginalScreenshot = original
+	gs.CropRect = crop
+	gs.Filters = list
+	vp.Guides = guides
+	vp.SetOp(NoOp)
+	vp.viewX, vp.viewY = 0, 0
+	gs.ApplyFilters(true)
+	vp.postCrop()
+}
+
+// Transform 旋转或者翻转整张截图。标注会合并到截图中，可以使用 Control+Z 撤销。
+func (gs *FireShotGO) Transform(o orientation) {
+	original := gs.flattened()
+	gs.pushGeometryEdit()
+	w, h := original.Rect.Dx(), original.Rect.Dy()
+	crop := o.rect(gs.CropRect.Sub(original.Rect.Min), w, h)
+	guides := make([]Guide, 0, len(gs.viewPort.Guides))
+	for _, g := range gs.viewPort.Guides {
+		if g.Vertical {
+			g.Pos -= original.Rect.Min.X
+		} else {
+			g.Pos -= original.Rect.Min.Y
+		}
+		guides = append(guides, o.guide(g, w, h))
+	}
+	gs.replaceOriginal(transformImage(original, o), crop, nil, guides)
+	gs.status.SetText(fmt.Sprintf("%s, %d x %d pixels, use Control+Z to undo.", o, crop.Dx(), crop.Dy()))
+}
+
+// 修改画布大小时，截图在新画布中的位置
+var (
+	canvasAnchorsX = []string{"左", "中", "右"}
+	canvasAnchorsY = []string{"上", "中", "下"}
+)
+
+// anchorOffset 按照 anchor 在 canvasAnchors 中的位置，将大小为 size 的截图放到 canvas 中的偏移
+func anchorOffset(anchors []string, anchor string, size, canvas int) int {
+	switch anchor {
+	case anchors[1]:
+		return (canvas - size) / 2
+	case anchors[2]:
+		return canvas - size
+	}
+	return 0
+}
+
+// ResizeCanvasForm 修改画布的大小: 按照锚点扩展(使用填充颜色)或者裁掉当前的截图，
+// 标注会合并到截图中，可以使用 Control+Z 撤销。
+func (gs *FireShotGO) ResizeCanvasForm() {
+	vp := gs.viewPort
+	crop := gs.CropRect
+	positive := func(s string) error {
+		if v, err := strconv.Atoi(s); err != nil || v < 1 || v > 1<<15 {
+			return fmt.Errorf("范围为 1 到 %d 像素", 1<<15)
+		}
+		return nil
+	}
+	widthEntry := widget.NewEntry()
+	widthEntry.SetText(strconv.Itoa(crop.Dx()))
+	widthEntry.Validator = positive
+	heightEntry := widget.NewEntry()
+	heightEntry.SetText(strconv.Itoa(crop.Dy()))
+	heightEntry.Validator = positive
+	anchorX := widget.NewRadioGroup(canvasAnchorsX, nil)
+	anchorX.Horizontal = true
+	anchorX.SetSelected(canvasAnchorsX[1])
+	anchorY := widget.NewRadioGroup(canvasAnchorsY, nil)
+	anchorY.Horizontal = true
+	anchorY.SetSelected(canvasAnchorsY[1])
+	fillOptions := []string{"背景颜色", "绘图颜色", "透明"}
+	fillRadio := widget.NewRadioGroup(fillOptions, nil)
+	fillRadio.Horizontal = true
+	fillRadio.SetSelected(fillOptions[0])
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("当前大小", widget.NewLabel(fmt.Sprintf("%d x %d", crop.Dx(), crop.Dy()))),
+		widget.NewFormItem("宽度(像素)", widthEntry),
+		widget.NewFormItem("高度(像素)", heightEntry),
+		widget.NewFormItem("水平位置", anchorX),
+		widget.NewFormItem("垂直位置", anchorY),
+		widget.NewFormItem("填充", fillRadio),
+	}
+	dialog.ShowForm("画布大小", "确认", "取消", items,
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			if positive(widthEntry.Text) != nil || positive(heightEntry.Text) != nil {
+				gs.status.SetText("Invalid canvas size.")
+				return
+			}
+			w, _ := strconv.Atoi(widthEntry.Text)
+			h, _ := strconv.Atoi(heightEntry.Text)
+			var fill color.Color = Transparent
+			switch fillRadio.Selected {
+			case fillOptions[0]:
+				fill = vp.BackgroundColor
+			case fillOptions[1]:
+				fill = vp.DrawingColor
+			}
+			offset := image.Point{
+				X: anchorOffset(canvasAnchorsX, anchorX.Selected, crop.Dx(), w),
+				Y: anchorOffset(canvasAnchorsY, anchorY.Selected, crop.Dy(), h),
+			}
+			gs.resizeCanvas(w, h, offset, fill)
+		}, gs.Win)
+}
+
+// resizeCanvas 创建 w x h 的画布，使用 fill 填充，并将当前的截图(裁剪区域，包括标注)放在 offset 的位置
+func (gs *FireShotGO) resizeCanvas(w, h int, offset image.Point, fill color.Color) {
+	composed := gs.flattened()
+	gs.pushGeometryEdit()
+	canvas := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(canvas, canvas.Rect, image.NewUniform(fill), image.Point{}, draw.Src)
+	target := image.Rectangle{Min: offset, Max: offset.Add(gs.CropRect.Size())}
+	draw.Draw(canvas, target, composed, gs.CropRect.Min, draw.Over)
+
+	// 参考线跟着截图移动
+	shift := offset.Sub(gs.CropRect.Min)
+	guides := make([]Guide, 0, len(gs.viewPort.Guides))
+	for _, g := range gs.viewPort.Guides {
+		if g.Vertical {
+			g.Pos += shift.X
+		} else {
+			g.Pos += shift.Y
+		}
+		guides = append(guides, g)
+	}
+	gs.replaceOriginal(canvas, canvas.Rect, nil, guides)
+	gs.status.SetText(fmt.Sprintf("Canvas resized to %d x %d pixels, use Control+Z to undo.", w, h))
+}
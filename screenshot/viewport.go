@@ -4,18 +4,15 @@ import (
 	"fmt"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
-	"fyne.io/fyne/v2/container"
-	"fyne.io/fyne/v2/data/validation"
-	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/widget"
 	"gitee.com/andrewgithub/FireShotGo/filters"
 	"gitee.com/andrewgithub/FireShotGo/resources"
+	"gitee.com/andrewgithub/FireShotGo/stamps"
 	"github.com/golang/glog"
 	"image"
 	"image/color"
 	"math"
-	"strconv"
 )
 
 // ViewPort is our view port for the image being edited. It's a specialized widget
@@ -45,9 +42,48 @@ type ViewPort struct {
 	// FontSize 字体的大小
 	FontSize float64
 
+	// TextStyle 文本的字体、粗斜体、对齐、行距、描边以及自动换行
+	TextStyle filters.TextStyle
+
 	// 虚线间隔配置
 	DottedLineSpacing float64
 
+	// LineCaps 箭头、直线、虚线两端的样式
+	LineCaps filters.LineCaps
+
+	// FillColor 圆和矩形的填充颜色，透明表示不填充
+	FillColor color.Color
+
+	// StrokeOpacity, FillOpacity 轮廓和填充的不透明度 [0, 1]
+	StrokeOpacity, FillOpacity float64
+
+	// CornerRadius 矩形的圆角半径
+	CornerRadius float64
+
+	// DashStyle 图形轮廓的虚线样式
+	DashStyle filters.DashStyle
+
+	// AntiAlias 新建的图形是否对边缘做抗锯齿，创建之后保存在每个图形中
+	AntiAlias bool
+
+	// Stamps 可用的图章，Stamp 是当前选中的图章
+	Stamps []*stamps.Stamp
+	Stamp  *stamps.Stamp
+
+	// ShowGrid 显示网格，GridSpacing 是主网格线的间隔(像素)
+	ShowGrid    bool
+	GridSpacing int
+
+	// ShowGuides 显示标尺和参考线，Guides 是从标尺拖出的参考线
+	ShowGuides bool
+	Guides     []Guide
+
+	// Snap 图形的端点和裁剪边缘对齐到参考线、网格和其他标注
+	Snap bool
+
+	// CropAspect 拖动裁剪时锁定的宽高比，例如 "16:9"，为空时不锁定
+	CropAspect string
+
 	// Are of the screenshot that is visible in the current window: these are the start (viewX, viewY)
 	// and sizes in fs.screenshot pixels -- each may be zoomed in/out when displaying.
 	viewX, viewY, viewW, viewH int
@@ -70,6 +106,9 @@ type ViewPort struct {
 	cursorShieldBlock   *canvas.Image
 	cursorDrawRectangle *canvas.Image
 	cursorDrawPen       *canvas.Image
+	cursorDrawStamp     *canvas.Image
+	// 取色时跟随鼠标的放大镜
+	cursorEyedropper *canvas.Image
 
 	// 鼠标是否在视图窗口上
 	mouseIn bool
@@ -95,6 +134,23 @@ type ViewPort struct {
 	currentShieldBlock  *filters.ShieldBlock  // 开始绘制矩形遮挡块
 	currentRectangle    *filters.Rectangle    // 开始绘制矩形
 	currentPen          *filters.Pen          // 开始使用画笔进行绘制
+	currentStamp        *filters.Stamp        // 开始拖动放置图章
+	currentSelection    *filters.Rectangle    // 拖动选择区域时显示的选择框，结束之后移除
+	currentDimension    *filters.Dimension    // 测量或者绘制尺寸标注线，测量结束之后移除
+
+	// 拖动插入的图片，movingOverlayFrom 是开始拖动时图片左上角的位置
+	movingOverlay     *filters.ImageOverlay
+	movingOverlayFrom image.Point
+
+	// 正在拖动的参考线，movingGuideOnRuler 表示参考线被拖回了标尺上，放开时删除
+	movingGuide        *Guide
+	movingGuideOnRuler bool
+
+	// cropSelection 拖动裁剪时选择的区域(原始截图的坐标)，cropDragFrom 和 cropDragRect 是开始拖动时
+	// 鼠标的位置和选择的区域，cropDragEdges 是正在拖动的边
+	cropSelection, cropDragRect image.Rectangle
+	cropDragFrom                image.Point
+	cropDragEdges               cropEdge
 
 	fyne.ShortcutHandler
 }
@@ -108,6 +164,8 @@ const (
 	CropTopLeft
 	// CropBottomRight 裁剪点 - 右下角
 	CropBottomRight
+	// CropDrag 拖动选择裁剪的区域，可以拖动控制点调整以及锁定宽高比
+	CropDrag
 	// DrawCircle 绘制圆 包括椭圆和圆
 	DrawCircle
 	// DrawArrow 绘制剪头
@@ -124,6 +182,20 @@ const (
 	DrawRectangle
 	// DrawPen 使用画笔进行绘制
 	DrawPen
+	// DrawStamp 放置图章
+	DrawStamp
+	// SelectText 选择区域识别文字并复制，单击时识别整张截图
+	SelectText
+	// SelectCodes 选择区域查找二维码和条形码，单击时查找整张截图
+	SelectCodes
+	// SelectAdjust 选择区域调整亮度、对比度等，单击时调整整张截图
+	SelectAdjust
+	// PickColor 取色: 显示鼠标周围像素的放大镜，单击时复制颜色
+	PickColor
+	// Measure 测量两点之间的距离和角度，结束之后不保留标注
+	Measure
+	// DrawDimension 绘制尺寸标注线
+	DrawDimension
 )
 
 // Ensure ViewPort implements the following interfaces.
@@ -132,6 +204,7 @@ var (
 	_             = fyne.CanvasObject(vpPlaceholder)
 	_             = fyne.Draggable(vpPlaceholder)
 	_             = fyne.Tappable(vpPlaceholder)
+	_             = fyne.DoubleTappable(vpPlaceholder)
 	_             = desktop.Hoverable(vpPlaceholder)
 )
 
@@ -158,6 +231,7 @@ func NewViewPort(gs *FireShotGO) (vp *ViewPort) {
 		cursorShieldBlock:     canvas.NewImageFromResource(resources.DrawShieldBlock),
 		cursorDrawRectangle:   canvas.NewImageFromResource(resources.DrawRectangle),
 		cursorDrawPen:         canvas.NewImageFromResource(resources.DrawPen),
+		cursorEyedropper:      newEyedropperCursor(),
 		// 记录鼠标位置信息
 		mouseMoveEvents: make(chan fyne.Position, 1000),
 
@@ -168,7 +242,39 @@ func NewViewPort(gs *FireShotGO) (vp *ViewPort) {
 		// 绘制的颜色
 		DrawingColor:    gs.GetColorPreference(DrawingColorPreference, Red),
 		BackgroundColor: gs.GetColorPreference(BackgroundColorPreference, Transparent),
+		// 线条两端的样式
+		LineCaps: filters.LineCaps{
+			Tail: filters.LineCap(gs.App.Preferences().Int(LineTailPreference)),
+			Head: filters.LineCap(gs.App.Preferences().Int(LineHeadPreference)),
+			Size: prefOrFloat(LineCapSizePreference, 1.0),
+		},
+		// 图形的填充、不透明度、圆角以及轮廓样式
+		FillColor:     gs.GetColorPreference(FillColorPreference, Transparent),
+		StrokeOpacity: prefOrFloat(StrokeOpacityPreference, 1.0),
+		FillOpacity:   prefOrFloat(FillOpacityPreference, 1.0),
+		CornerRadius:  gs.App.Preferences().Float(CornerRadiusPreference),
+		DashStyle:     filters.DashStyle(gs.App.Preferences().Int(DashStylePreference)),
+		// 文本样式
+		TextStyle: filters.TextStyle{
+			Font:         gs.App.Preferences().String(TextFontPreference),
+			Bold:         gs.App.Preferences().Bool(TextBoldPreference),
+			Italic:       gs.App.Preferences().Bool(TextItalicPreference),
+			Align:        filters.TextAlign(gs.App.Preferences().Int(TextAlignPreference)),
+			LineSpacing:  prefOrFloat(TextLineSpacingPreference, 1.0),
+			OutlineColor: gs.GetColorPreference(TextOutlineColorPreference, Transparent),
+			OutlineWidth: gs.App.Preferences().Float(TextOutlineWidthPreference),
+			MaxWidth:     gs.App.Preferences().Int(TextMaxWidthPreference),
+		},
+		// 网格、参考线以及对齐
+		ShowGrid:    gs.App.Preferences().Bool(ShowGridPreference),
+		GridSpacing: gs.App.Preferences().IntWithFallback(GridSpacingPreference, 10),
+		ShowGuides:  gs.App.Preferences().Bool(ShowGuidesPreference),
+		Snap:        gs.App.Preferences().BoolWithFallback(SnapPreference, true),
+		// 拖动裁剪
+		CropAspect: gs.App.Preferences().String(CropAspectPreference),
 	}
+	vp.loadStamps()
+	vp.AntiAlias = gs.App.Preferences().BoolWithFallback(AntiAliasPreference, true)
 	go vp.consumeMouseMoveEvents()
 	vp.raster = canvas.NewRaster(vp.draw)
 	return
@@ -179,8 +285,45 @@ const (
 	DrawingColorPreference    = "DrawingColor"
 	FontSizePreference        = "FontSize"
 	ThicknessPreference       = "Thickness"
+	LineTailPreference        = "LineTail"
+	LineHeadPreference        = "LineHead"
+	LineCapSizePreference     = "LineCapSize"
+	FillColorPreference       = "FillColor"
+	StrokeOpacityPreference   = "StrokeOpacity"
+	FillOpacityPreference     = "FillOpacity"
+	CornerRadiusPreference    = "CornerRadius"
+	DashStylePreference       = "DashStyle"
+	AntiAliasPreference       = "AntiAlias"
+
+	TextFontPreference         = "TextFont"
+	TextBoldPreference         = "TextBold"
+	TextItalicPreference       = "TextItalic"
+	TextAlignPreference        = "TextAlign"
+	TextLineSpacingPreference  = "TextLineSpacing"
+	TextOutlineColorPreference = "TextOutlineColor"
+	TextOutlineWidthPreference = "TextOutlineWidth"
+	TextMaxWidthPreference     = "TextMaxWidth"
 )
 
+// shapeStyle 根据当前的设置生成图形的绘制样式
+func (vp *ViewPort) shapeStyle() filters.ShapeStyle {
+	return filters.ShapeStyle{
+		Fill:          vp.FillColor,
+		StrokeOpacity: vp.StrokeOpacity,
+		FillOpacity:   vp.FillOpacity,
+		Dash:          vp.DashStyle,
+	}
+}
+
+// arrowCaps 返回绘制箭头时使用的端点样式，箭头工具终点至少要有一个实心三角形
+func (vp *ViewPort) arrowCaps() filters.LineCaps {
+	caps := vp.LineCaps
+	if caps.Head == filters.CapNone {
+		caps.Head = filters.CapTriangle
+	}
+	return caps
+}
+
 func (vp *ViewPort) Resize(size fyne.Size) {
 	glog.V(2).Infof("Resize(size={w=%g, h=%g})", size.Width, size.Height)
 	vp.BaseWidget.Resize(size)
@@ -319,26 +462,30 @@ func (vp *ViewPort) renderCache() {
 	imgW, imgH := wh(img)
 	zoom := vp.zoom()
 
-	var c color.RGBA
 	glog.V(2).Infof("renderCache(): cache=(w=%d, h=%d, bytes=%d), zoom=%g, viewX=%d, viewY=%d, viewW=%d, viewH=%d",
 		w, h, len(vp.cache.Pix), zoom, vp.viewX, vp.viewY, vp.viewW, vp.viewH)
-	for y := 0; y < h; y++ {
-		for x := 0; x < w; x++ {
-			pos := (y*w + x) * bytesPerPixel
-			imgX := int(math.Round(float64(x)*zoom)) + vp.viewX
-			imgY := int(math.Round(float64(y)*zoom)) + vp.viewY
-			if imgX < 0 || imgX >= imgW || imgY < 0 || imgY >= imgH {
-				// Background image.
-				c = bgPattern(x, y)
-			} else {
-				c = img.RGBAAt(imgX, imgY)
+	// 按照分块并行渲染
+	filters.Parallel(filters.Tiles(vp.cache.Rect), func(tile image.Rectangle) {
+		var c color.RGBA
+		for y := tile.Min.Y; y < tile.Max.Y; y++ {
+			for x := tile.Min.X; x < tile.Max.X; x++ {
+				pos := (y*w + x) * bytesPerPixel
+				imgX := int(math.Round(float64(x)*zoom)) + vp.viewX
+				imgY := int(math.Round(float64(y)*zoom)) + vp.viewY
+				if imgX < 0 || imgX >= imgW || imgY < 0 || imgY >= imgH {
+					// Background image.
+					c = bgPattern(x, y)
+				} else {
+					c = img.RGBAAt(imgX, imgY)
+				}
+				vp.cache.Pix[pos] = c.R
+				vp.cache.Pix[pos+1] = c.G
+				vp.cache.Pix[pos+2] = c.B
+				vp.cache.Pix[pos+3] = c.A
 			}
-			vp.cache.Pix[pos] = c.R
-			vp.cache.Pix[pos+1] = c.G
-			vp.cache.Pix[pos+2] = c.B
-			vp.cache.Pix[pos+3] = c.A
 		}
-	}
+	})
+	vp.renderOverlays()
 }
 
 var (
@@ -368,20 +515,37 @@ func (vp *ViewPort) Dragged(ev *fyne.DragEvent) {
 		vp.dragStartViewX = vp.viewX
 		vp.dragStartViewY = vp.viewY
 		go vp.consumeDragEvents()
+		if vp.startGuideDrag(vp.dragStart) {
+			return
+		}
 
 		startX, startY := vp.screenshotPos(vp.dragStart)
 		startX += vp.fs.CropRect.Min.X
 		startY += vp.fs.CropRect.Min.Y
+		switch vp.currentOperation {
+		case DrawCircle, DrawArrow, DrawStraightLine, DrawDottedLine, DrawShieldBlock, DrawRectangle, DrawStamp:
+			start := vp.snapPoint(image.Point{X: startX, Y: startY}, nil)
+			startX, startY = start.X, start.Y
+		}
 
 		switch vp.currentOperation {
-		case NoOp, CropTopLeft, CropBottomRight, DrawText:
+		case NoOp:
+			// 在插入的图片上开始拖动就移动图片，否则拖动视图
+			if vp.movingOverlay = vp.overlayAt(image.Point{X: startX, Y: startY}); vp.movingOverlay != nil {
+				vp.movingOverlayFrom = vp.movingOverlay.Rect.Min
+			}
+		case CropTopLeft, CropBottomRight, DrawText:
 			// Drag the image around, nothing to do to start.
+		case CropDrag:
+			vp.startCropDrag(vp.dragStart)
 		case DrawCircle:
 			glog.V(2).Infof("Tapped(): draw a circle starting at (%d, %d)", startX, startY)
 			vp.currentCircle = filters.NewCircle(image.Rectangle{
 				Min: image.Point{X: startX, Y: startY},
 				Max: image.Point{X: startX + 5, Y: startY + 5},
 			}, vp.DrawingColor, vp.Thickness)
+			vp.currentCircle.SetStyle(vp.shapeStyle())
+			vp.currentCircle.SetAntiAlias(vp.AntiAlias)
 			vp.fs.Filters = append(vp.fs.Filters, vp.currentCircle)
 			vp.fs.ApplyFilters(false)
 		case DrawArrow:
@@ -390,6 +554,9 @@ func (vp *ViewPort) Dragged(ev *fyne.DragEvent) {
 				image.Point{X: startX, Y: startY},
 				image.Point{X: startX + 1, Y: startY + 1},
 				vp.DrawingColor, vp.Thickness)
+			vp.currentArrow.SetCaps(vp.arrowCaps())
+			vp.currentArrow.SetStyle(vp.shapeStyle())
+			vp.currentArrow.SetAntiAlias(vp.AntiAlias)
 			vp.fs.Filters = append(vp.fs.Filters, vp.currentArrow)
 			vp.fs.ApplyFilters(false)
 
@@ -399,6 +566,9 @@ func (vp *ViewPort) Dragged(ev *fyne.DragEvent) {
 				image.Point{X: startX, Y: startY},
 				image.Point{X: startX + 1, Y: startY + 1},
 				vp.DrawingColor, vp.Thickness)
+			vp.currentStraightLine.SetCaps(vp.LineCaps)
+			vp.currentStraightLine.SetStyle(vp.shapeStyle())
+			vp.currentStraightLine.SetAntiAlias(vp.AntiAlias)
 
 			vp.fs.Filters = append(vp.fs.Filters, vp.currentStraightLine)
 			vp.fs.ApplyFilters(false)
@@ -408,6 +578,8 @@ func (vp *ViewPort) Dragged(ev *fyne.DragEvent) {
 				image.Point{X: startX, Y: startY},
 				image.Point{X: startX + 1, Y: startY + 1},
 				vp.DrawingColor, vp.Thickness, vp.DottedLineSpacing)
+			vp.currentDottedLine.SetCaps(vp.LineCaps)
+			vp.currentDottedLine.SetAntiAlias(vp.AntiAlias)
 
 			vp.fs.Filters = append(vp.fs.Filters, vp.currentDottedLine)
 			vp.fs.ApplyFilters(false)
@@ -426,14 +598,29 @@ func (vp *ViewPort) Dragged(ev *fyne.DragEvent) {
 				Max: image.Point{X: startX + 5, Y: startY + 5},
 			}, vp.DrawingColor,
 				vp.Thickness)
+			vp.currentRectangle.SetStyle(vp.shapeStyle(), vp.CornerRadius)
+			vp.currentRectangle.SetAntiAlias(vp.AntiAlias)
 			vp.fs.Filters = append(vp.fs.Filters, vp.currentRectangle)
 			vp.fs.ApplyFilters(false)
 		case DrawPen:
 			glog.V(2).Infof("Tapped(): draw a line at (%d, %d)", startX, startY)
 			vp.currentPen = filters.NewPen(image.Point{startX, startY}, vp.DrawingColor,
 				vp.Thickness)
+			vp.currentPen.SetAntiAlias(vp.AntiAlias)
 			vp.fs.Filters = append(vp.fs.Filters, vp.currentPen)
 			vp.fs.ApplyFilters(false)
+		case DrawStamp:
+			glog.V(2).Infof("Tapped(): place a stamp starting at (%d, %d)", startX, startY)
+			vp.currentStamp = filters.NewStamp(vp.Stamp, image.Rectangle{
+				Min: image.Point{X: startX, Y: startY},
+				Max: image.Point{X: startX + 1, Y: startY + 1},
+			})
+			vp.fs.Filters = append(vp.fs.Filters, vp.currentStamp)
+			vp.fs.ApplyFilters(false)
+		case SelectText, SelectCodes, SelectAdjust:
+			vp.startSelection(image.Point{X: startX, Y: startY})
+		case Measure, DrawDimension:
+			vp.startDimension(vp.dragStart)
 		}
 
 		return // No need to process first event.
@@ -488,10 +675,20 @@ func (vp *ViewPort) consumeDragEvents() {
 // the previous call.
 // 随着鼠标拖动实时更新end point
 func (vp *ViewPort) doDragThrottled(ev *fyne.DragEvent) {
+	if vp.movingGuide != nil {
+		vp.dragGuide(ev.Position)
+		return
+	}
 	switch vp.currentOperation {
 	case NoOp, CropTopLeft, CropBottomRight, DrawText:
 		// 当NoOp时，裁剪，或者文本时，如果单击鼠标进行拖动就拖动图片
+		if vp.movingOverlay != nil {
+			vp.dragOverlay(ev.Position)
+			return
+		}
 		vp.dragViewDelta(ev.Position.Subtract(vp.dragStart))
+	case CropDrag:
+		vp.dragCrop(ev.Position)
 	case DrawCircle:
 		vp.dragCircle(ev.Position)
 	case DrawArrow:
@@ -506,6 +703,12 @@ func (vp *ViewPort) doDragThrottled(ev *fyne.DragEvent) {
 		vp.dragRectangle(ev.Position)
 	case DrawPen:
 		vp.DragPen(ev.Position)
+	case DrawStamp:
+		vp.dragStamp(ev.Position)
+	case SelectText, SelectCodes, SelectAdjust:
+		vp.dragSelection(ev.Position)
+	case Measure, DrawDimension:
+		vp.dragDimension(ev.Position)
 	}
 }
 
@@ -525,12 +728,9 @@ func (vp *ViewPort) dragCircle(toPos fyne.Position) {
 	if vp.currentCircle == nil {
 		glog.Errorf("dragCircle(): dragCircle event, but none has been started yet!?")
 	}
-	startX, startY := vp.screenshotPos(vp.dragStart)
-	startX += vp.fs.CropRect.Min.X
-	startY += vp.fs.CropRect.Min.Y
-	toX, toY := vp.screenshotPos(toPos)
-	toX += vp.fs.CropRect.Min.X
-	toY += vp.fs.CropRect.Min.Y
+	start := vp.annotationPoint(vp.dragStart, vp.currentCircle)
+	to := vp.annotationPoint(toPos, vp.currentCircle)
+	startX, startY, toX, toY := start.X, start.Y, to.X, to.Y
 	vp.currentCircle.SetDim(image.Rectangle{
 		Min: image.Point{X: startX, Y: startY},
 		Max: image.Point{X: toX, Y: toY},
@@ -545,10 +745,8 @@ func (vp *ViewPort) dragArrow(toPos fyne.Position) {
 	if vp.currentArrow == nil {
 		glog.Errorf("dragArrow(): dragArrow event, but none has been started yet!?")
 	}
-	toX, toY := vp.screenshotPos(toPos)
-	toX += vp.fs.CropRect.Min.X
-	toY += vp.fs.CropRect.Min.Y
-	vp.currentArrow.SetPoints(vp.currentArrow.From, image.Point{X: toX, Y: toY})
+	to := vp.annotationPoint(toPos, vp.currentArrow)
+	vp.currentArrow.SetPoints(vp.currentArrow.From, to)
 	glog.V(2).Infof("dragArrow(): draw an arrow in %+v", vp.currentArrow)
 	vp.fs.ApplyFilters(false)
 	vp.renderCache()
@@ -560,10 +758,8 @@ func (vp *ViewPort) dragLine(toPos fyne.Position) {
 	if vp.currentStraightLine == nil {
 		glog.Errorf("dragLine(): dragLine event, but none has been started yet!?")
 	}
-	toX, toY := vp.screenshotPos(toPos)
-	toX += vp.fs.CropRect.Min.X
-	toY += vp.fs.CropRect.Min.Y
-	vp.currentStraightLine.SetPoints(vp.currentStraightLine.From, image.Point{X: toX, Y: toY})
+	to := vp.annotationPoint(toPos, vp.currentStraightLine)
+	vp.currentStraightLine.SetPoints(vp.currentStraightLine.From, to)
 	glog.V(2).Infof("dragStraightLine(): draw an line in %+v", vp.currentStraightLine)
 	vp.fs.ApplyFilters(false)
 	vp.renderCache()
@@ -575,10 +771,8 @@ func (vp *ViewPort) dragDottedLine(toPos fyne.Position) {
 	if vp.currentDottedLine == nil {
 		glog.Errorf("dragLine(): dragDottedLine event, but none has been started yet!?")
 	}
-	toX, toY := vp.screenshotPos(toPos)
-	toX += vp.fs.CropRect.Min.X
-	toY += vp.fs.CropRect.Min.Y
-	vp.currentDottedLine.SetPoints(vp.currentDottedLine.From, image.Point{X: toX, Y: toY})
+	to := vp.annotationPoint(toPos, vp.currentDottedLine)
+	vp.currentDottedLine.SetPoints(vp.currentDottedLine.From, to)
 	glog.V(2).Infof("dragDottedLine(): draw an dot line in %+v", vp.currentDottedLine)
 	vp.fs.ApplyFilters(false)
 	vp.renderCache()
@@ -588,6 +782,7 @@ func (vp *ViewPort) dragDottedLine(toPos fyne.Position) {
 func (vp *ViewPort) DragPen(toPos fyne.Position) {
 	if vp.currentPen == nil {
 		glog.Errorf("dragPen(): dragPen event, but none has been started yet!?")
+		return
 	}
 	toX, toY := vp.screenshotPos(toPos)
 	toX += vp.fs.CropRect.Min.X
@@ -596,7 +791,7 @@ func (vp *ViewPort) DragPen(toPos fyne.Position) {
 	vp.currentPen.SetPoints(image.Point{
 		toX, toY,
 	})
-	glog.V(2).Infof("dragPen(): draw a point in %+v", vp.currentPen)
+	glog.V(2).Infof("dragPen(): draw a point at (%d, %d)", toX, toY)
 	vp.fs.ApplyFilters(false)
 	vp.renderCache()
 	vp.Refresh()
@@ -607,12 +802,9 @@ func (vp *ViewPort) dragShieldBlock(toPos fyne.Position) {
 	if vp.currentShieldBlock == nil {
 		glog.Errorf("dragShieldBlock(): dragShieldBlock event, but none has been started yet!?")
 	}
-	startX, startY := vp.screenshotPos(vp.dragStart)
-	startX += vp.fs.CropRect.Min.X
-	startY += vp.fs.CropRect.Min.Y
-	toX, toY := vp.screenshotPos(toPos)
-	toX += vp.fs.CropRect.Min.X
-	toY += vp.fs.CropRect.Min.Y
+	start := vp.annotationPoint(vp.dragStart, vp.currentShieldBlock)
+	to := vp.annotationPoint(toPos, vp.currentShieldBlock)
+	startX, startY, toX, toY := start.X, start.Y, to.X, to.Y
 	// 设置进去的rect已经保证max > min
 	vp.currentShieldBlock.SetRect(image.Rectangle{
 		Min: image.Point{X: startX, Y: startY},
@@ -629,12 +821,9 @@ func (vp *ViewPort) dragRectangle(toPos fyne.Position) {
 	if vp.currentRectangle == nil {
 		glog.Errorf("dragRectangle(): dragRectangle event, but none has been started yet!?")
 	}
-	startX, startY := vp.screenshotPos(vp.dragStart)
-	startX += vp.fs.CropRect.Min.X
-	startY += vp.fs.CropRect.Min.Y
-	toX, toY := vp.screenshotPos(toPos)
-	toX += vp.fs.CropRect.Min.X
-	toY += vp.fs.CropRect.Min.Y
+	start := vp.annotationPoint(vp.dragStart, vp.currentRectangle)
+	to := vp.annotationPoint(toPos, vp.currentRectangle)
+	startX, startY, toX, toY := start.X, start.Y, to.X, to.Y
 	// 设置进去的rect已经保证max > min
 	vp.currentRectangle.SetRect(image.Rectangle{
 		Min: image.Point{X: startX, Y: startY},
@@ -650,32 +839,59 @@ func (vp *ViewPort) dragRectangle(toPos fyne.Position) {
 func (vp *ViewPort) DragEnd() {
 	glog.V(2).Infof("DragEnd(), dragEvents=%v", vp.dragEvents != nil)
 	close(vp.dragEvents)
+	if vp.movingGuide != nil {
+		// 拖动参考线不影响当前的工具
+		vp.endGuideDrag()
+		vp.dragEvents = nil
+		vp.dragSkipTap = true
+		return
+	}
 
 	switch vp.currentOperation {
-	case NoOp, CropTopLeft, CropBottomRight, DrawText:
+	case NoOp, CropTopLeft, CropBottomRight, CropDrag, DrawText:
 		// Drag the image around, nothing to do to start.
-	case DrawCircle, DrawArrow, DrawStraightLine, DrawDottedLine, DrawShieldBlock, DrawRectangle, DrawPen:
+	case DrawCircle, DrawArrow, DrawStraightLine, DrawDottedLine, DrawShieldBlock, DrawRectangle, DrawPen, DrawStamp:
 		vp.fs.ApplyFilters(true)
+	case SelectText:
+		vp.fs.copyText(vp.endSelection())
+	case SelectCodes:
+		vp.fs.detectCodes(vp.endSelection())
+	case SelectAdjust:
+		vp.fs.adjustRegion(vp.endSelection())
+	case Measure, DrawDimension:
+		vp.endDimension()
 	}
 	vp.dragEvents = nil
 	vp.dragSkipTap = true
+	if vp.movingOverlay != nil {
+		if vp.movingOverlay.Rect.Min != vp.movingOverlayFrom {
+			vp.fs.overlayMoves = append(vp.fs.overlayMoves, overlayMove{
+				overlay: vp.movingOverlay, from: vp.movingOverlayFrom, filters: len(vp.fs.Filters)})
+			vp.fs.status.SetText("Image moved, use Control+Z to undo.")
+		}
+		vp.movingOverlay = nil
+		vp.fs.ApplyFilters(true)
+	}
 
 	switch vp.currentOperation {
-	case NoOp, CropTopLeft, CropBottomRight, DrawText:
+	case NoOp, CropTopLeft, CropBottomRight, CropDrag, DrawText:
 		// Nothing to do
 	case DrawPen:
 		vp.fs.status.SetText("Drawing done, use Control+Z to undo.")
 		vp.SetOp(NoOp)
 
-	case DrawCircle, DrawArrow, DrawStraightLine, DrawDottedLine, DrawShieldBlock, DrawRectangle:
+	case DrawCircle, DrawArrow, DrawStraightLine, DrawDottedLine, DrawShieldBlock, DrawRectangle, DrawStamp:
 		vp.currentCircle = nil
 		vp.currentArrow = nil
 		vp.currentStraightLine = nil
 		vp.currentDottedLine = nil
 		vp.currentShieldBlock = nil
 		vp.currentRectangle = nil
+		vp.currentStamp = nil
 		vp.fs.status.SetText("Drawing done, use Control+Z to undo.")
 		vp.SetOp(NoOp)
+	case SelectText, SelectCodes, SelectAdjust, Measure, DrawDimension:
+		vp.SetOp(NoOp)
 	}
 }
 
@@ -688,7 +904,7 @@ func (vp *ViewPort) DragEnd() {
 func (vp *ViewPort) MouseIn(ev *desktop.MouseEvent) {
 	vp.mouseIn = true
 	if vp.cursor != nil {
-		vp.cursor.Move(ev.Position)
+		vp.cursor.Move(vp.cursorPos(ev.Position))
 	}
 }
 
@@ -710,11 +926,22 @@ func (vp *ViewPort) MouseOut() {
 // mouse movement event.
 func (vp *ViewPort) processMouseMoveEvent(pos fyne.Position) {
 	if vp.cursor != nil {
-		vp.cursor.Move(pos)
+		if vp.currentOperation == PickColor {
+			vp.updateLoupe(vp.screenshotPixel(pos))
+		}
+		vp.cursor.Move(vp.cursorPos(pos))
 		vp.Refresh()
 	}
 }
 
+// cursorPos 鼠标后面跟随的图标的位置，放大镜稍微偏移，不挡住鼠标所指的像素
+func (vp *ViewPort) cursorPos(pos fyne.Position) fyne.Position {
+	if vp.cursor == vp.cursorEyedropper {
+		return pos.Add(loupeOffset)
+	}
+	return pos
+}
+
 // consumeMouseMoveEvents 等待鼠标事件并处理
 func (vp *ViewPort) consumeMouseMoveEvents() {
 	// vp.mouseMoveEvents 只有进程退出该GoRoutine才会退出
@@ -758,6 +985,10 @@ func (vp *ViewPort) SetOp(op OperationType) {
 		vp.DragEnd()
 	}
 	vp.currentOperation = op
+	if op != CropDrag && !vp.cropSelection.Empty() {
+		vp.cropSelection = image.Rectangle{}
+		vp.Refresh()
+	}
 	switch op {
 	case NoOp:
 		if vp.cursor != nil {
@@ -773,6 +1004,11 @@ func (vp *ViewPort) SetOp(op OperationType) {
 		vp.cursor = vp.cursorCropBottomRight
 		vp.cursor.Resize(cursorSize)
 
+	case CropDrag:
+		vp.cursor = vp.cursorDrawRectangle
+		vp.cursor.Resize(cursorSize)
+		vp.fs.status.SetText("Drag to select the region to crop, then drag the handles to adjust it!")
+
 	case DrawCircle:
 		vp.cursor = vp.cursorDrawCircle
 		vp.cursor.Resize(cursorSize)
@@ -811,6 +1047,34 @@ func (vp *ViewPort) SetOp(op OperationType) {
 		vp.cursor = vp.cursorDrawPen
 		vp.cursor.Resize(cursorSize)
 		vp.fs.status.SetText("Click and drag from start to end (point side) to draw some points!")
+	case DrawStamp:
+		vp.cursor = vp.cursorDrawStamp
+		vp.cursor.Resize(cursorSize)
+		vp.fs.status.SetText("Click to place the stamp, or click and drag to define its size!")
+	case SelectText:
+		vp.cursor = vp.cursorDrawRectangle
+		vp.cursor.Resize(cursorSize)
+		vp.fs.status.SetText("Click and drag to select the text to copy, or click to copy all the text!")
+	case SelectCodes:
+		vp.cursor = vp.cursorDrawRectangle
+		vp.cursor.Resize(cursorSize)
+		vp.fs.status.SetText("Click and drag to select where to look for codes, or click to search the whole screenshot!")
+	case SelectAdjust:
+		vp.cursor = vp.cursorDrawRectangle
+		vp.cursor.Resize(cursorSize)
+		vp.fs.status.SetText("Click and drag to select the region to adjust, or click to adjust the whole screenshot!")
+	case PickColor:
+		vp.cursor = vp.cursorEyedropper
+		vp.cursor.Resize(vp.cursorEyedropper.MinSize())
+		vp.fs.status.SetText("Click on a pixel to copy its color!")
+	case Measure:
+		vp.cursor = vp.cursorDrawLine
+		vp.cursor.Resize(cursorSize)
+		vp.fs.status.SetText("Click and drag between two points to measure them!")
+	case DrawDimension:
+		vp.cursor = vp.cursorDrawLine
+		vp.cursor.Resize(cursorSize)
+		vp.fs.status.SetText("Click and drag between two points to add a dimension line!")
 	}
 
 }
@@ -840,77 +1104,44 @@ func (vp *ViewPort) Tapped(ev *fyne.PointEvent) {
 	switch vp.currentOperation {
 	case NoOp:
 		// Nothing ...
-	case CropTopLeft:
-		vp.cropTopLeft(screenshotX, screenshotY)
-	case CropBottomRight:
-		vp.cropBottomRight(screenshotX, screenshotY)
-	case DrawCircle, DrawArrow, DrawStraightLine, DrawDottedLine, DrawShieldBlock, DrawRectangle, DrawPen:
+	case CropTopLeft, CropBottomRight:
+		// 裁剪的边缘同样对齐到参考线、网格和截图中的边缘
+		corner := vp.snapPoint(absolutePoint, nil).Sub(vp.fs.CropRect.Min)
+		if vp.currentOperation == CropTopLeft {
+			vp.cropTopLeft(corner.X, corner.Y)
+		} else {
+			vp.cropBottomRight(corner.X, corner.Y)
+		}
+	case CropDrag:
+		// 单击裁剪区域内时裁剪，否则取消
+		if absolutePoint.In(vp.cropSelection) {
+			vp.applyCropSelection()
+			return
+		}
+		vp.SetOp(NoOp)
+		vp.fs.status.SetText("Crop cancelled.")
+	case DrawCircle, DrawArrow, DrawStraightLine, DrawDottedLine, DrawShieldBlock, DrawRectangle, DrawPen, DrawDimension:
 		vp.fs.status.SetText("You must drag to draw something ...")
+	case Measure:
+		vp.fs.status.SetText("You must drag between two points to measure them ...")
 	case DrawText:
 		vp.createTextFilter(absolutePoint)
+	case DrawStamp:
+		vp.placeStamp(absolutePoint)
+	case SelectText:
+		vp.fs.copyText(image.Rectangle{})
+	case SelectCodes:
+		vp.fs.detectCodes(image.Rectangle{})
+	case SelectAdjust:
+		vp.fs.adjustRegion(image.Rectangle{})
+	case PickColor:
+		vp.pickColorAt(vp.screenshotPixel(ev.Position))
 	}
 
 	// After a tap
 	vp.SetOp(NoOp)
 }
 
-func (vp *ViewPort) createTextFilter(center image.Point) {
-	var form dialog.Dialog
-	textEntry := widget.NewMultiLineEntry()
-	textEntry.Resize(fyne.NewSize(400, 80))
-	fontSize := widget.NewEntry()
-	fontSize.SetText(fmt.Sprintf("%g", vp.FontSize))
-	fontSize.Validator = validation.NewRegexp(`\d`, "Must contain a number")
-	bgColorRect := canvas.NewRectangle(vp.BackgroundColor)
-	bgColorRect.SetMinSize(fyne.NewSize(200, 20))
-	picker := dialog.NewColorPicker(
-		"Pick a Color", "Select background color for text",
-		func(c color.Color) {
-			vp.BackgroundColor = c
-			vp.fs.SetColorPreference(BackgroundColorPreference, c)
-			bgColorRect.FillColor = vp.BackgroundColor
-			bgColorRect.Refresh()
-			form.Refresh()
-		},
-		vp.fs.Win)
-	backgroundEntry := container.NewHBox(
-		// Set color button.
-		widget.NewButtonWithIcon("", resources.ColorWheel, func() { picker.Show() }),
-		// No color button
-		widget.NewButtonWithIcon("", resources.Reset, func() {
-			vp.BackgroundColor = Transparent
-			bgColorRect.FillColor = vp.BackgroundColor
-			bgColorRect.Refresh()
-		}),
-		bgColorRect,
-	)
-	items := []*widget.FormItem{
-		widget.NewFormItem("Text", textEntry),
-		widget.NewFormItem("Font size", fontSize),
-		widget.NewFormItem("Background", backgroundEntry),
-	}
-	form = dialog.NewForm("Insert text", "Ok", "Cancel", items,
-		func(confirm bool) {
-			if confirm {
-				fSize, err := strconv.ParseFloat(fontSize.Text, 64)
-				if err != nil {
-					glog.Errorf("Error parsing the font size given: %q", fontSize.Text)
-					vp.fs.status.SetText(fmt.Sprintf("Error parsing the font size given: %q", fontSize.Text))
-					return
-				}
-				vp.FontSize = fSize
-				vp.fs.App.Preferences().SetFloat(FontSizePreference, fSize)
-				textFilter := filters.NewText(textEntry.Text, center, vp.DrawingColor, vp.BackgroundColor, fSize)
-				vp.fs.Filters = append(vp.fs.Filters, textFilter)
-				vp.fs.ApplyFilters(true)
-				vp.fs.status.SetText("Text drawn, use Control+Z to undo.")
-			}
-		}, vp.fs.Win)
-	form.Resize(fyne.NewSize(500, 300))
-	form.Show()
-	vp.fs.Win.Canvas().Focus(textEntry)
-}
-
 // cropTopLeft will crop the screenshot on this position.
 func (vp *ViewPort) cropTopLeft(x, y int) {
 	vp.fs.CropRect.Min = vp.fs.CropRect.Min.Add(image.Point{X: x, Y: y})
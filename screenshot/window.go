@@ -11,6 +11,7 @@ import (
 	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"gitee.com/andrewgithub/FireShotGo/filters"
 	"gitee.com/andrewgithub/FireShotGo/firetheme"
 	"gitee.com/andrewgithub/FireShotGo/resources"
 	"github.com/golang/glog"
@@ -43,6 +44,7 @@ func (fs *FireShotGO) BuildEditWindow() {
 			fs.status.SetText("点击裁剪的右下角")
 			fs.viewPort.SetOp(CropBottomRight)
 		})
+	cropDrag := widget.NewButtonWithIcon("", theme.ContentCutIcon(), func() { fs.viewPort.SetOp(CropDrag) })
 	cropReset := widget.NewButtonWithIcon("", resources.Reset, func() {
 		fs.viewPort.cropReset()
 		fs.viewPort.SetOp(NoOp)
@@ -57,8 +59,8 @@ func (fs *FireShotGO) BuildEditWindow() {
 	rectangleButton := widget.NewButton("矩形框 (alt+r)", func() { fs.viewPort.SetOp(DrawRectangle) })
 	rectangleButton.SetIcon(resources.DrawRectangle)
 
-	//penButton := widget.NewButton("画笔 (alt+p)", func() { fs.viewPort.SetOp(DrawPen) })
-	//penButton.SetIcon(resources.DrawPen)
+	penButton := widget.NewButton("画笔 (alt+p)", func() { fs.viewPort.SetOp(DrawPen) })
+	penButton.SetIcon(resources.DrawPen)
 
 	fs.thicknessEntry = &widget.Entry{Validator: validation.NewRegexp(`\d`, "Must contain a number")}
 	fs.thicknessEntry.SetPlaceHolder(fmt.Sprintf("%g", fs.viewPort.Thickness))
@@ -86,6 +88,7 @@ func (fs *FireShotGO) BuildEditWindow() {
 		// FIXME: 已添加矢量图标 2021-09-30
 		widget.NewButtonWithIcon("直线 (alt+l)", resources.DrawLine,
 			func() { fs.viewPort.SetOp(DrawStraightLine) }),
+		fs.setLineCaps(),
 		widget.NewSeparator(),
 		widget.NewButtonWithIcon("虚线 (alt+d)", resources.DrawDottedLine,
 			func() { fs.viewPort.SetOp(DrawDottedLine) }),
@@ -94,20 +97,25 @@ func (fs *FireShotGO) BuildEditWindow() {
 		circleButton,
 		shieldBlockButton,
 		rectangleButton,
-		//penButton,
+		penButton,
 		container.NewHBox(
 			widget.NewLabel("裁剪:"),
 			cropTopLeft,
 			cropBottomRight,
+			cropDrag,
 			cropReset,
 		),
+		container.NewHBox(widget.NewLabel("比例:"), fs.makeCropAspectSelect()),
 		container.NewHBox(
 			widget.NewIcon(resources.Thickness), fs.thicknessEntry,
 			widget.NewButtonWithIcon("", resources.ColorWheel, func() { fs.colorPicker() }),
 			fs.colorSample,
 		),
+		fs.setShapeStyle(),
 		widget.NewButtonWithIcon("文本 (alt+t)", resources.DrawText,
 			func() { fs.viewPort.SetOp(DrawText) }),
+		widget.NewSeparator(),
+		fs.setStamps(),
 	)
 
 	// Status bar with zoom control.
@@ -205,6 +213,146 @@ func (fs *FireShotGO) setDottedLineSpacing() *fyne.Container {
 	return container.NewVBox(slide, bar, buttons)
 }
 
+// setLineCaps 线条端点设置: 起点、终点的样式以及端点相对线宽的大小
+func (fs *FireShotGO) setLineCaps() *fyne.Container {
+	tailSelect := widget.NewSelect(filters.LineCapNames, func(name string) {
+		fs.viewPort.LineCaps.Tail = filters.ParseLineCap(name)
+		fs.App.Preferences().SetInt(LineTailPreference, int(fs.viewPort.LineCaps.Tail))
+	})
+	tailSelect.SetSelected(fs.viewPort.LineCaps.Tail.String())
+
+	headSelect := widget.NewSelect(filters.LineCapNames, func(name string) {
+		fs.viewPort.LineCaps.Head = filters.ParseLineCap(name)
+		fs.App.Preferences().SetInt(LineHeadPreference, int(fs.viewPort.LineCaps.Head))
+	})
+	headSelect.SetSelected(fs.viewPort.LineCaps.Head.String())
+
+	sizeEntry := &widget.Entry{Validator: validation.NewRegexp(`\d`, "Must contain a number")}
+	sizeEntry.SetPlaceHolder(fmt.Sprintf("%g", fs.viewPort.LineCaps.Size))
+	sizeEntry.OnChanged = func(str string) {
+		glog.V(2).Infof("Line cap size changed to %s", str)
+		val, err := strconv.ParseFloat(str, 64)
+		if err == nil && val > 0 {
+			fs.viewPort.LineCaps.Size = val
+			fs.App.Preferences().SetFloat(LineCapSizePreference, val)
+		}
+	}
+
+	return container.NewGridWithColumns(2,
+		widget.NewLabel("起点:"), tailSelect,
+		widget.NewLabel("终点:"), headSelect,
+		widget.NewLabel("端点大小:"), sizeEntry,
+	)
+}
+
+// setStamps 图章设置: 选择图章，以及设置用户图章所在的目录
+func (fs *FireShotGO) setStamps() *fyne.Container {
+	stampSelect := widget.NewSelect(fs.viewPort.StampNames(), func(name string) {
+		fs.viewPort.selectStamp(name)
+		fs.viewPort.SetOp(DrawStamp)
+	})
+	stampSelect.SetSelected(fs.viewPort.Stamp.Name)
+
+	folderButton := widget.NewButton("图章目录", func() {
+		dialog.NewFolderOpen(func(dir fyne.ListableURI, err error) {
+			if err != nil {
+				glog.Errorf("Failed to select stamps folder: %s", err)
+				fs.status.SetText(fmt.Sprintf("Failed to select stamps folder: %s", err))
+				return
+			}
+			if dir == nil {
+				return
+			}
+			fs.App.Preferences().SetString(StampsFolderPreference, dir.Path())
+			fs.viewPort.loadStamps()
+			stampSelect.Options = fs.viewPort.StampNames()
+			stampSelect.SetSelected(fs.viewPort.Stamp.Name)
+			fs.status.SetText(fmt.Sprintf("Loaded %d stamps, including those in %s", len(fs.viewPort.Stamps), dir.Path()))
+		}, fs.Win).Show()
+	})
+
+	return container.NewVBox(
+		widget.NewButton("图章 (alt+s)", func() { fs.viewPort.SetOp(DrawStamp) }),
+		container.NewGridWithColumns(2, stampSelect, folderButton),
+	)
+}
+
+// setShapeStyle 图形样式设置: 填充颜色、轮廓和填充的不透明度、圆角半径以及轮廓的虚线样式
+func (fs *FireShotGO) setShapeStyle() *fyne.Container {
+	fillSample := canvas.NewRectangle(fs.viewPort.FillColor)
+	fillSample.StrokeColor = Yellow
+	fillSample.StrokeWidth = 1
+	size1d := theme.IconInlineSize()
+	fillSample.SetMinSize(fyne.NewSize(3*size1d, size1d))
+	setFill := func(c color.Color) {
+		fs.viewPort.FillColor = c
+		fs.SetColorPreference(FillColorPreference, c)
+		fillSample.FillColor = c
+		fillSample.Refresh()
+	}
+	fillPicker := widget.NewButtonWithIcon("", resources.ColorWheel, func() {
+		dialog.NewColorPicker("Pick a Color", "Select fill color for shapes", setFill, fs.Win).Show()
+	})
+	fillReset := widget.NewButtonWithIcon("", resources.Reset, func() { setFill(Transparent) })
+
+	strokeOpacity := binding.BindFloat(&fs.viewPort.StrokeOpacity)
+	strokeOpacity.AddListener(binding.NewDataListener(func() {
+		fs.App.Preferences().SetFloat(StrokeOpacityPreference, fs.viewPort.StrokeOpacity)
+	}))
+	strokeSlider := widget.NewSliderWithData(0.05, 1, strokeOpacity)
+	strokeSlider.Step = 0.05
+
+	fillOpacity := binding.BindFloat(&fs.viewPort.FillOpacity)
+	fillOpacity.AddListener(binding.NewDataListener(func() {
+		fs.App.Preferences().SetFloat(FillOpacityPreference, fs.viewPort.FillOpacity)
+	}))
+	fillSlider := widget.NewSliderWithData(0.05, 1, fillOpacity)
+	fillSlider.Step = 0.05
+
+	radiusEntry := &widget.Entry{Validator: validation.NewRegexp(`\d`, "Must contain a number")}
+	radiusEntry.SetPlaceHolder(fmt.Sprintf("%g", fs.viewPort.CornerRadius))
+	radiusEntry.OnChanged = func(str string) {
+		glog.V(2).Infof("Corner radius changed to %s", str)
+		val, err := strconv.ParseFloat(str, 64)
+		if err == nil && val >= 0 {
+			fs.viewPort.CornerRadius = val
+			fs.App.Preferences().SetFloat(CornerRadiusPreference, val)
+		}
+	}
+
+	dashSelect := widget.NewSelect(filters.DashStyleNames, func(name string) {
+		fs.viewPort.DashStyle = filters.ParseDashStyle(name)
+		fs.App.Preferences().SetInt(DashStylePreference, int(fs.viewPort.DashStyle))
+	})
+	dashSelect.SetSelected(fs.viewPort.DashStyle.String())
+
+	// 关闭抗锯齿之后边缘不会出现混合的颜色，适合需要精确到像素的场合
+	antiAliasCheck := widget.NewCheck("抗锯齿", nil)
+	antiAliasCheck.SetChecked(fs.viewPort.AntiAlias)
+	antiAliasCheck.OnChanged = func(on bool) {
+		fs.viewPort.AntiAlias = on
+		fs.App.Preferences().SetBool(AntiAliasPreference, on)
+		// 已有的图形也一起修改，只有修改过的图形会重新绘制
+		for _, filter := range fs.Filters {
+			if a, ok := filter.(filters.AntiAliaser); ok {
+				a.SetAntiAlias(on)
+			}
+		}
+		fs.ApplyFilters(false)
+	}
+
+	return container.NewVBox(
+		container.NewHBox(widget.NewLabel("填充:"), fillPicker, fillReset, fillSample),
+		container.NewGridWithColumns(2,
+			widget.NewLabel("轮廓透明度:"), strokeSlider,
+			widget.NewLabel("填充透明度:"), fillSlider,
+			widget.NewLabel("圆角半径:"), radiusEntry,
+			widget.NewLabel("轮廓:"), dashSelect,
+		),
+		antiAliasCheck,
+	)
+}
+
 func (fs *FireShotGO) colorPicker() {
 	glog.V(2).Infof("colorPicker():")
 	picker := dialog.NewColorPicker(
@@ -225,6 +373,10 @@ func (fs *FireShotGO) MakeFireShotMenu() *fyne.MainMenu {
 	menuFile := fyne.NewMenu("文件",
 		fyne.NewMenuItem("打开", func() { fs.OpenImage() }),
 		fyne.NewMenuItem("保存 (ctrl+s)", func() { fs.SaveImage() }),
+		fyne.NewMenuItem("图片格式", func() { fs.ImageFormatForm() }),
+		fyne.NewMenuItem("图片信息", func() { fs.MetadataForm() }),
+		fyne.NewMenuItem("导出 SVG", func() { fs.ExportSVG() }),
+		fyne.NewMenuItem("导出 PDF", func() { fs.ExportPDFForm() }),
 		fyne.NewMenuItem("截屏", func() { fs.DelayedScreenshotForm() }),
 	) // Quit is added automatically.
 
@@ -235,6 +387,32 @@ func (fs *FireShotGO) MakeFireShotMenu() *fyne.MainMenu {
 				fs.fireShotGoFont.FireShotFontEdit(fs)
 			}),
 		fyne.NewMenuItem("复制 (ctrl+c)", func() { fs.CopyImageToClipboard() }),
+		fyne.NewMenuItem("美化导出", func() { fs.BeautifyForm() }),
+		fyne.NewMenuItem("插入图片", func() { fs.InsertImageFromFile() }),
+		fyne.NewMenuItem("粘贴图片 (ctrl+shift+v)", func() { fs.InsertImageFromClipboard() }),
+		fyne.NewMenuItem("插入截图", func() { fs.InsertCaptureForm() }),
+		fyne.NewMenuItem("复制选择区域的文字", func() { fs.CopyTextFromSelection() }),
+		fyne.NewMenuItem("文字识别设置", func() { fs.OCRForm() }),
+		fyne.NewMenuItem("自动遮挡", func() { fs.AutoRedact() }),
+		fyne.NewMenuItem("遮挡规则", func() { fs.RedactionRulesForm() }),
+		fyne.NewMenuItem("识别二维码 / 条形码", func() { fs.DetectCodes() }),
+		fyne.NewMenuItem("取色", func() { fs.PickColor() }),
+		fyne.NewMenuItem("测量", func() { fs.Measure() }),
+		fyne.NewMenuItem("尺寸标注", func() { fs.DrawDimension() }),
+		fyne.NewMenuItem("测量设置", func() { fs.MeasureForm() }),
+		fyne.NewMenuItem("网格与对齐", func() { fs.GridForm() }),
+		fyne.NewMenuItem("调整图像", func() { fs.AdjustImage() }),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("拖动裁剪 (alt+x)", func() { fs.CropDrag() }),
+		fyne.NewMenuItem("精确裁剪", func() { fs.CropForm() }),
+		fyne.NewMenuItem("向左旋转 90°", func() { fs.Transform(rotateCounterClockwise) }),
+		fyne.NewMenuItem("向右旋转 90°", func() { fs.Transform(rotateClockwise) }),
+		fyne.NewMenuItem("水平翻转", func() { fs.Transform(flipHorizontal) }),
+		fyne.NewMenuItem("垂直翻转", func() { fs.Transform(flipVertical) }),
+		fyne.NewMenuItem("画布大小", func() { fs.ResizeCanvasForm() }),
+		fyne.NewMenuItem("比较截图", func() { fs.CompareForm() }),
+		fyne.NewMenuItem("拼接截图", func() { fs.CollageForm() }),
+		fyne.NewMenuItemSeparator(),
 		fyne.NewMenuItem("虚线设置", func() {
 			fs.fireShotGoFont.FireShotFontEdit(fs)
 		}),
@@ -0,0 +1,187 @@
+// Package stamps 图章(贴纸)库: 对勾、叉号、警告、BUG 标签等常用图标。
+//
+// 内置图章使用 SVG 矢量图，可以按照任意大小渲染而不失真；用户也可以把自己的
+// SVG 或者 PNG 图片放到图章目录中，PNG 图片在缩放时使用 Catmull-Rom 插值。
+package stamps
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+	xdraw "golang.org/x/image/draw"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//go:embed svg/*.svg
+var builtinFS embed.FS
+
+// Stamp 一个图章，来源是 SVG 矢量图或者位图
+type Stamp struct {
+	// Name 显示在界面上的名称，SVG 优先使用 <title>，否则使用文件名
+	Name string
+
+	// svg 矢量图的原始内容，每次渲染都重新解析，因为 oksvg 的 SetTarget 会修改图标
+	svg []byte
+	// bitmap 位图图章
+	bitmap image.Image
+	// aspect 宽高比
+	aspect float64
+}
+
+// FromSVG 从 SVG 内容创建图章，name 为空时使用 SVG 中的 <title>
+func FromSVG(name string, data []byte) (*Stamp, error) {
+	icon, err := oksvg.ReadIconStream(bytes.NewReader(data), oksvg.WarnErrorMode)
+	if err != nil {
+		return nil, err
+	}
+	if icon.ViewBox.W <= 0 || icon.ViewBox.H <= 0 {
+		return nil, fmt.Errorf("SVG %q has an empty viewBox", name)
+	}
+	if len(icon.Titles) > 0 && strings.TrimSpace(icon.Titles[0]) != "" {
+		name = strings.TrimSpace(icon.Titles[0])
+	}
+	return &Stamp{Name: name, svg: data, aspect: icon.ViewBox.W / icon.ViewBox.H}, nil
+}
+
+// FromImage 从位图创建图章
+func FromImage(name string, img image.Image) *Stamp {
+	b := img.Bounds()
+	return &Stamp{Name: name, bitmap: img, aspect: float64(b.Dx()) / float64(b.Dy())}
+}
+
+// AspectRatio 返回图章的宽高比
+func (s *Stamp) AspectRatio() float64 { return s.aspect }
+
+// Render 渲染宽为 w 高为 h 的图片，图章保持宽高比居中放置，多余的部分是透明的
+func (s *Stamp) Render(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	if w <= 0 || h <= 0 {
+		return img
+	}
+	// 保持宽高比
+	tw, th := float64(w), float64(h)
+	if tw/th > s.aspect {
+		tw = th * s.aspect
+	} else {
+		th = tw / s.aspect
+	}
+	x, y := (float64(w)-tw)/2, (float64(h)-th)/2
+
+	if s.bitmap != nil {
+		target := image.Rect(int(x+0.5), int(y+0.5), int(math.Round(x+tw)), int(math.Round(y+th)))
+		xdraw.CatmullRom.Scale(img, target, s.bitmap, s.bitmap.Bounds(), xdraw.Over, nil)
+		return img
+	}
+
+	icon, err := oksvg.ReadIconStream(bytes.NewReader(s.svg), oksvg.IgnoreErrorMode)
+	if err != nil {
+		glog.Errorf("Failed to parse stamp %q: %s", s.Name, err)
+		return img
+	}
+	icon.SetTarget(x, y, tw, th)
+	scanner := rasterx.NewScannerGV(w, h, img, img.Bounds())
+	icon.Draw(rasterx.NewDasher(w, h, scanner), 1.0)
+	return img
+}
+
+// Data 返回图章的原始内容: 矢量图章返回 SVG，位图图章返回 PNG，可以通过 Decode 恢复图章
+func (s *Stamp) Data() ([]byte, error) {
+	if s.bitmap == nil {
+		return s.svg, nil
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, s.bitmap); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pngSignature PNG 文件的开头
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// Decode 从 Data 返回的内容恢复图章，内容是 PNG 时创建位图图章，否则按照 SVG 解析
+func Decode(name string, data []byte) (*Stamp, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return FromSVG(name, data)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return FromImage(name, img), nil
+}
+
+// Builtin 返回内置的图章，按照文件名排序
+func Builtin() []*Stamp {
+	entries, err := builtinFS.ReadDir("svg")
+	if err != nil {
+		glog.Fatalf("Failed to read embedded stamps: %s", err)
+	}
+	var list []*Stamp
+	for _, entry := range entries {
+		data, err := builtinFS.ReadFile(path.Join("svg", entry.Name()))
+		if err != nil {
+			glog.Fatalf("Failed to read embedded stamp %q: %s", entry.Name(), err)
+		}
+		s, err := FromSVG(strings.TrimSuffix(entry.Name(), ".svg"), data)
+		if err != nil {
+			glog.Fatalf("Failed to parse embedded stamp %q: %s", entry.Name(), err)
+		}
+		list = append(list, s)
+	}
+	return list
+}
+
+// LoadFolder 读取目录 dir 中的 SVG 和 PNG 图章，无法解析的文件会被跳过
+func LoadFolder(dir string) ([]*Stamp, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+	var list []*Stamp
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		filePath := filepath.Join(dir, file.Name())
+		name := strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))
+		var s *Stamp
+		switch strings.ToLower(filepath.Ext(file.Name())) {
+		case ".svg":
+			data, err := ioutil.ReadFile(filePath)
+			if err == nil {
+				s, err = FromSVG(name, data)
+			}
+			if err != nil {
+				glog.Warningf("Skipping stamp %q: %s", filePath, err)
+				continue
+			}
+		case ".png":
+			data, err := ioutil.ReadFile(filePath)
+			var img image.Image
+			if err == nil {
+				img, err = png.Decode(bytes.NewReader(data))
+			}
+			if err != nil {
+				glog.Warningf("Skipping stamp %q: %s", filePath, err)
+				continue
+			}
+			s = FromImage(name, img)
+		default:
+			continue
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}